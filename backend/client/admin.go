@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Search for users by the start of their username, with their role and space.
+func (c *Client) AdminSearchUsers(ctx context.Context, username string) ([]AdminUser, error) {
+	res := struct {
+		Users []AdminUser `json:"users"`
+	}{}
+	err := c.do(ctx, "GET", "/api/admin/users/"+url.PathEscape(username), nil, nil, &res)
+	return res.Users, err
+}
+
+func (c *Client) AdminDeleteUser(ctx context.Context, id int) error {
+	return c.do(ctx, "DELETE", "/api/admin/user/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+// Set a user's role to "guest", "user" or "admin".
+func (c *Client) SetUserRole(ctx context.Context, id int, role string) error {
+	body := struct {
+		Role string `json:"role"`
+	}{role}
+	return c.do(ctx, "PATCH", "/api/admin/user/role/"+strconv.Itoa(id), nil, body, nil)
+}
+
+// Set the bytes a user can upload.
+func (c *Client) SetUserStorageSpace(ctx context.Context, id, amount int) error {
+	body := struct {
+		ID     int `json:"id"`
+		Amount int `json:"amount"`
+	}{id, amount}
+	return c.do(ctx, "PATCH", "/api/admin/user/storage-space", nil, body, nil)
+}
+
+// Remove the lockout after failed logins from an account.
+func (c *Client) DeleteAccountLockout(ctx context.Context, username string) error {
+	return c.do(ctx, "DELETE", "/api/admin/lockout/account/"+url.PathEscape(username), nil, nil, nil)
+}
+
+// Remove the lockout after failed logins from an ip.
+func (c *Client) DeleteIPLockout(ctx context.Context, ip string) error {
+	return c.do(ctx, "DELETE", "/api/admin/lockout/ip/"+url.PathEscape(ip), nil, nil, nil)
+}
+
+// Get a page of the request logs, newest first.
+// before is the Next of the previous page, or 0 for the first page. limit is the page size, 0 for the default.
+func (c *Client) Logs(ctx context.Context, filter LogFilter, before, limit int) (*Logs, error) {
+	query := filter.values()
+	if before != 0 {
+		query.Set("before", strconv.Itoa(before))
+	}
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	logs := &Logs{}
+	err := c.do(ctx, "GET", "/api/admin/logs", query, nil, logs)
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// Stream the request logs as "csv" or "ndjson", the caller has to close the returned reader.
+// limit is the most rows to export, 0 for the default.
+func (c *Client) ExportLogs(ctx context.Context, filter LogFilter, format string, limit int) (io.ReadCloser, error) {
+	query := filter.values()
+	query.Set("format", format)
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	res, err := c.send(ctx, "GET", "/api/admin/logs/export", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// Get the latency, error rate and busiest users and ips of the request logs.
+// interval is "minute", "hour" or "day" and top is the number of users and ips, empty and 0 for the defaults.
+func (c *Client) LogStats(ctx context.Context, filter LogFilter, interval string, top int) (*LogStats, error) {
+	query := filter.values()
+	if interval != "" {
+		query.Set("interval", interval)
+	}
+	if top != 0 {
+		query.Set("top", strconv.Itoa(top))
+	}
+	stats := &LogStats{}
+	err := c.do(ctx, "GET", "/api/admin/logs/stats", query, nil, stats)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Get the versions of the backend and the health of its dependencies.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	status := &Status{}
+	err := c.do(ctx, "GET", "/api/admin/status", nil, nil, status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (f LogFilter) values() url.Values {
+	query := url.Values{}
+	if !f.From.IsZero() {
+		query.Set("from", f.From.Format(time.RFC3339Nano))
+	}
+	if !f.To.IsZero() {
+		query.Set("to", f.To.Format(time.RFC3339Nano))
+	}
+	if f.UserID != nil {
+		query.Set("userID", strconv.Itoa(*f.UserID))
+	}
+	for name, value := range map[string]string{"ip": f.IP, "endpoint": f.Endpoint, "method": f.Method, "status": f.Status} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	return query
+}
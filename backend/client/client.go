@@ -0,0 +1,210 @@
+// Package client is a Go client for the file hosting API.
+//
+// Every endpoint has a typed method, and Upload and Download move whole files through the presigned storage urls.
+// The session token is taken from the cookie the server sets on login and sent back in an "Authorization: Bearer"
+// header, so the client works without a cookie jar and the token can be saved with Token and restored with SetToken.
+package client
+
+import (
+	"backend/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	// Number of file parts Upload sends at the same time.
+	Parallelism int
+	// Times a request rejected by a rate limit is sent again after waiting for its Retry-After,
+	// 0 to return the error right away. Longer waits than maxRetryAfter are not retried.
+	RateLimitRetries int
+
+	mu    sync.Mutex
+	token string
+}
+
+// Create a client for the server at baseURL, for example "https://localhost".
+// If httpClient is nil http.DefaultClient is used. It is also used for the presigned storage urls.
+func New(baseURL string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("client: base url %q needs a scheme and a host", baseURL)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: u, httpClient: httpClient, Parallelism: 4, RateLimitRetries: 3}, nil
+}
+
+// Get the current session token, empty if the client is not logged in.
+// The server replaces the token when it expires, so save it again after using the client.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Use a session token saved with Token.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// Longest Retry-After of a rate limited request the client waits for before sending it again.
+const maxRetryAfter = time.Minute
+
+// Error returned when the server responds with a status other than 200.
+type Error struct {
+	StatusCode int
+	// Time to wait before sending the request again, from the Retry-After header of a 429 or 503 response.
+	RetryAfter time.Duration
+	types.ErrorResponse
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%d %s: %s (request %s)", e.StatusCode, e.Code, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Check if err is an *Error with the status code.
+func IsStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// Send a request to the API and return the response if its status is 200, the caller has to close its body.
+// body is encoded as JSON if it is not nil.
+// A request rejected by a rate limit is sent again after its Retry-After, up to RateLimitRetries times.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		res, err := c.sendOnce(ctx, method, path, query, data)
+		var apiErr *Error
+		if attempt >= c.RateLimitRetries || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests ||
+			apiErr.RetryAfter > maxRetryAfter {
+			return res, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(max(apiErr.RetryAfter, time.Second)):
+		}
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, method, path string, query url.Values, data []byte) (*http.Response, error) {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := c.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	c.updateToken(res)
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, readError(res)
+	}
+	return res, nil
+}
+
+// Send a request to the API and decode the JSON response into result if it is not nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	res, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if result == nil {
+		return nil
+	}
+	err = json.NewDecoder(res.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("client: decoding the response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// Keep the token from the session cookie, which is set on login and when an expired token is refreshed,
+// and removed on logout.
+func (c *Client) updateToken(res *http.Response) {
+	for _, cookie := range res.Cookies() {
+		if cookie.Name != "file_hosting" {
+			continue
+		}
+		if cookie.MaxAge < 0 || cookie.Value == "" {
+			c.SetToken("")
+		} else {
+			c.SetToken(cookie.Value)
+		}
+	}
+}
+
+func readError(res *http.Response) error {
+	apiErr := &Error{StatusCode: res.StatusCode, RetryAfter: retryAfter(res.Header.Get("Retry-After"))}
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	if err == nil && strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		err = json.Unmarshal(data, &apiErr.ErrorResponse)
+	}
+	// Responses not written by the API, for example from a proxy.
+	if err != nil || apiErr.Code == "" {
+		apiErr.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(res.StatusCode)), " ", "_")
+		apiErr.Message = strings.TrimSpace(string(data))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(res.StatusCode)
+		}
+	}
+	return apiErr
+}
+
+// Parse a Retry-After header, which is either a number of seconds or a date, 0 if it is not set or invalid.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Serve 429 with a Retry-After of one second for the first limited requests, then 200.
+func rateLimitedServer(t *testing.T, limited int32, requests *atomic.Int32) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == "POST" && string(body) != `{"key":"file.txt","size":5,"repositoryID":1}` {
+			t.Errorf("request %d was sent with the body %q", requests.Load()+1, body)
+		}
+		if requests.Add(1) <= limited {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"code":"too_many_requests","message":"Too many requests, try again later"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"fileID":7,"uploadParts":[]}`))
+	}))
+	t.Cleanup(server.Close)
+	c, err := New(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRetryAfterRateLimit(t *testing.T) {
+	var requests atomic.Int32
+	c := rateLimitedServer(t, 2, &requests)
+
+	start := time.Now()
+	res, err := c.StartUpload(context.Background(), 1, "file.txt", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.FileID != 7 || requests.Load() != 3 {
+		t.Fatalf("got file %d after %d requests, want file 7 after 3", res.FileID, requests.Load())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second*2 {
+		t.Fatalf("retried after %s, want at least the 2s of Retry-After", elapsed)
+	}
+}
+
+func TestRateLimitRetriesRunOut(t *testing.T) {
+	var requests atomic.Int32
+	c := rateLimitedServer(t, 5, &requests)
+	c.RateLimitRetries = 1
+
+	_, err := c.StartUpload(context.Background(), 1, "file.txt", 5)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != time.Second {
+		t.Fatalf("got %v, want a 429 error with a Retry-After of 1s", err)
+	}
+	if requests.Load() != 2 {
+		t.Fatalf("sent %d requests, want 2", requests.Load())
+	}
+}
+
+func TestRateLimitRetryStopsWithContext(t *testing.T) {
+	var requests atomic.Int32
+	c := rateLimitedServer(t, 5, &requests)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	start := time.Now()
+	_, err := c.StartUpload(ctx, 1, "file.txt", 5)
+	if !IsStatus(err, http.StatusTooManyRequests) {
+		t.Fatalf("got %v, want the 429 error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*900 || requests.Load() != 1 {
+		t.Fatalf("returned after %s and %d requests, want right after the context is done", elapsed, requests.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", time.Second * 3},
+		{"-1", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, test := range tests {
+		if got := retryAfter(test.value); got != test.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := retryAfter(future); got <= time.Second*55 || got > time.Minute {
+		t.Errorf("retryAfter(%q) = %s, want about a minute", future, got)
+	}
+}
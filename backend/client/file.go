@@ -0,0 +1,110 @@
+package client
+
+import (
+	"backend/types"
+	"context"
+	"strconv"
+)
+
+// Get a presigned url to download a file from the storage.
+func (c *Client) DownloadURL(ctx context.Context, id int) (string, error) {
+	res := struct {
+		URL string `json:"url"`
+	}{}
+	err := c.do(ctx, "GET", "/api/file/"+strconv.Itoa(id), nil, nil, &res)
+	return res.URL, err
+}
+
+func (c *Client) DeleteFile(ctx context.Context, id int) error {
+	return c.do(ctx, "DELETE", "/api/file/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+// Create a folder at key, for example "folder/inner", in a repository. The containing folder has to exist.
+func (c *Client) CreateFolder(ctx context.Context, repositoryID int, key string) (*Folder, error) {
+	body := struct {
+		Key          string `json:"key"`
+		RepositoryID int    `json:"repositoryID"`
+	}{key, repositoryID}
+	folder := &Folder{}
+	err := c.do(ctx, "POST", "/api/file/folder", nil, body, folder)
+	if err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// Delete a folder with everything in it.
+func (c *Client) DeleteFolder(ctx context.Context, id int) error {
+	return c.do(ctx, "DELETE", "/api/file/folder/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+func (c *Client) RenameFile(ctx context.Context, id int, name string) error {
+	body := struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}{id, name}
+	return c.do(ctx, "PATCH", "/api/file/name", nil, body, nil)
+}
+
+func (c *Client) RenameFolder(ctx context.Context, id int, name string) error {
+	body := struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}{id, name}
+	return c.do(ctx, "PATCH", "/api/file/folder/name", nil, body, nil)
+}
+
+// Start a multipart upload of size bytes to key in a repository.
+// Returns the id of the file and a presigned url for every part, split like fileutil.SplitFile does.
+func (c *Client) StartUpload(ctx context.Context, repositoryID int, key string, size int64) (*types.UploadStartResponse, error) {
+	body := struct {
+		Key          string `json:"key"`
+		Size         int64  `json:"size"`
+		RepositoryID int    `json:"repositoryID"`
+	}{key, size, repositoryID}
+	res := &types.UploadStartResponse{}
+	err := c.do(ctx, "POST", "/api/file/upload-start", nil, body, res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Record the ETag the storage returned for an uploaded part.
+func (c *Client) AddUploadPart(ctx context.Context, fileID int, eTag string, part int) error {
+	body := struct {
+		FileID int    `json:"fileID"`
+		ETag   string `json:"eTag"`
+		Part   int    `json:"part"`
+	}{fileID, eTag, part}
+	return c.do(ctx, "POST", "/api/file/file-part", nil, body, nil)
+}
+
+// Complete an upload after every part is recorded and return its date as Unix time in seconds.
+func (c *Client) CompleteUpload(ctx context.Context, fileID int) (int, error) {
+	body := struct {
+		ID int `json:"id"`
+	}{fileID}
+	res := struct {
+		Date int `json:"date"`
+	}{}
+	err := c.do(ctx, "POST", "/api/file/upload-complete", nil, body, &res)
+	return res.Date, err
+}
+
+// Get new presigned urls for the parts of an upload that are not recorded yet.
+func (c *Client) ResumeUpload(ctx context.Context, fileID int) ([]types.UploadPart, error) {
+	body := struct {
+		ID int `json:"id"`
+	}{fileID}
+	res := struct {
+		UploadParts []types.UploadPart `json:"uploadParts"`
+	}{}
+	err := c.do(ctx, "POST", "/api/file/upload-resume", nil, body, &res)
+	return res.UploadParts, err
+}
+
+// Abort an upload that is not completed and delete its file.
+func (c *Client) AbortUpload(ctx context.Context, fileID int) error {
+	return c.do(ctx, "DELETE", "/api/file/in-progress/"+strconv.Itoa(fileID), nil, nil, nil)
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"strconv"
+)
+
+// Add a user to a repository and return the id of the membership. permission is "full" or "read".
+func (c *Client) AddMember(ctx context.Context, repositoryID, userID int, permission string) (int, error) {
+	body := struct {
+		UserID       int    `json:"userID"`
+		RepositoryID int    `json:"repositoryID"`
+		Permission   string `json:"permission"`
+	}{userID, repositoryID, permission}
+	res := struct {
+		ID int `json:"id"`
+	}{}
+	err := c.do(ctx, "POST", "/api/member/", nil, body, &res)
+	return res.ID, err
+}
+
+func (c *Client) RemoveMember(ctx context.Context, id int) error {
+	return c.do(ctx, "DELETE", "/api/member/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+// Stop being a member of a repository.
+func (c *Client) LeaveRepository(ctx context.Context, repositoryID int) error {
+	return c.do(ctx, "DELETE", "/api/member/leave/"+strconv.Itoa(repositoryID), nil, nil, nil)
+}
+
+func (c *Client) SetMemberPermission(ctx context.Context, id int, permission string) error {
+	body := struct {
+		ID         int    `json:"id"`
+		Permission string `json:"permission"`
+	}{id, permission}
+	return c.do(ctx, "PATCH", "/api/member/permission", nil, body, nil)
+}
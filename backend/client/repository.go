@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"strconv"
+)
+
+// Get a repository with its members and files.
+func (c *Client) Repository(ctx context.Context, id int) (*Repository, error) {
+	repository := &Repository{}
+	err := c.do(ctx, "GET", "/api/repository/"+strconv.Itoa(id), nil, nil, repository)
+	if err != nil {
+		return nil, err
+	}
+	return repository, nil
+}
+
+// Get the repositories the user owns or is a member of.
+func (c *Client) Repositories(ctx context.Context) ([]RepositorySummary, error) {
+	res := struct {
+		Repositories []RepositorySummary `json:"repositories"`
+	}{}
+	err := c.do(ctx, "GET", "/api/repository/all-repositories", nil, nil, &res)
+	return res.Repositories, err
+}
+
+// Create a repository and return its id. visibility is "public" or "private".
+func (c *Client) CreateRepository(ctx context.Context, name, visibility string) (int, error) {
+	body := struct {
+		Name       string `json:"name"`
+		Visibility string `json:"visibility"`
+	}{name, visibility}
+	res := struct {
+		ID int `json:"id"`
+	}{}
+	err := c.do(ctx, "POST", "/api/repository/", nil, body, &res)
+	return res.ID, err
+}
+
+func (c *Client) DeleteRepository(ctx context.Context, id int) error {
+	return c.do(ctx, "DELETE", "/api/repository/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+func (c *Client) RenameRepository(ctx context.Context, id int, name string) error {
+	body := struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}{id, name}
+	return c.do(ctx, "PATCH", "/api/repository/name", nil, body, nil)
+}
+
+func (c *Client) SetRepositoryVisibility(ctx context.Context, id int, visibility string) error {
+	body := struct {
+		ID         int    `json:"id"`
+		Visibility string `json:"visibility"`
+	}{id, visibility}
+	return c.do(ctx, "PATCH", "/api/repository/visibility", nil, body, nil)
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"strconv"
+)
+
+// Get the user's sessions, including the revoked ones.
+func (c *Client) Sessions(ctx context.Context) ([]Session, error) {
+	res := struct {
+		Sessions []Session `json:"sessions"`
+	}{}
+	err := c.do(ctx, "GET", "/api/session/all", nil, nil, &res)
+	return res.Sessions, err
+}
+
+// End every session of the user, including the current one.
+func (c *Client) DeleteSessions(ctx context.Context) error {
+	return c.do(ctx, "DELETE", "/api/session/all", nil, nil, nil)
+}
+
+func (c *Client) DeleteSession(ctx context.Context, id int) error {
+	return c.do(ctx, "DELETE", "/api/session/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+func (c *Client) RenameSession(ctx context.Context, id int, name string) error {
+	body := struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}{id, name}
+	return c.do(ctx, "PATCH", "/api/session/name", nil, body, nil)
+}
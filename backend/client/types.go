@@ -0,0 +1,184 @@
+package client
+
+import "time"
+
+type Account struct {
+	Username   string `json:"username"`
+	Role       string `json:"role"` // "guest", "user" or "admin".
+	SpaceTaken int    `json:"spaceTaken"`
+	Space      int    `json:"space"`
+}
+
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+type SecurityEvents struct {
+	Events []SecurityEvent `json:"events"`
+	// Id to pass as before to get the next page, nil if this is the last page.
+	Next *int `json:"next"`
+}
+
+type SecurityEvent struct {
+	ID      int       `json:"id"`
+	Type    string    `json:"type"` // For example "login_failed".
+	Date    time.Time `json:"date"`
+	IP      *string   `json:"ip"`
+	Device  *string   `json:"device"`
+	Details *string   `json:"details"`
+}
+
+type Session struct {
+	ID            int        `json:"id"`
+	ExpiryDate    time.Time  `json:"expiryDate"`
+	Device        string     `json:"device"`
+	RevokedDate   *time.Time `json:"revokedDate"`
+	RevokedReason *string    `json:"revokedReason"`
+	CreatedDate   time.Time  `json:"createdDate"`
+	LastUsedDate  time.Time  `json:"lastUsedDate"`
+	LastIP        *string    `json:"lastIP"`
+	Name          *string    `json:"name"`
+	Current       bool       `json:"current"` // True for the session of this client.
+}
+
+type AdminUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Space    int    `json:"space"`
+}
+
+// Filter of the request logs, zero fields are not used.
+type LogFilter struct {
+	From     time.Time
+	To       time.Time
+	UserID   *int // 0 for requests that were not authenticated.
+	IP       string
+	Endpoint string
+	Method   string
+	Status   string // A status code like "404" or a class like "5xx".
+}
+
+type Logs struct {
+	Logs []LogEntry `json:"logs"`
+	// Id to pass as before to get the next page, nil if this is the last page.
+	Next *int `json:"next"`
+}
+
+type LogEntry struct {
+	ID            int       `json:"id"`
+	Date          time.Time `json:"date"`
+	IP            string    `json:"ip"`
+	UserID        int       `json:"userID"`
+	Username      *string   `json:"username"`
+	ExecutionTime float32   `json:"executionTime"` // In milliseconds.
+	Endpoint      string    `json:"endpoint"`
+	Route         *string   `json:"route"`
+	Method        string    `json:"method"`
+	Status        int       `json:"status"`
+	Bytes         *int64    `json:"bytes"`
+	RequestID     *string   `json:"requestID"`
+	UserAgent     *string   `json:"userAgent"`
+	ErrorCode     *string   `json:"errorCode"`
+	ErrorMessage  *string   `json:"errorMessage"`
+}
+
+type LogStats struct {
+	From    time.Time `json:"from"`
+	Latency []struct {
+		Endpoint string  `json:"endpoint"`
+		Method   string  `json:"method"`
+		Requests int     `json:"requests"`
+		P50      float64 `json:"p50"`
+		P95      float64 `json:"p95"`
+		P99      float64 `json:"p99"`
+	} `json:"latency"`
+	ErrorRate []struct {
+		Date         time.Time `json:"date"`
+		Requests     int       `json:"requests"`
+		ClientErrors int       `json:"clientErrors"`
+		ServerErrors int       `json:"serverErrors"`
+		Rate         float64   `json:"rate"`
+	} `json:"errorRate"`
+	TopUsers []struct {
+		UserID   int     `json:"userID"`
+		Username *string `json:"username"`
+		Requests int     `json:"requests"`
+	} `json:"topUsers"`
+	TopIPs []struct {
+		IP       string `json:"ip"`
+		Requests int    `json:"requests"`
+	} `json:"topIPs"`
+}
+
+type Status struct {
+	Status   string  `json:"status"` // "ok" or "unavailable".
+	Uptime   float64 `json:"uptime"` // In seconds.
+	Versions struct {
+		Go          string  `json:"go"`
+		Revision    *string `json:"revision"`
+		Modified    bool    `json:"modified"`
+		Database    *string `json:"database"`
+		LogDatabase *string `json:"logDatabase"`
+	} `json:"versions"`
+	Schema struct {
+		Version        int  `json:"version"`
+		AppliedVersion *int `json:"appliedVersion"`
+	} `json:"schema"`
+	Dependencies []struct {
+		Name    string  `json:"name"`
+		Status  string  `json:"status"`
+		Latency float64 `json:"latency"` // In milliseconds.
+		Error   string  `json:"error"`
+	} `json:"dependencies"`
+}
+
+type RepositorySummary struct {
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	OwnerUsername     string `json:"ownerUsername"`
+	UserUploadedSpace int    `json:"userUploadedSpace"`
+}
+
+type Repository struct {
+	Name           string   `json:"name"`
+	Members        []Member `json:"members"`
+	Files          []File   `json:"files"`
+	UserPermission string   `json:"userPermission"` // "owner", "full", "read" or "none".
+	OwnerUsername  string   `json:"ownerUsername"`
+	Visibility     string   `json:"visibility"` // "public" or "private".
+}
+
+type Member struct {
+	ID         int    `json:"id"`
+	Username   string `json:"username"`
+	Permission string `json:"permission"` // "full" or "read".
+}
+
+// A file or a folder in a repository.
+type File struct {
+	ID            int    `json:"id"`
+	OwnerUsername string `json:"ownerUsername"`
+	Path          string `json:"path"` // For example "folder/file.txt".
+	Type          string `json:"type"` // "file" or "folder".
+	Size          int    `json:"size"`
+	UploadDate    int    `json:"uploadDate"` // Unix time in seconds, 0 if the upload is not completed.
+}
+
+type Folder struct {
+	ID   int `json:"id"`
+	Date int `json:"date"` // Unix time in seconds.
+}
+
+type JWKS struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg"`
+		Use string `json:"use"`
+	} `json:"keys"`
+}
@@ -0,0 +1,211 @@
+package client
+
+import (
+	"backend/types"
+	"backend/util/fileutil"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Times a part is sent to the storage before the upload is resumed with new presigned urls.
+const partAttempts = 3
+
+// Times the missing parts are resumed before the upload fails.
+const resumeAttempts = 3
+
+type UploadResult struct {
+	FileID int
+	Date   int // Unix time in seconds the upload was completed, 0 if it was not.
+}
+
+// Upload the contents of r to path, for example "folder/file.txt", in a repository.
+// The parts are sent to their presigned urls Parallelism at a time, retried and resumed with new urls if they fail,
+// and recorded before the upload is completed.
+// If r is an io.ReaderAt and io.Seeker, like *os.File or *bytes.Reader, it is read from its current offset to its end,
+// otherwise it is first copied to a temporary file to know its size.
+// If the upload fails after it was started the result still has the FileID, to finish it with ContinueUpload
+// or remove it with AbortUpload.
+func (c *Client) Upload(ctx context.Context, repositoryID int, path string, r io.Reader) (*UploadResult, error) {
+	data, cleanup, err := sectionReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	start, err := c.StartUpload(ctx, repositoryID, path, data.Size())
+	if err != nil {
+		return nil, err
+	}
+	result := &UploadResult{FileID: start.FileID}
+	result.Date, err = c.finishUpload(ctx, start.FileID, start.UploadParts, data, data.Size())
+	return result, err
+}
+
+// Upload the parts of a started upload that are not recorded yet and complete it,
+// for example after the program that started it was interrupted. data has to have the size passed to Upload.
+func (c *Client) ContinueUpload(ctx context.Context, fileID int, data io.ReaderAt, size int64) (*UploadResult, error) {
+	parts, err := c.ResumeUpload(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	result := &UploadResult{FileID: fileID}
+	result.Date, err = c.finishUpload(ctx, fileID, parts, data, size)
+	return result, err
+}
+
+// Download a file into w and return the number of bytes written.
+func (c *Client) Download(ctx context.Context, fileID int, w io.Writer) (int64, error) {
+	url, err := c.DownloadURL(ctx, fileID)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("client: storage responded with %s", res.Status)
+	}
+	return io.Copy(w, res.Body)
+}
+
+func (c *Client) finishUpload(ctx context.Context, fileID int, parts []types.UploadPart, data io.ReaderAt, size int64) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := c.uploadParts(ctx, fileID, parts, data, size)
+		if err == nil {
+			break
+		}
+		if attempt == resumeAttempts || ctx.Err() != nil {
+			return 0, err
+		}
+		// The presigned urls may have expired, get new ones for the parts that are still missing.
+		parts, err = c.ResumeUpload(ctx, fileID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return c.CompleteUpload(ctx, fileID)
+}
+
+// Send the parts to the storage and record their ETags.
+func (c *Client) uploadParts(ctx context.Context, fileID int, parts []types.UploadPart, data io.ReaderAt, size int64) error {
+	// Split the file the same way the server did when it presigned the urls.
+	partCount, partSize, leftover := fileutil.SplitFile(int(size))
+	for _, part := range parts {
+		if part.Part < 1 || part.Part > partCount {
+			return fmt.Errorf("client: part %d is not in a file of %d parts", part.Part, partCount)
+		}
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(max(c.Parallelism, 1))
+	for _, part := range parts {
+		length := partSize
+		if part.Part == partCount && leftover != 0 {
+			length = leftover
+		}
+		section := io.NewSectionReader(data, int64(part.Part-1)*int64(partSize), int64(length))
+		group.Go(func() error {
+			eTag, err := c.putPart(groupCtx, part.URL, section)
+			if err != nil {
+				return fmt.Errorf("client: uploading part %d: %w", part.Part, err)
+			}
+			return c.AddUploadPart(groupCtx, fileID, eTag, part.Part)
+		})
+	}
+	return group.Wait()
+}
+
+// Send a part to its presigned url and return its ETag, retrying with a backoff.
+func (c *Client) putPart(ctx context.Context, url string, part *io.SectionReader) (string, error) {
+	var err error
+	for attempt := range partAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(time.Second << (attempt - 1)):
+			}
+		}
+		var eTag string
+		eTag, err = c.put(ctx, url, io.NewSectionReader(part, 0, part.Size()))
+		if err == nil {
+			return eTag, nil
+		}
+	}
+	return "", err
+}
+
+func (c *Client) put(ctx context.Context, url string, body *io.SectionReader) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, body)
+	if err != nil {
+		return "", err
+	}
+	// The length is part of the presigned url's signature.
+	req.ContentLength = body.Size()
+	if body.Size() == 0 {
+		req.Body = http.NoBody
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("storage responded with %s", res.Status)
+	}
+	eTag := res.Header.Get("ETag")
+	if eTag == "" {
+		return "", errors.New("storage did not return an ETag")
+	}
+	return eTag, nil
+}
+
+// Get a reader of r from its current offset to its end that can be read from many goroutines.
+// The returned function removes the temporary file if one had to be created.
+func sectionReader(r io.Reader) (*io.SectionReader, func(), error) {
+	if seeker, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			var end int64
+			end, err = seeker.Seek(0, io.SeekEnd)
+			if err == nil {
+				_, err = seeker.Seek(offset, io.SeekStart)
+				if err == nil {
+					return io.NewSectionReader(seeker, offset, end-offset), func() {}, nil
+				}
+			}
+		}
+		// Not seekable, for example a pipe, copy it like other readers.
+	}
+
+	file, err := os.CreateTemp("", "file_hosting-upload-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	size, err := io.Copy(file, r)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return io.NewSectionReader(file, 0, size), cleanup, nil
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Create an account as a guest and log in to it.
+func (c *Client) CreateUser(ctx context.Context, username, password string) error {
+	return c.do(ctx, "POST", "/api/user/", nil, credentials{username, password}, nil)
+}
+
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	return c.do(ctx, "POST", "/api/user/login", nil, credentials{username, password}, nil)
+}
+
+// Log out and end the current session.
+func (c *Client) Logout(ctx context.Context) error {
+	return c.do(ctx, "POST", "/api/user/logout", nil, nil, nil)
+}
+
+// Delete the logged in user with all of their repositories.
+func (c *Client) DeleteUser(ctx context.Context) error {
+	return c.do(ctx, "DELETE", "/api/user/", nil, nil, nil)
+}
+
+func (c *Client) Account(ctx context.Context) (*Account, error) {
+	account := &Account{}
+	err := c.do(ctx, "GET", "/api/user/account", nil, nil, account)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// Search for users by the start of their username.
+func (c *Client) SearchUsers(ctx context.Context, username string) ([]User, error) {
+	res := struct {
+		Users []User `json:"users"`
+	}{}
+	err := c.do(ctx, "GET", "/api/user/users/"+url.PathEscape(username), nil, nil, &res)
+	return res.Users, err
+}
+
+// Get a page of the user's security events, newest first.
+// before is the Next of the previous page, or 0 for the first page. limit is the page size, 0 for the default.
+func (c *Client) SecurityEvents(ctx context.Context, before, limit int) (*SecurityEvents, error) {
+	query := url.Values{}
+	if before != 0 {
+		query.Set("before", strconv.Itoa(before))
+	}
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	events := &SecurityEvents{}
+	err := c.do(ctx, "GET", "/api/user/security-events", query, nil, events)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (c *Client) ChangeUsername(ctx context.Context, username string) error {
+	body := struct {
+		Username string `json:"username"`
+	}{username}
+	return c.do(ctx, "PATCH", "/api/user/username", nil, body, nil)
+}
+
+func (c *Client) ChangePassword(ctx context.Context, currentPassword, newPassword string) error {
+	body := struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}{currentPassword, newPassword}
+	return c.do(ctx, "PATCH", "/api/user/password", nil, body, nil)
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"io"
+)
+
+// Get the public keys that session tokens are signed with.
+func (c *Client) JWKS(ctx context.Context) (*JWKS, error) {
+	jwks := &JWKS{}
+	err := c.do(ctx, "GET", "/api/.well-known/jwks.json", nil, nil, jwks)
+	if err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+// Get the OpenAPI document of the server.
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	res, err := c.send(ctx, "GET", "/api/openapi.json", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return io.ReadAll(res.Body)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Saved in the user's config directory, or at FH_CONFIG.
+type config struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+	// Uploads that were started but not completed.
+	Uploads []pendingUpload `json:"uploads"`
+}
+
+// An upload to finish by uploading the rest of the local file, if it did not change.
+type pendingUpload struct {
+	Server       string    `json:"server"`
+	FileID       int       `json:"fileID"`
+	RepositoryID int       `json:"repositoryID"`
+	Path         string    `json:"path"`
+	LocalPath    string    `json:"localPath"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"modTime"`
+}
+
+func configPath() (string, error) {
+	if path := os.Getenv("FH_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "fh", "config.json"), nil
+}
+
+// Load the config, an empty one if it does not exist yet.
+func loadConfig() (*config, error) {
+	cfg := &config{}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	// Write to another file first so an interrupted write does not lose the token.
+	temp := path + ".tmp"
+	err = os.WriteFile(temp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// Get the pending upload of a path in a repository, nil if there is none.
+func (cfg *config) pendingUpload(server string, repositoryID int, path string) *pendingUpload {
+	for i := range cfg.Uploads {
+		upload := &cfg.Uploads[i]
+		if upload.Server == server && upload.RepositoryID == repositoryID && upload.Path == path {
+			return upload
+		}
+	}
+	return nil
+}
+
+func (cfg *config) removeUpload(server string, fileID int) {
+	for i, upload := range cfg.Uploads {
+		if upload.Server == server && upload.FileID == fileID {
+			cfg.Uploads = append(cfg.Uploads[:i], cfg.Uploads[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Command fh is a command-line client of the file hosting API, built on the client package.
+//
+// Run "fh help" for the commands and flags.
+package main
+
+import (
+	"backend/client"
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const usage = `Usage: fh [flags] <command> [arguments]
+
+Commands:
+  login <username>                                 log in, the password is read from FH_PASSWORD or stdin
+  logout                                           log out and forget the token
+  token                                            print the session token, to use with -token or FH_TOKEN
+  account                                          show the logged in user
+  repos                                            list the repositories
+  repo create <name> [public|private]              create a repository, private by default
+  repo delete <repo>                               delete a repository with its files
+  repo rename <repo> <name>                        rename a repository
+  repo visibility <repo> <public|private>          change who can see a repository
+  ls <repo> [folder]                               list a folder, the root by default
+  mkdir <repo> <folder>                            create a folder and its parents
+  rename <repo> <path> <name>                      rename a file or a folder
+  rm <repo> <path>                                 delete a file or a folder
+  upload <repo> <local> [folder]                   upload a file or a directory into a folder
+  resume                                           finish the uploads that were interrupted
+  download <repo> <path> [local]                   download a file or a folder
+  sync [flags] <local> [<repo> [folder]]           sync a directory with a repository folder both ways
+  members <repo>                                   list the members of a repository
+  member add <repo> <username> <full|read>         add a member
+  member permission <repo> <username> <full|read>  change the permission of a member
+  member remove <repo> <username>                  remove a member
+  leave <repo>                                     stop being a member of a repository
+
+<repo> is the id or the name of a repository, paths in it look like "folder/file.txt".
+Uploads interrupted with Ctrl-C or by a failure are finished by "fh resume" or by running the same upload again.
+sync takes -dry-run to only print the changes, and -force to sync even if the synced folder is missing
+on the server or more than half of the synced files would be deleted locally.
+
+Flags:
+`
+
+type app struct {
+	client *client.Client
+	config *config
+	server string
+	// Save the token to the config after the command, false if it was passed with -token or FH_TOKEN.
+	saveToken bool
+	// The token the command started with, the config is only changed if the token changed.
+	startToken string
+	json       bool
+	out        io.Writer
+}
+
+func main() {
+	flags := flag.NewFlagSet("fh", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	server := flags.String("server", os.Getenv("FH_SERVER"), "url of the server, defaults to the last one used or https://localhost")
+	token := flags.String("token", os.Getenv("FH_TOKEN"), "session token to use instead of the saved one, the server replaces it when it expires\nand the new one is printed on stderr, the old one cannot be used again")
+	ca := flags.String("ca", os.Getenv("FH_CA"), "PEM file with a certificate to trust, for example backend/util/cert.pem")
+	jsonOutput := flags.Bool("json", false, "print the output as JSON")
+	parallel := flags.Int("parallel", 4, "number of parts of a file uploaded at the same time")
+	err := flags.Parse(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	if flags.NArg() == 0 || flags.Arg(0) == "help" {
+		flags.SetOutput(os.Stdout)
+		flags.Usage()
+		return
+	}
+
+	a, err := newApp(*server, *token, *ca, *jsonOutput, *parallel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fh:", err)
+		os.Exit(1)
+	}
+	// Stop on Ctrl-C, interrupted uploads are kept to be resumed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err = a.run(ctx, flags.Arg(0), flags.Args()[1:])
+	stop()
+	if saveErr := a.save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, "fh:", err)
+		fmt.Fprintln(os.Stderr, `Run "fh help" for the commands.`)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fh:", err)
+		os.Exit(1)
+	}
+}
+
+func newApp(server, token, ca string, jsonOutput bool, parallel int) (*app, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	a := &app{config: cfg, json: jsonOutput, out: os.Stdout}
+	// Use the server of the saved token when none is passed.
+	switch {
+	case server == "" && cfg.Server != "":
+		a.server = cfg.Server
+	case server == "":
+		a.server = "https://localhost"
+	default:
+		a.server = strings.TrimSuffix(server, "/")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs, _ := x509.SystemCertPool()
+		if rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", ca)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	}
+	a.client, err = client.New(a.server, &http.Client{Transport: transport})
+	if err != nil {
+		return nil, err
+	}
+	a.client.Parallelism = parallel
+
+	if token != "" {
+		a.client.SetToken(token)
+	} else {
+		a.saveToken = true
+		// Use the saved token only with the server it is for.
+		if cfg.Server == a.server {
+			a.client.SetToken(cfg.Token)
+		}
+	}
+	a.startToken = a.client.Token()
+	return a, nil
+}
+
+// Save the config with the server and its token if the token was refreshed, replaced by a login or removed.
+// A token passed with -token or FH_TOKEN is not saved, so when the server refreshes it the new one is printed
+// on stderr: the old one is revoked, and using it again would end the session.
+func (a *app) save() error {
+	token := a.client.Token()
+	switch {
+	case a.saveToken && token != a.startToken:
+		a.config.Server = a.server
+		a.config.Token = token
+	case !a.saveToken && token != a.startToken && token != "":
+		fmt.Fprintln(os.Stderr, "fh: the session token was refreshed, use this one with -token or FH_TOKEN from now on:")
+		fmt.Fprintln(os.Stderr, token)
+	}
+	return a.config.save()
+}
+
+var errUsage = errors.New("wrong arguments")
+
+func (a *app) run(ctx context.Context, command string, args []string) error {
+	switch command {
+	case "login":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return a.login(ctx, args[0])
+	case "logout":
+		if len(args) != 0 {
+			return errUsage
+		}
+		err := a.client.Logout(ctx)
+		// The token is forgotten even if the session already ended.
+		a.client.SetToken("")
+		return err
+	case "token":
+		if len(args) != 0 {
+			return errUsage
+		}
+		if a.client.Token() == "" {
+			return errors.New("not logged in")
+		}
+		fmt.Fprintln(a.out, a.client.Token())
+		return nil
+	case "account":
+		return a.account(ctx, args)
+	case "repos":
+		return a.repositories(ctx, args)
+	case "repo":
+		return a.repository(ctx, args)
+	case "ls":
+		return a.list(ctx, args)
+	case "mkdir":
+		return a.mkdir(ctx, args)
+	case "rename":
+		return a.rename(ctx, args)
+	case "rm":
+		return a.remove(ctx, args)
+	case "upload":
+		return a.upload(ctx, args)
+	case "resume":
+		return a.resume(ctx, args)
+	case "download":
+		return a.download(ctx, args)
+	case "sync":
+		return a.sync(ctx, args)
+	case "members":
+		return a.members(ctx, args)
+	case "member":
+		return a.member(ctx, args)
+	case "leave":
+		return a.leave(ctx, args)
+	}
+	return fmt.Errorf("%w: unknown command %q", errUsage, command)
+}
+
+func (a *app) login(ctx context.Context, username string) error {
+	password, ok := os.LookupEnv("FH_PASSWORD")
+	if !ok {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	err := a.client.Login(ctx, username, password)
+	if err != nil {
+		return err
+	}
+	// A token passed with -token is replaced by the new one.
+	a.saveToken = true
+	return a.done(map[string]string{"username": username}, "Logged in as "+username)
+}
+
+func (a *app) account(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	account, err := a.client.Account(ctx)
+	if err != nil {
+		return err
+	}
+	return a.print(account, []string{"USERNAME", "ROLE", "USED", "SPACE"},
+		[][]string{{account.Username, account.Role, formatSize(int64(account.SpaceTaken)), formatSize(int64(account.Space))}})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"backend/client"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Name of the file in a synced directory that keeps the state of the last sync.
+const syncStateFile = ".fh-sync.json"
+
+// Suffix of the temporary name a file is uploaded under before it replaces the server's version.
+const tempUploadSuffix = ".fh-upload"
+
+// The files and folders of a synced directory as they were after the last sync, on both sides.
+// Paths are relative to the synced directory and its repository folder, like "photos/1.jpg".
+type syncState struct {
+	Server       string                `json:"server"`
+	RepositoryID int                   `json:"repositoryID"`
+	Folder       string                `json:"folder"`
+	Files        map[string]syncedFile `json:"files"`
+	Folders      []string              `json:"folders"`
+}
+
+type syncedFile struct {
+	localFile
+	FileID     int `json:"fileID"`
+	UploadDate int `json:"uploadDate"` // Changes with FileID when the file is uploaded again.
+}
+
+type localFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash"` // Hex SHA-256 of the contents.
+}
+
+// The local tree of a synced directory.
+type manifest struct {
+	Files   map[string]localFile
+	Folders map[string]bool
+}
+
+// The files and folders of the repository folder that is synced.
+type remoteTree struct {
+	Files      map[string]client.File
+	Folders    map[string]client.File
+	InProgress map[string]client.File // Files whose upload is not completed.
+}
+
+func loadSyncState(root string) (*syncState, error) {
+	data, err := os.ReadFile(filepath.Join(root, syncStateFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &syncState{}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filepath.Join(root, syncStateFile), err)
+	}
+	return state, nil
+}
+
+func (state *syncState) save(root string) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to another file first so an interrupted write does not lose the state.
+	path := filepath.Join(root, syncStateFile)
+	err = os.WriteFile(path+".tmp", data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Build the manifest of a directory. Files with the size and modification time they had in the last sync
+// keep their hash from it, the others are hashed.
+func scanLocal(root string, previous map[string]syncedFile) (*manifest, error) {
+	m := &manifest{Files: map[string]localFile{}, Folders: map[string]bool{}}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() {
+			m.Folders[rel] = true
+			return nil
+		}
+		// Skip the state, unfinished downloads, symlinks and other special files.
+		if rel == syncStateFile || rel == syncStateFile+".tmp" || strings.HasSuffix(rel, ".fh-download") || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		file := localFile{Size: info.Size(), ModTime: info.ModTime()}
+		if synced, ok := previous[rel]; ok && synced.Size == file.Size && synced.ModTime.Equal(file.ModTime) {
+			file.Hash = synced.Hash
+		} else {
+			file.Hash, err = hashFile(p)
+			if err != nil {
+				return err
+			}
+		}
+		m.Files[rel] = file
+		return nil
+	})
+	return m, err
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Get the files and folders in a repository folder by their path relative to it.
+func listRemote(files []client.File, folder string) *remoteTree {
+	tree := &remoteTree{Files: map[string]client.File{}, Folders: map[string]client.File{}, InProgress: map[string]client.File{}}
+	prefix := ""
+	if folder != "" {
+		prefix = folder + "/"
+	}
+	for _, file := range files {
+		rel, ok := strings.CutPrefix(file.Path, prefix)
+		if !ok || rel == "" || !filepath.IsLocal(filepath.FromSlash(rel)) {
+			continue
+		}
+		// Leave out files being uploaded to replace another one, they are renamed when they are done.
+		if file.Type == "file" && strings.HasPrefix(path.Base(rel), ".") && strings.HasSuffix(rel, tempUploadSuffix) {
+			continue
+		}
+		switch {
+		case file.Type == "folder":
+			tree.Folders[rel] = file
+		case file.UploadDate == 0:
+			tree.InProgress[rel] = file
+		default:
+			tree.Files[rel] = file
+		}
+	}
+	return tree
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Print v as JSON with -json, otherwise print the rows as a table under the header.
+func (a *app) print(v any, header []string, rows [][]string) error {
+	if a.json {
+		encoder := json.NewEncoder(a.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// Print the result of a command that changes something, v with -json and the message otherwise.
+func (a *app) done(v any, message string) error {
+	if a.json {
+		return a.print(v, nil, nil)
+	}
+	_, err := fmt.Fprintln(a.out, message)
+	return err
+}
+
+func formatSize(bytes int64) string {
+	if bytes < 1000 {
+		return strconv.FormatInt(bytes, 10) + "B"
+	}
+	size := float64(bytes)
+	for _, unit := range []string{"KB", "MB", "GB", "TB"} {
+		size /= 1000
+		if size < 1000 || unit == "TB" {
+			return strconv.FormatFloat(size, 'f', 1, 64) + unit
+		}
+	}
+	return ""
+}
+
+// Format Unix time in seconds, "-" for 0.
+func formatDate(date int) string {
+	if date == 0 {
+		return "-"
+	}
+	return time.Unix(int64(date), 0).Format("2006-01-02 15:04")
+}
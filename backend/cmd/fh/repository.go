@@ -0,0 +1,376 @@
+package main
+
+import (
+	"backend/client"
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func (a *app) repositories(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	repositories, err := a.client.Repositories(ctx)
+	if err != nil {
+		return err
+	}
+	rows := [][]string{}
+	for _, r := range repositories {
+		rows = append(rows, []string{strconv.Itoa(r.ID), r.Name, r.OwnerUsername, formatSize(int64(r.UserUploadedSpace))})
+	}
+	return a.print(repositories, []string{"ID", "NAME", "OWNER", "UPLOADED"}, rows)
+}
+
+func (a *app) repository(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	switch {
+	case args[0] == "create" && (len(args) == 2 || len(args) == 3):
+		visibility := "private"
+		if len(args) == 3 {
+			visibility = args[2]
+		}
+		id, err := a.client.CreateRepository(ctx, args[1], visibility)
+		if err != nil {
+			return err
+		}
+		return a.done(map[string]int{"id": id}, fmt.Sprintf("Created repository %s with id %d", args[1], id))
+	case args[0] == "delete" && len(args) == 2:
+		id, err := a.repositoryID(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		err = a.client.DeleteRepository(ctx, id)
+		if err != nil {
+			return err
+		}
+		return a.done(map[string]int{"id": id}, "Deleted repository "+args[1])
+	case args[0] == "rename" && len(args) == 3:
+		id, err := a.repositoryID(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		err = a.client.RenameRepository(ctx, id, args[2])
+		if err != nil {
+			return err
+		}
+		return a.done(map[string]any{"id": id, "name": args[2]}, "Renamed repository "+args[1]+" to "+args[2])
+	case args[0] == "visibility" && len(args) == 3:
+		id, err := a.repositoryID(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		err = a.client.SetRepositoryVisibility(ctx, id, args[2])
+		if err != nil {
+			return err
+		}
+		return a.done(map[string]any{"id": id, "visibility": args[2]}, "Repository "+args[1]+" is "+args[2])
+	}
+	return errUsage
+}
+
+func (a *app) list(ctx context.Context, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errUsage
+	}
+	repositoryID, repository, err := a.getRepository(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	folder := ""
+	if len(args) == 2 {
+		folder = cleanPath(args[1])
+	}
+	if folder != "" {
+		file := findFile(repository.Files, folder)
+		if file == nil || file.Type != "folder" {
+			return fmt.Errorf("no folder %q in repository %d", folder, repositoryID)
+		}
+	}
+
+	files := children(repository.Files, folder)
+	rows := [][]string{}
+	for _, file := range files {
+		size := formatSize(int64(file.Size))
+		if file.Type == "folder" {
+			size = "-"
+		}
+		date := formatDate(file.UploadDate)
+		if file.Type == "file" && file.UploadDate == 0 {
+			date = "uploading"
+		}
+		rows = append(rows, []string{strconv.Itoa(file.ID), file.Type, path.Base(file.Path), size, date, file.OwnerUsername})
+	}
+	return a.print(files, []string{"ID", "TYPE", "NAME", "SIZE", "UPLOADED", "OWNER"}, rows)
+}
+
+func (a *app) mkdir(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	repositoryID, repository, err := a.getRepository(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	folder := cleanPath(args[1])
+	if folder == "" {
+		return errUsage
+	}
+	err = a.ensureFolder(ctx, repositoryID, folder, fileMap(repository.Files))
+	if err != nil {
+		return err
+	}
+	return a.done(map[string]string{"path": folder}, "Created folder "+folder)
+}
+
+func (a *app) rename(ctx context.Context, args []string) error {
+	if len(args) != 3 {
+		return errUsage
+	}
+	repositoryID, repository, err := a.getRepository(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	file := findFile(repository.Files, cleanPath(args[1]))
+	if file == nil {
+		return fmt.Errorf("no file or folder %q in repository %d", cleanPath(args[1]), repositoryID)
+	}
+	if file.Type == "folder" {
+		err = a.client.RenameFolder(ctx, file.ID, args[2])
+	} else {
+		err = a.client.RenameFile(ctx, file.ID, args[2])
+	}
+	if err != nil {
+		return err
+	}
+	newPath := path.Join(path.Dir(file.Path), args[2])
+	return a.done(map[string]any{"id": file.ID, "path": newPath}, "Renamed "+file.Path+" to "+newPath)
+}
+
+func (a *app) remove(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	repositoryID, repository, err := a.getRepository(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	file := findFile(repository.Files, cleanPath(args[1]))
+	if file == nil {
+		return fmt.Errorf("no file or folder %q in repository %d", cleanPath(args[1]), repositoryID)
+	}
+	switch {
+	case file.Type == "folder":
+		err = a.client.DeleteFolder(ctx, file.ID)
+	case file.UploadDate == 0:
+		err = a.client.AbortUpload(ctx, file.ID)
+		if err == nil {
+			a.config.removeUpload(a.server, file.ID)
+		}
+	default:
+		err = a.client.DeleteFile(ctx, file.ID)
+	}
+	if err != nil {
+		return err
+	}
+	return a.done(map[string]any{"id": file.ID, "path": file.Path}, "Deleted "+file.Path)
+}
+
+func (a *app) members(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	_, repository, err := a.getRepository(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	rows := [][]string{}
+	for _, member := range repository.Members {
+		rows = append(rows, []string{strconv.Itoa(member.ID), member.Username, member.Permission})
+	}
+	return a.print(repository.Members, []string{"ID", "USERNAME", "PERMISSION"}, rows)
+}
+
+func (a *app) member(ctx context.Context, args []string) error {
+	if len(args) < 3 {
+		return errUsage
+	}
+	repositoryID, repository, err := a.getRepository(ctx, args[1])
+	if err != nil {
+		return err
+	}
+	username := args[2]
+	switch {
+	case args[0] == "add" && len(args) == 4:
+		users, err := a.client.SearchUsers(ctx, username)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(users, func(user client.User) bool { return user.Username == username })
+		if i == -1 {
+			return fmt.Errorf("no user %q", username)
+		}
+		id, err := a.client.AddMember(ctx, repositoryID, users[i].ID, args[3])
+		if err != nil {
+			return err
+		}
+		return a.done(map[string]any{"id": id, "username": username, "permission": args[3]},
+			fmt.Sprintf("Added %s to repository %d with %s permission", username, repositoryID, args[3]))
+	case args[0] == "permission" && len(args) == 4:
+		member, err := findMember(repository, username)
+		if err != nil {
+			return err
+		}
+		err = a.client.SetMemberPermission(ctx, member.ID, args[3])
+		if err != nil {
+			return err
+		}
+		return a.done(map[string]any{"id": member.ID, "username": username, "permission": args[3]},
+			fmt.Sprintf("%s has %s permission in repository %d", username, args[3], repositoryID))
+	case args[0] == "remove" && len(args) == 3:
+		member, err := findMember(repository, username)
+		if err != nil {
+			return err
+		}
+		err = a.client.RemoveMember(ctx, member.ID)
+		if err != nil {
+			return err
+		}
+		return a.done(map[string]any{"id": member.ID, "username": username},
+			fmt.Sprintf("Removed %s from repository %d", username, repositoryID))
+	}
+	return errUsage
+}
+
+func (a *app) leave(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	id, err := a.repositoryID(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	err = a.client.LeaveRepository(ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.done(map[string]int{"id": id}, "Left repository "+args[0])
+}
+
+// Get the id of a repository from its id or its name.
+func (a *app) repositoryID(ctx context.Context, arg string) (int, error) {
+	if id, err := strconv.Atoi(arg); err == nil {
+		return id, nil
+	}
+	repositories, err := a.client.Repositories(ctx)
+	if err != nil {
+		return 0, err
+	}
+	ids := []int{}
+	for _, r := range repositories {
+		if r.Name == arg {
+			ids = append(ids, r.ID)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return 0, fmt.Errorf("no repository named %q", arg)
+	case 1:
+		return ids[0], nil
+	}
+	return 0, fmt.Errorf("%d repositories are named %q, use the id of one of them: %v", len(ids), arg, ids)
+}
+
+func (a *app) getRepository(ctx context.Context, arg string) (int, *client.Repository, error) {
+	id, err := a.repositoryID(ctx, arg)
+	if err != nil {
+		return 0, nil, err
+	}
+	repository, err := a.client.Repository(ctx, id)
+	return id, repository, err
+}
+
+// Create a folder and the folders containing it that are not in files, adding them to files.
+func (a *app) ensureFolder(ctx context.Context, repositoryID int, folder string, files map[string]client.File) error {
+	if folder == "" {
+		return nil
+	}
+	if file, ok := files[folder]; ok {
+		if file.Type != "folder" {
+			return fmt.Errorf("%s is a file, not a folder", folder)
+		}
+		return nil
+	}
+	err := a.ensureFolder(ctx, repositoryID, parent(folder), files)
+	if err != nil {
+		return err
+	}
+	created, err := a.client.CreateFolder(ctx, repositoryID, folder)
+	if err != nil {
+		return fmt.Errorf("creating folder %s: %w", folder, err)
+	}
+	files[folder] = client.File{ID: created.ID, Path: folder, Type: "folder", UploadDate: created.Date}
+	return nil
+}
+
+func findMember(repository *client.Repository, username string) (*client.Member, error) {
+	for i := range repository.Members {
+		if repository.Members[i].Username == username {
+			return &repository.Members[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a member of the repository", username)
+}
+
+// Clean a path in a repository, the root is an empty string.
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// Get the folder containing a path, an empty string for the root.
+func parent(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+func findFile(files []client.File, p string) *client.File {
+	for i := range files {
+		if files[i].Path == p {
+			return &files[i]
+		}
+	}
+	return nil
+}
+
+func fileMap(files []client.File) map[string]client.File {
+	m := make(map[string]client.File, len(files))
+	for _, file := range files {
+		m[file.Path] = file
+	}
+	return m
+}
+
+// Get the files and folders directly in a folder, folders first and then by name.
+func children(files []client.File, folder string) []client.File {
+	result := []client.File{}
+	for _, file := range files {
+		if parent(file.Path) == folder {
+			result = append(result, file)
+		}
+	}
+	slices.SortFunc(result, func(a, b client.File) int {
+		if a.Type != b.Type {
+			return strings.Compare(b.Type, a.Type)
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	return result
+}
@@ -0,0 +1,617 @@
+package main
+
+import (
+	"backend/client"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A change made by a sync.
+type syncAction struct {
+	// "upload", "download", "delete-remote", "delete-local", "rename-remote", "rename-local", "mkdir-remote",
+	// "mkdir-local", "delete-remote-folder", "delete-local-folder" or "conflict".
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	// New path of a rename, or the path the local file of a conflict is kept at.
+	To     string `json:"to,omitempty"`
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Order in which the actions are applied: folders are created first, then files are renamed and changed,
+// and folders are deleted last when they are empty.
+var actionOrder = []string{"mkdir-remote", "mkdir-local", "rename-remote", "rename-local", "conflict", "upload", "download",
+	"delete-remote", "delete-local", "delete-remote-folder", "delete-local-folder"}
+
+type syncer struct {
+	*app
+	repositoryID int
+	folder       string
+	root         string
+	state        *syncState
+	local        *manifest
+	remote       *remoteTree
+	files        map[string]client.File // The repository's files by their full path, for ensureFolder and uploadFile.
+	actions      []syncAction
+	// The local files that match the server after the sync, to save in the state.
+	synced map[string]localFile
+}
+
+// Sync a local directory with a repository folder both ways.
+// Changes are found by comparing both sides with the state of the last sync, a file changed on both sides is a conflict:
+// the server's version is downloaded and the local one is kept next to it as a conflicted copy, which is uploaded too.
+func (a *app) sync(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only print what would be changed")
+	force := flags.Bool("force", false, "sync even if the synced folder is missing on the server or most synced files would be deleted locally")
+	err := flags.Parse(args)
+	if err != nil {
+		return errUsage
+	}
+	args = flags.Args()
+	if len(args) < 1 || len(args) > 3 {
+		return errUsage
+	}
+	root, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	state, err := loadSyncState(root)
+	if err != nil {
+		return err
+	}
+
+	s := &syncer{app: a, root: root, actions: []syncAction{}, synced: map[string]localFile{}}
+	switch {
+	case state == nil && len(args) == 1:
+		return fmt.Errorf("%s is not synced yet, pass the repository and the folder to sync it with", root)
+	case state == nil:
+		s.repositoryID, err = a.repositoryID(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if len(args) == 3 {
+			s.folder = cleanPath(args[2])
+		}
+		state = &syncState{Server: a.server, RepositoryID: s.repositoryID, Folder: s.folder, Files: map[string]syncedFile{}}
+	default:
+		s.repositoryID, s.folder = state.RepositoryID, state.Folder
+		if state.Server != a.server {
+			return fmt.Errorf("%s is synced with %s, not %s", root, state.Server, a.server)
+		}
+		if len(args) > 1 {
+			id, err := a.repositoryID(ctx, args[1])
+			if err != nil {
+				return err
+			}
+			folder := ""
+			if len(args) == 3 {
+				folder = cleanPath(args[2])
+			}
+			if id != s.repositoryID || folder != s.folder {
+				return fmt.Errorf("%s is synced with folder %q of repository %d", root, s.folder, s.repositoryID)
+			}
+		}
+	}
+	s.state = state
+
+	err = os.MkdirAll(root, 0755)
+	if err != nil {
+		return err
+	}
+	s.local, err = scanLocal(root, state.Files)
+	if err != nil {
+		return err
+	}
+	repository, err := a.client.Repository(ctx, s.repositoryID)
+	if err != nil {
+		return err
+	}
+	s.files = fileMap(repository.Files)
+	if s.folder != "" {
+		if folder, ok := s.files[s.folder]; !ok || folder.Type != "folder" {
+			// Creating it again would delete every synced file locally.
+			if len(state.Files) > 0 && !*force && !*dryRun {
+				return fmt.Errorf("the synced folder %q is missing in repository %d, it was probably renamed or deleted; "+
+					"run with -force to create it again and delete the synced files locally", s.folder, s.repositoryID)
+			}
+			if *dryRun {
+				s.actions = append(s.actions, syncAction{Action: "mkdir-remote", Path: "", Reason: "the synced folder does not exist"})
+			} else if err = a.ensureFolder(ctx, s.repositoryID, s.folder, s.files); err != nil {
+				return err
+			}
+		}
+	}
+	s.remote = listRemote(repository.Files, s.folder)
+
+	s.plan()
+	if *dryRun {
+		return s.print()
+	}
+	if deletes := s.localDeletes(); !*force && deletes > maxLocalDeletes && deletes*2 > len(state.Files) {
+		return fmt.Errorf("%d of the %d synced files would be deleted locally, run with -dry-run to see the changes "+
+			"and with -force to make them", deletes, len(state.Files))
+	}
+	failed := s.apply(ctx)
+	err = s.saveState(ctx, failed)
+	printErr := s.print()
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		count := 0
+		for _, action := range s.actions {
+			if action.Error != "" {
+				count++
+			}
+		}
+		return fmt.Errorf("%d of %d changes failed", count, len(s.actions))
+	}
+	return printErr
+}
+
+// Find the actions that bring both sides in sync.
+func (s *syncer) plan() {
+	base := s.state.Files
+	done := map[string]bool{}
+	s.planRenames(done)
+
+	paths := map[string]bool{}
+	for rel := range base {
+		paths[rel] = true
+	}
+	for rel := range s.local.Files {
+		paths[rel] = true
+	}
+	for rel := range s.remote.Files {
+		paths[rel] = true
+	}
+	for rel := range s.remote.InProgress {
+		paths[rel] = true
+	}
+	for _, rel := range slices.Sorted(maps.Keys(paths)) {
+		if done[rel] {
+			continue
+		}
+		b, hasB := base[rel]
+		l, hasL := s.local.Files[rel]
+		r, hasR := s.remote.Files[rel]
+		if _, ok := s.remote.InProgress[rel]; ok {
+			// Finish uploads started by an earlier sync, leave the ones of other clients.
+			pending := s.config.pendingUpload(s.server, s.repositoryID, path.Join(s.folder, rel))
+			if hasL && pending != nil && pending.Size == l.Size && pending.ModTime.Equal(l.ModTime) {
+				s.add("upload", rel, "", "resuming an interrupted upload")
+			}
+			continue
+		}
+		localChanged := hasL && (!hasB || l.Hash != b.Hash)
+		remoteChanged := hasR && (!hasB || r.ID != b.FileID || r.UploadDate != b.UploadDate)
+		switch {
+		case hasL && hasR && !localChanged && !remoteChanged:
+			s.synced[rel] = l
+		case hasL && hasR && !remoteChanged:
+			s.add("upload", rel, "", "changed locally")
+		case hasL && hasR && !localChanged:
+			s.add("download", rel, "", "changed on the server")
+		case hasL && hasR:
+			// A file downloaded by fh has the upload date as its modification time.
+			if l.Size == int64(r.Size) && l.ModTime.Unix() == int64(r.UploadDate) {
+				s.synced[rel] = l
+			} else if hasB {
+				s.add("conflict", rel, conflictPath(rel), "changed locally and on the server")
+			} else {
+				s.add("conflict", rel, conflictPath(rel), "created locally and on the server")
+			}
+		case hasL && hasB && !localChanged:
+			s.add("delete-local", rel, "", "deleted on the server")
+		case hasL && hasB:
+			s.add("upload", rel, "", "changed locally and deleted on the server")
+		case hasL:
+			s.add("upload", rel, "", "created locally")
+		case hasR && hasB && !remoteChanged:
+			s.add("delete-remote", rel, "", "deleted locally")
+		case hasR && hasB:
+			s.add("download", rel, "", "deleted locally and changed on the server")
+		case hasR:
+			s.add("download", rel, "", "created on the server")
+		}
+	}
+	s.planFolders()
+	slices.SortStableFunc(s.actions, func(a, b syncAction) int {
+		if a.Action != b.Action {
+			return slices.Index(actionOrder, a.Action) - slices.Index(actionOrder, b.Action)
+		}
+		// Delete the deepest folders first.
+		if strings.HasSuffix(a.Action, "-folder") {
+			return strings.Count(b.Path, "/") - strings.Count(a.Path, "/")
+		}
+		return 0
+	})
+}
+
+// Find files renamed on one side and unchanged on the other, so they are renamed instead of copied again.
+// The server can only rename a file within its folder, files moved to another folder are uploaded again.
+func (s *syncer) planRenames(done map[string]bool) {
+	base := s.state.Files
+	// Local files that are new on both sides, by their hash.
+	created := map[string][]string{}
+	for _, rel := range slices.Sorted(maps.Keys(s.local.Files)) {
+		l := s.local.Files[rel]
+		_, hasB := base[rel]
+		_, hasR := s.remote.Files[rel]
+		if !hasB && !hasR {
+			created[l.Hash] = append(created[l.Hash], rel)
+		}
+	}
+	// Server files that are new on both sides, by their id.
+	remoteByID := map[int]string{}
+	for rel, r := range s.remote.Files {
+		_, hasB := base[rel]
+		_, hasL := s.local.Files[rel]
+		if !hasB && !hasL {
+			remoteByID[r.ID] = rel
+		}
+	}
+
+	for _, rel := range slices.Sorted(maps.Keys(base)) {
+		b := base[rel]
+		l, hasL := s.local.Files[rel]
+		r, hasR := s.remote.Files[rel]
+		remoteUnchanged := hasR && r.ID == b.FileID && r.UploadDate == b.UploadDate
+		// Renamed locally.
+		if !hasL && remoteUnchanged {
+			for i, to := range created[b.Hash] {
+				if parent(to) == parent(rel) && !done[to] {
+					s.add("rename-remote", rel, to, "renamed locally")
+					done[rel], done[to] = true, true
+					created[b.Hash] = slices.Delete(created[b.Hash], i, i+1)
+					break
+				}
+			}
+			continue
+		}
+		// Renamed on the server, which keeps the id of the file.
+		if hasL && l.Hash == b.Hash && !hasR {
+			if to, ok := remoteByID[b.FileID]; ok {
+				s.add("rename-local", rel, to, "renamed on the server")
+				done[rel], done[to] = true, true
+				delete(remoteByID, b.FileID)
+			}
+		}
+	}
+}
+
+// Create the folders that are missing on one side, or delete them if they were deleted on the other
+// and nothing in them is kept.
+func (s *syncer) planFolders() {
+	inBase := map[string]bool{}
+	for _, rel := range s.state.Folders {
+		inBase[rel] = true
+	}
+	deleted := map[string]bool{}
+	for _, action := range s.actions {
+		if action.Action == "delete-local" || action.Action == "delete-remote" {
+			deleted[action.Path] = true
+		}
+	}
+	// Check that every file in a folder is deleted and every folder in it was synced before.
+	emptied := func(folder string, files map[string]bool, folders map[string]bool) bool {
+		prefix := folder + "/"
+		for rel := range files {
+			if strings.HasPrefix(rel, prefix) && !deleted[rel] {
+				return false
+			}
+		}
+		for rel := range folders {
+			if strings.HasPrefix(rel, prefix) && !inBase[rel] {
+				return false
+			}
+		}
+		return true
+	}
+	localFiles := setOf(s.local.Files)
+	remoteFiles := setOf(s.remote.Files)
+	for rel := range s.remote.InProgress {
+		remoteFiles[rel] = true
+	}
+	localFolders := s.local.Folders
+	remoteFolders := setOf(s.remote.Folders)
+
+	// Folders that keep a file being uploaded, downloaded or renamed.
+	needed := map[string]bool{}
+	for _, action := range s.actions {
+		if strings.HasPrefix(action.Action, "delete-") {
+			continue
+		}
+		for _, rel := range []string{action.Path, action.To} {
+			for dir := parent(rel); dir != ""; dir = parent(dir) {
+				needed[dir] = true
+			}
+		}
+	}
+
+	folders := map[string]bool{}
+	for rel := range localFolders {
+		folders[rel] = true
+	}
+	for rel := range remoteFolders {
+		folders[rel] = true
+	}
+	for _, rel := range slices.Sorted(maps.Keys(folders)) {
+		hasL, hasR := localFolders[rel], remoteFolders[rel]
+		switch {
+		case hasL && !hasR && inBase[rel] && !needed[rel] && emptied(rel, localFiles, localFolders):
+			s.add("delete-local-folder", rel, "", "deleted on the server")
+		case hasL && !hasR:
+			s.add("mkdir-remote", rel, "", "created locally")
+		case !hasL && hasR && inBase[rel] && !needed[rel] && emptied(rel, remoteFiles, remoteFolders):
+			s.add("delete-remote-folder", rel, "", "deleted locally")
+		case !hasL && hasR:
+			s.add("mkdir-local", rel, "", "created on the server")
+		}
+	}
+}
+
+// Number of files deleted locally above which a sync that deletes more than half of the synced files needs -force.
+const maxLocalDeletes = 10
+
+// Count the files the plan deletes locally.
+func (s *syncer) localDeletes() int {
+	count := 0
+	for _, action := range s.actions {
+		if action.Action == "delete-local" {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *syncer) add(action, rel, to, reason string) {
+	s.actions = append(s.actions, syncAction{Action: action, Path: rel, To: to, Reason: reason})
+}
+
+// Apply the actions, returning the paths of the ones that failed. An action failing does not stop the others.
+func (s *syncer) apply(ctx context.Context) map[string]bool {
+	failed := map[string]bool{}
+	for i := range s.actions {
+		action := &s.actions[i]
+		if ctx.Err() != nil {
+			action.Error = ctx.Err().Error()
+		} else if err := s.applyAction(ctx, *action); err != nil {
+			action.Error = err.Error()
+		}
+		if action.Error != "" {
+			failed[action.Path] = true
+			if action.To != "" {
+				failed[action.To] = true
+			}
+		}
+	}
+	return failed
+}
+
+func (s *syncer) applyAction(ctx context.Context, action syncAction) error {
+	rel := action.Path
+	remotePath := path.Join(s.folder, rel)
+	localPath := s.localPath(rel)
+	switch action.Action {
+	case "mkdir-remote":
+		return s.ensureFolder(ctx, s.repositoryID, remotePath, s.files)
+	case "mkdir-local":
+		return os.MkdirAll(localPath, 0755)
+	case "upload":
+		return s.uploadTo(ctx, rel, rel)
+	case "download":
+		return s.downloadTo(ctx, s.remote.Files[rel], rel)
+	case "delete-remote":
+		err := s.client.DeleteFile(ctx, s.remote.Files[rel].ID)
+		delete(s.files, remotePath)
+		return err
+	case "delete-local":
+		return os.Remove(localPath)
+	case "rename-remote":
+		err := s.client.RenameFile(ctx, s.remote.Files[rel].ID, path.Base(action.To))
+		if err != nil {
+			return err
+		}
+		s.synced[action.To] = s.local.Files[action.To]
+		return nil
+	case "rename-local":
+		err := os.MkdirAll(filepath.Dir(s.localPath(action.To)), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(localPath, s.localPath(action.To))
+		if err != nil {
+			return err
+		}
+		s.synced[action.To] = s.local.Files[rel]
+		return nil
+	case "conflict":
+		// Keep the local file as a conflicted copy and upload it next to the server's version.
+		err := os.Rename(localPath, s.localPath(action.To))
+		if err != nil {
+			return err
+		}
+		s.local.Files[action.To] = s.local.Files[rel]
+		err = s.downloadTo(ctx, s.remote.Files[rel], rel)
+		if err != nil {
+			return err
+		}
+		return s.uploadTo(ctx, action.To, action.To)
+	case "delete-remote-folder":
+		return s.client.DeleteFolder(ctx, s.remote.Folders[rel].ID)
+	case "delete-local-folder":
+		return os.Remove(localPath)
+	}
+	return errors.New("unknown action " + action.Action)
+}
+
+// Upload a local file, replacing the server's version if there is one.
+// A replaced file is uploaded under a temporary name first and renamed after the old version is deleted,
+// so a failed upload leaves the old version on the server.
+func (s *syncer) uploadTo(ctx context.Context, localRel, remoteRel string) error {
+	remotePath := path.Join(s.folder, remoteRel)
+	err := s.ensureFolder(ctx, s.repositoryID, parent(remotePath), s.files)
+	if err != nil {
+		return err
+	}
+	old, ok := s.remote.Files[remoteRel]
+	if !ok {
+		_, err = s.uploadFile(ctx, s.repositoryID, s.localPath(localRel), remotePath, s.files)
+		if err != nil {
+			return err
+		}
+		s.synced[remoteRel] = s.local.Files[localRel]
+		return nil
+	}
+
+	tempPath := tempUploadPath(remotePath)
+	err = s.removeStaleUpload(ctx, tempPath, s.localPath(localRel))
+	if err != nil {
+		return err
+	}
+	t, err := s.uploadFile(ctx, s.repositoryID, s.localPath(localRel), tempPath, s.files)
+	if err != nil {
+		// An interrupted upload is kept to be resumed by the next sync.
+		return err
+	}
+	err = s.client.DeleteFile(ctx, old.ID)
+	if err != nil && !client.IsStatus(err, http.StatusNotFound) {
+		return err
+	}
+	delete(s.files, remotePath)
+	err = s.client.RenameFile(ctx, t.FileID, path.Base(remotePath))
+	if err != nil {
+		return err
+	}
+	file := s.files[tempPath]
+	file.Path = remotePath
+	s.files[remotePath] = file
+	delete(s.files, tempPath)
+	s.synced[remoteRel] = s.local.Files[localRel]
+	return nil
+}
+
+// Remove a file left at the temporary path of an upload by an earlier sync, unless it is an upload of the same
+// local file that can be resumed.
+func (s *syncer) removeStaleUpload(ctx context.Context, tempPath, localPath string) error {
+	file, ok := s.files[tempPath]
+	if !ok {
+		return nil
+	}
+	var err error
+	if file.UploadDate == 0 {
+		pending := s.config.pendingUpload(s.server, s.repositoryID, tempPath)
+		info, statErr := os.Stat(localPath)
+		if pending != nil && pending.FileID == file.ID && statErr == nil && pending.LocalPath == localPath &&
+			pending.Size == info.Size() && pending.ModTime.Equal(info.ModTime()) {
+			return nil
+		}
+		err = s.client.AbortUpload(ctx, file.ID)
+		s.config.removeUpload(s.server, file.ID)
+	} else {
+		err = s.client.DeleteFile(ctx, file.ID)
+	}
+	if err != nil && !client.IsStatus(err, http.StatusNotFound) {
+		return err
+	}
+	delete(s.files, tempPath)
+	return nil
+}
+
+func (s *syncer) downloadTo(ctx context.Context, file client.File, rel string) error {
+	t, err := s.downloadFile(ctx, file, s.localPath(rel))
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(t.LocalPath)
+	if err != nil {
+		return err
+	}
+	hash, err := hashFile(t.LocalPath)
+	if err != nil {
+		return err
+	}
+	s.synced[rel] = localFile{Size: info.Size(), ModTime: info.ModTime(), Hash: hash}
+	return nil
+}
+
+// Save the files and folders that are the same on both sides, with their ids and upload dates from the server.
+// The paths that failed keep their state from the last sync, so they are tried again.
+func (s *syncer) saveState(ctx context.Context, failed map[string]bool) error {
+	repository, err := s.client.Repository(ctx, s.repositoryID)
+	if err != nil {
+		return err
+	}
+	remote := listRemote(repository.Files, s.folder)
+	state := &syncState{Server: s.server, RepositoryID: s.repositoryID, Folder: s.folder, Files: map[string]syncedFile{}, Folders: []string{}}
+	for rel, l := range s.synced {
+		if r, ok := remote.Files[rel]; ok && !failed[rel] {
+			state.Files[rel] = syncedFile{localFile: l, FileID: r.ID, UploadDate: r.UploadDate}
+		}
+	}
+	for rel := range failed {
+		if b, ok := s.state.Files[rel]; ok {
+			state.Files[rel] = b
+		}
+	}
+	for _, rel := range slices.Sorted(maps.Keys(remote.Folders)) {
+		if info, err := os.Stat(s.localPath(rel)); err == nil && info.IsDir() {
+			state.Folders = append(state.Folders, rel)
+		}
+	}
+	return state.save(s.root)
+}
+
+func (s *syncer) localPath(rel string) string {
+	return filepath.Join(s.root, filepath.FromSlash(rel))
+}
+
+func (s *syncer) print() error {
+	rows := [][]string{}
+	for _, action := range s.actions {
+		detail := action.Reason
+		if action.To != "" {
+			detail = action.To + ", " + detail
+		}
+		if action.Error != "" {
+			detail += ", failed: " + action.Error
+		}
+		rows = append(rows, []string{action.Action, action.Path, detail})
+	}
+	if len(rows) == 0 && !s.json {
+		_, err := fmt.Fprintln(s.out, "Everything is in sync")
+		return err
+	}
+	return s.app.print(s.actions, []string{"ACTION", "PATH", "DETAIL"}, rows)
+}
+
+// Get the temporary path a file is uploaded to before it replaces the file at p, like "folder/.notes.txt.fh-upload".
+func tempUploadPath(p string) string {
+	return path.Join(parent(p), "."+path.Base(p)+tempUploadSuffix)
+}
+
+// Get the path of the conflicted copy of a file, like "notes (conflict 2026-10-19 150405).txt".
+func conflictPath(rel string) string {
+	ext := path.Ext(rel)
+	return strings.TrimSuffix(rel, ext) + " (conflict " + time.Now().Format("2006-01-02 150405") + ")" + ext
+}
+
+func setOf[V any](m map[string]V) map[string]bool {
+	set := make(map[string]bool, len(m))
+	for k := range m {
+		set[k] = true
+	}
+	return set
+}
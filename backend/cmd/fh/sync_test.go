@@ -0,0 +1,278 @@
+package main
+
+import (
+	"backend/client"
+	"slices"
+	"testing"
+	"time"
+)
+
+var modTime = time.Unix(1000, 0)
+
+func local(hash string) localFile {
+	return localFile{Size: 1, ModTime: modTime, Hash: hash}
+}
+
+func synced(hash string, id, date int) syncedFile {
+	return syncedFile{localFile: local(hash), FileID: id, UploadDate: date}
+}
+
+func remoteFile(p string, id, date int) client.File {
+	return client.File{ID: id, Path: p, Type: "file", Size: 1, UploadDate: date}
+}
+
+func remoteFolder(p string, id int) client.File {
+	return client.File{ID: id, Path: p, Type: "folder", UploadDate: 1}
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		// The last sync.
+		base        map[string]syncedFile
+		baseFolders []string
+		// The local directory.
+		local        map[string]localFile
+		localFolders []string
+		// The repository folder.
+		remote []client.File
+		// Uploads started by an earlier run.
+		pending []pendingUpload
+		want    []string
+	}{
+		{
+			name:   "unchanged",
+			base:   map[string]syncedFile{"a": synced("1", 1, 10)},
+			local:  map[string]localFile{"a": local("1")},
+			remote: []client.File{remoteFile("a", 1, 10)},
+			want:   []string{},
+		},
+		{
+			name:   "changed locally",
+			base:   map[string]syncedFile{"a": synced("1", 1, 10)},
+			local:  map[string]localFile{"a": local("2")},
+			remote: []client.File{remoteFile("a", 1, 10)},
+			want:   []string{"upload a"},
+		},
+		{
+			name:   "changed on the server",
+			base:   map[string]syncedFile{"a": synced("1", 1, 10)},
+			local:  map[string]localFile{"a": local("1")},
+			remote: []client.File{remoteFile("a", 2, 20)},
+			want:   []string{"download a"},
+		},
+		{
+			name:   "changed on both sides",
+			base:   map[string]syncedFile{"a": synced("1", 1, 10)},
+			local:  map[string]localFile{"a": local("2")},
+			remote: []client.File{remoteFile("a", 2, 20)},
+			want:   []string{"conflict a"},
+		},
+		{
+			name:   "created on both sides",
+			local:  map[string]localFile{"a": local("1")},
+			remote: []client.File{remoteFile("a", 2, 20)},
+			want:   []string{"conflict a"},
+		},
+		{
+			name:   "downloaded by fh before the state was saved",
+			local:  map[string]localFile{"a": local("1")},
+			remote: []client.File{remoteFile("a", 2, int(modTime.Unix()))},
+			want:   []string{},
+		},
+		{
+			name:  "created locally",
+			local: map[string]localFile{"a": local("1")},
+			want:  []string{"upload a"},
+		},
+		{
+			name:   "created on the server",
+			remote: []client.File{remoteFile("a", 1, 10)},
+			want:   []string{"download a"},
+		},
+		{
+			name:   "deleted locally",
+			base:   map[string]syncedFile{"a": synced("1", 1, 10)},
+			remote: []client.File{remoteFile("a", 1, 10)},
+			want:   []string{"delete-remote a"},
+		},
+		{
+			name:  "deleted on the server",
+			base:  map[string]syncedFile{"a": synced("1", 1, 10)},
+			local: map[string]localFile{"a": local("1")},
+			want:  []string{"delete-local a"},
+		},
+		{
+			name:   "deleted locally and changed on the server",
+			base:   map[string]syncedFile{"a": synced("1", 1, 10)},
+			remote: []client.File{remoteFile("a", 2, 20)},
+			want:   []string{"download a"},
+		},
+		{
+			name:  "changed locally and deleted on the server",
+			base:  map[string]syncedFile{"a": synced("1", 1, 10)},
+			local: map[string]localFile{"a": local("2")},
+			want:  []string{"upload a"},
+		},
+		{
+			name:   "renamed locally",
+			base:   map[string]syncedFile{"a": synced("1", 1, 10)},
+			local:  map[string]localFile{"b": local("1")},
+			remote: []client.File{remoteFile("a", 1, 10)},
+			want:   []string{"rename-remote a b"},
+		},
+		{
+			name:         "moved locally to another folder",
+			base:         map[string]syncedFile{"a": synced("1", 1, 10)},
+			local:        map[string]localFile{"dir/a": local("1")},
+			localFolders: []string{"dir"},
+			remote:       []client.File{remoteFile("a", 1, 10)},
+			want:         []string{"mkdir-remote dir", "upload dir/a", "delete-remote a"},
+		},
+		{
+			name:   "renamed on the server",
+			base:   map[string]syncedFile{"a": synced("1", 1, 10)},
+			local:  map[string]localFile{"a": local("1")},
+			remote: []client.File{remoteFile("b", 1, 10)},
+			want:   []string{"rename-local a b"},
+		},
+		{
+			name:    "interrupted upload",
+			local:   map[string]localFile{"a": local("1")},
+			remote:  []client.File{remoteFile("a", 1, 0)},
+			pending: []pendingUpload{{Server: "https://localhost", FileID: 1, RepositoryID: 1, Path: "a", Size: 1, ModTime: modTime}},
+			want:    []string{"upload a"},
+		},
+		{
+			name:   "upload of another client",
+			local:  map[string]localFile{"a": local("1")},
+			remote: []client.File{remoteFile("a", 1, 0)},
+			want:   []string{},
+		},
+		{
+			name:   "replacing upload of an earlier sync",
+			base:   map[string]syncedFile{"a": synced("1", 1, 10)},
+			local:  map[string]localFile{"a": local("2")},
+			remote: []client.File{remoteFile("a", 1, 10), remoteFile(".a"+tempUploadSuffix, 2, 0)},
+			want:   []string{"upload a"},
+		},
+		{
+			name:        "folder deleted locally",
+			base:        map[string]syncedFile{"dir/a": synced("1", 1, 10)},
+			baseFolders: []string{"dir"},
+			remote:      []client.File{remoteFolder("dir", 2), remoteFile("dir/a", 1, 10)},
+			want:        []string{"delete-remote dir/a", "delete-remote-folder dir"},
+		},
+		{
+			name:        "folder deleted locally with a new file on the server",
+			base:        map[string]syncedFile{"dir/a": synced("1", 1, 10)},
+			baseFolders: []string{"dir"},
+			remote:      []client.File{remoteFolder("dir", 2), remoteFile("dir/a", 1, 10), remoteFile("dir/b", 3, 30)},
+			want:        []string{"mkdir-local dir", "download dir/b", "delete-remote dir/a"},
+		},
+		{
+			name:         "folder deleted on the server",
+			base:         map[string]syncedFile{"dir/a": synced("1", 1, 10)},
+			baseFolders:  []string{"dir"},
+			local:        map[string]localFile{"dir/a": local("1")},
+			localFolders: []string{"dir"},
+			want:         []string{"delete-local dir/a", "delete-local-folder dir"},
+		},
+		{
+			name:         "folder deleted on the server with a new local file",
+			base:         map[string]syncedFile{"dir/a": synced("1", 1, 10)},
+			baseFolders:  []string{"dir"},
+			local:        map[string]localFile{"dir/a": local("1"), "dir/c": local("3")},
+			localFolders: []string{"dir"},
+			want:         []string{"mkdir-remote dir", "upload dir/c", "delete-local dir/a"},
+		},
+		{
+			name:         "nested folders deleted locally",
+			baseFolders:  []string{"dir", "dir/sub"},
+			localFolders: []string{},
+			remote:       []client.File{remoteFolder("dir", 1), remoteFolder("dir/sub", 2)},
+			want:         []string{"delete-remote-folder dir/sub", "delete-remote-folder dir"},
+		},
+		{
+			name:         "folder created on each side",
+			localFolders: []string{"new-local"},
+			remote:       []client.File{remoteFolder("new-remote", 1)},
+			want:         []string{"mkdir-remote new-local", "mkdir-local new-remote"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			base := test.base
+			if base == nil {
+				base = map[string]syncedFile{}
+			}
+			m := &manifest{Files: test.local, Folders: map[string]bool{}}
+			if m.Files == nil {
+				m.Files = map[string]localFile{}
+			}
+			for _, folder := range test.localFolders {
+				m.Folders[folder] = true
+			}
+			s := &syncer{
+				app:          &app{config: &config{Uploads: test.pending}, server: "https://localhost"},
+				repositoryID: 1,
+				state:        &syncState{Server: "https://localhost", RepositoryID: 1, Files: base, Folders: test.baseFolders},
+				local:        m,
+				remote:       listRemote(test.remote, ""),
+				actions:      []syncAction{},
+				synced:       map[string]localFile{},
+			}
+			s.plan()
+
+			got := []string{}
+			for _, action := range s.actions {
+				entry := action.Action + " " + action.Path
+				// The conflicted copy has the time in its name.
+				if action.To != "" && action.Action != "conflict" {
+					entry += " " + action.To
+				}
+				got = append(got, entry)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("got actions %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanKeepsUnchangedFiles(t *testing.T) {
+	s := &syncer{
+		app:     &app{config: &config{}, server: "https://localhost"},
+		state:   &syncState{Files: map[string]syncedFile{"a": synced("1", 1, 10), "b": synced("2", 2, 10)}},
+		local:   &manifest{Files: map[string]localFile{"a": local("1"), "b": local("3")}, Folders: map[string]bool{}},
+		remote:  listRemote([]client.File{remoteFile("a", 1, 10), remoteFile("b", 2, 10)}, ""),
+		actions: []syncAction{},
+		synced:  map[string]localFile{},
+	}
+	s.plan()
+	// Only the files that are the same on both sides are saved in the state without being changed.
+	if len(s.synced) != 1 || s.synced["a"] != local("1") {
+		t.Fatalf("synced %v, want only a", s.synced)
+	}
+}
+
+func TestListRemote(t *testing.T) {
+	files := []client.File{
+		remoteFolder("photos", 1),
+		remoteFile("photos/a.jpg", 2, 10),
+		remoteFile("photos/b.jpg", 3, 0),
+		remoteFile("photos/.c.jpg"+tempUploadSuffix, 4, 10),
+		remoteFolder("photos/2026", 5),
+		remoteFile("other.txt", 6, 10),
+	}
+	tree := listRemote(files, "photos")
+	if len(tree.Files) != 1 || tree.Files["a.jpg"].ID != 2 {
+		t.Fatalf("got files %v, want only a.jpg", tree.Files)
+	}
+	if len(tree.InProgress) != 1 || tree.InProgress["b.jpg"].ID != 3 {
+		t.Fatalf("got uploads in progress %v, want only b.jpg", tree.InProgress)
+	}
+	if len(tree.Folders) != 1 || tree.Folders["2026"].ID != 5 {
+		t.Fatalf("got folders %v, want only 2026", tree.Folders)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"backend/client"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type transfer struct {
+	Path      string `json:"path"`
+	LocalPath string `json:"localPath"`
+	FileID    int    `json:"fileID"`
+	Size      int64  `json:"size"`
+	// "uploaded", "resumed", "downloaded", "skipped" if it already exists with the same size,
+	// "changed" if the local file of an upload to resume changed, or "deleted" if it was deleted on the server.
+	Status string `json:"status"`
+}
+
+// Upload a file or a directory with everything in it into a folder. Files that exist with the same size are skipped,
+// and uploads started by an earlier run are resumed, so an interrupted upload can be run again.
+func (a *app) upload(ctx context.Context, args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errUsage
+	}
+	repositoryID, repository, err := a.getRepository(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	local, err := filepath.Abs(args[1])
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	folder := ""
+	if len(args) == 3 {
+		folder = cleanPath(args[2])
+	}
+	files := fileMap(repository.Files)
+	err = a.ensureFolder(ctx, repositoryID, folder, files)
+	if err != nil {
+		return err
+	}
+
+	transfers := []transfer{}
+	root := path.Join(folder, filepath.Base(local))
+	if !info.IsDir() {
+		var t *transfer
+		t, err = a.uploadFile(ctx, repositoryID, local, root, files)
+		if t != nil {
+			transfers = append(transfers, *t)
+		}
+	} else {
+		err = filepath.WalkDir(local, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(local, p)
+			if err != nil {
+				return err
+			}
+			remote := path.Join(root, filepath.ToSlash(rel))
+			if d.IsDir() {
+				return a.ensureFolder(ctx, repositoryID, remote, files)
+			}
+			// Skip symlinks and other special files.
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			t, err := a.uploadFile(ctx, repositoryID, p, remote, files)
+			if t != nil {
+				transfers = append(transfers, *t)
+			}
+			return err
+		})
+	}
+	printErr := a.printTransfers(transfers)
+	if err != nil {
+		return err
+	}
+	return printErr
+}
+
+// Upload a local file to a path in a repository, resuming its upload if an earlier run started it.
+func (a *app) uploadFile(ctx context.Context, repositoryID int, local, remote string, files map[string]client.File) (*transfer, error) {
+	f, err := os.Open(local)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	t := &transfer{Path: remote, LocalPath: local, Size: info.Size()}
+
+	if existing, ok := files[remote]; ok {
+		if existing.Type == "folder" {
+			return nil, fmt.Errorf("%s is a folder", remote)
+		}
+		t.FileID = existing.ID
+		if existing.UploadDate != 0 {
+			if int64(existing.Size) != info.Size() {
+				return nil, fmt.Errorf("%s already exists with a different size, delete it first", remote)
+			}
+			t.Status = "skipped"
+			return t, nil
+		}
+		pending := a.config.pendingUpload(a.server, repositoryID, remote)
+		if pending == nil || pending.FileID != existing.ID || pending.LocalPath != local || pending.Size != info.Size() || !pending.ModTime.Equal(info.ModTime()) {
+			return nil, fmt.Errorf("%s is being uploaded by someone else or from a file that changed, delete it to upload it again", remote)
+		}
+		a.progress("Resuming", remote, info.Size())
+		err = a.continueUpload(ctx, *pending, f)
+		if err != nil {
+			return nil, err
+		}
+		t.Status = "resumed"
+		return t, nil
+	}
+
+	a.progress("Uploading", remote, info.Size())
+	start, err := a.client.StartUpload(ctx, repositoryID, remote, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("uploading %s: %w", remote, err)
+	}
+	files[remote] = client.File{ID: start.FileID, Path: remote, Type: "file", Size: int(info.Size())}
+	pending := pendingUpload{Server: a.server, FileID: start.FileID, RepositoryID: repositoryID, Path: remote,
+		LocalPath: local, Size: info.Size(), ModTime: info.ModTime()}
+	a.config.Uploads = append(a.config.Uploads, pending)
+	// Save the upload before sending its parts, so it can be resumed even if the program is killed.
+	err = a.config.save()
+	if err != nil {
+		return nil, err
+	}
+	err = a.continueUpload(ctx, pending, f)
+	if err != nil {
+		return nil, err
+	}
+	t.FileID = start.FileID
+	t.Status = "uploaded"
+	return t, nil
+}
+
+func (a *app) continueUpload(ctx context.Context, pending pendingUpload, data io.ReaderAt) error {
+	_, err := a.client.ContinueUpload(ctx, pending.FileID, data, pending.Size)
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w, run \"fh resume\" to finish it", pending.Path, err)
+	}
+	a.config.removeUpload(a.server, pending.FileID)
+	return nil
+}
+
+// Finish the uploads of this server that were interrupted.
+func (a *app) resume(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	transfers := []transfer{}
+	var err error
+	for _, pending := range slices.Clone(a.config.Uploads) {
+		if pending.Server != a.server {
+			continue
+		}
+		t := transfer{Path: pending.Path, LocalPath: pending.LocalPath, FileID: pending.FileID, Size: pending.Size, Status: "resumed"}
+		info, statErr := os.Stat(pending.LocalPath)
+		if statErr != nil || info.Size() != pending.Size || !info.ModTime().Equal(pending.ModTime) {
+			t.Status = "changed"
+			transfers = append(transfers, t)
+			continue
+		}
+		a.progress("Resuming", pending.Path, pending.Size)
+		err = a.resumeFile(ctx, pending)
+		if client.IsStatus(err, http.StatusNotFound) {
+			a.config.removeUpload(a.server, pending.FileID)
+			t.Status, err = "deleted", nil
+		}
+		if err != nil {
+			break
+		}
+		transfers = append(transfers, t)
+	}
+	printErr := a.printTransfers(transfers)
+	if err != nil {
+		return err
+	}
+	return printErr
+}
+
+func (a *app) resumeFile(ctx context.Context, pending pendingUpload) error {
+	f, err := os.Open(pending.LocalPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return a.continueUpload(ctx, pending, f)
+}
+
+// Download a file, or a folder with everything in it, to a local path.
+// A folder is downloaded into a directory with its name, or into local if it is given.
+func (a *app) download(ctx context.Context, args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errUsage
+	}
+	repositoryID, repository, err := a.getRepository(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	remote := cleanPath(args[1])
+	local := ""
+	if len(args) == 3 {
+		local = args[2]
+	}
+	file := findFile(repository.Files, remote)
+	if remote != "" && file == nil {
+		return fmt.Errorf("no file or folder %q in repository %d", remote, repositoryID)
+	}
+
+	transfers := []transfer{}
+	if file != nil && file.Type == "file" {
+		if local == "" {
+			local = path.Base(remote)
+		} else if info, err := os.Stat(local); err == nil && info.IsDir() {
+			local = filepath.Join(local, path.Base(remote))
+		}
+		var t *transfer
+		t, err = a.downloadFile(ctx, *file, local)
+		if t != nil {
+			transfers = append(transfers, *t)
+		}
+	} else {
+		if local == "" {
+			local = path.Base(remote)
+			if remote == "" {
+				local = repository.Name
+			}
+		}
+		err = a.downloadFolder(ctx, repository.Files, remote, local, &transfers)
+	}
+	printErr := a.printTransfers(transfers)
+	if err != nil {
+		return err
+	}
+	return printErr
+}
+
+func (a *app) downloadFolder(ctx context.Context, files []client.File, folder, local string, transfers *[]transfer) error {
+	err := os.MkdirAll(local, 0755)
+	if err != nil {
+		return err
+	}
+	prefix := ""
+	if folder != "" {
+		prefix = folder + "/"
+	}
+	files = slices.Clone(files)
+	slices.SortFunc(files, func(a, b client.File) int { return strings.Compare(a.Path, b.Path) })
+	for _, file := range files {
+		rel, ok := strings.CutPrefix(file.Path, prefix)
+		if !ok {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return fmt.Errorf("%s is not a valid local path", file.Path)
+		}
+		localPath := filepath.Join(local, filepath.FromSlash(rel))
+		if file.Type == "folder" {
+			err = os.MkdirAll(localPath, 0755)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		// Files that are still being uploaded are left out.
+		if file.UploadDate == 0 {
+			continue
+		}
+		t, err := a.downloadFile(ctx, file, localPath)
+		if err != nil {
+			return err
+		}
+		*transfers = append(*transfers, *t)
+	}
+	return nil
+}
+
+func (a *app) downloadFile(ctx context.Context, file client.File, local string) (*transfer, error) {
+	if file.UploadDate == 0 {
+		return nil, fmt.Errorf("%s is still being uploaded", file.Path)
+	}
+	err := os.MkdirAll(filepath.Dir(local), 0755)
+	if err != nil {
+		return nil, err
+	}
+	a.progress("Downloading", file.Path, int64(file.Size))
+	// Write to another file first, so an interrupted download does not leave a partial file.
+	temp := local + ".fh-download"
+	f, err := os.Create(temp)
+	if err != nil {
+		return nil, err
+	}
+	size, err := a.client.Download(ctx, file.ID, f)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp, local)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return nil, fmt.Errorf("downloading %s: %w", file.Path, err)
+	}
+	// Keep the upload date as the modification time.
+	date := time.Unix(int64(file.UploadDate), 0)
+	os.Chtimes(local, date, date)
+	return &transfer{Path: file.Path, LocalPath: local, FileID: file.ID, Size: size, Status: "downloaded"}, nil
+}
+
+// Tell what is being transferred on stderr, unless the output is JSON.
+func (a *app) progress(action, remote string, size int64) {
+	if !a.json {
+		fmt.Fprintf(os.Stderr, "%s %s (%s)\n", action, remote, formatSize(size))
+	}
+}
+
+func (a *app) printTransfers(transfers []transfer) error {
+	rows := [][]string{}
+	for _, t := range transfers {
+		rows = append(rows, []string{t.Status, t.Path, t.LocalPath, formatSize(t.Size)})
+	}
+	return a.print(transfers, []string{"STATUS", "PATH", "LOCAL", "SIZE"}, rows)
+}
@@ -0,0 +1,46 @@
+package admin
+
+import (
+	db "backend/database"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Unlock a username locked after too many failed login attempts and reset its attempts from every ip.
+func DeleteAccountLockout(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	// Get a connection from the database.
+	ctx := r.Context()
+	conn, err := db.GetConnection(ctx)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	defer conn.Release()
+
+	// Delete the lockout from the database.
+	var deleted int64
+	err = txutil.Run(ctx, conn, "admin.DeleteAccountLockout", func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, "DELETE FROM account_lockout_ WHERE username_ = LOWER($1)", username)
+		deleted = tag.RowsAffected()
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	if deleted == 0 {
+		errorutil.Status(w, r, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
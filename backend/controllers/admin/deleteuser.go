@@ -4,148 +4,87 @@ import (
 	db "backend/database"
 	"backend/storage"
 	"backend/types"
-	"context"
-	"errors"
-	"fmt"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // Delete a user as an admin and delete files in their repositories.
 func DeleteUser(w http.ResponseWriter, r *http.Request) {
 	deleteID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
+		logutil.LogError(r.Context(), err)
+		errorutil.Invalid(w, r, "id", "must be a number")
 		return
 	}
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-	// If commit is not run first this will rollback the transaction.
-	defer tx.Rollback(ctx)
-
+	defer conn.Release()
 	// Get all files the user has and all files in the user's repositories to delete them from s3.
-	rows, err := tx.Query(ctx, `SELECT file_.id_, file_.upload_date_, file_.upload_id_ FROM file_ JOIN repository_ ON file_.repository_id_ = repository_.id_ 
-	WHERE (repository_.user_id_ = @userID OR file_.user_id_ = @userID) AND file_.type_ = 'file'::file_type_enum_`, pgx.NamedArgs{"userID": deleteID})
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	// Scan the rows into two arrays for deletion.
 	uploadedFiles := []types.UploadedFile{}
 	inProgressFiles := []types.InProgressFile{}
-	for rows.Next() {
-		file := types.FileData{}
-		err = rows.Scan(&file.ID, &file.Date, &file.UploadID)
+	err = txutil.RunReadOnly(ctx, conn, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `SELECT file_.id_, file_.upload_date_, file_.upload_id_ FROM file_ JOIN repository_ ON file_.repository_id_ = repository_.id_ 
+	WHERE (repository_.user_id_ = @userID OR file_.user_id_ = @userID) AND file_.type_ = 'file'::file_type_enum_`, pgx.NamedArgs{"userID": deleteID})
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
-		if file.Date == nil {
-			inProgressFiles = append(inProgressFiles, types.InProgressFile{ID: strconv.Itoa(file.ID), UploadID: file.UploadID})
-		} else {
-			uploadedFiles = append(uploadedFiles, types.UploadedFile{ID: strconv.Itoa(file.ID)})
+		// Scan the rows into two arrays for deletion.
+		for rows.Next() {
+			file := types.FileData{}
+			err = rows.Scan(&file.ID, &file.Date, &file.UploadID)
+			if err != nil {
+				return err
+			}
+			if file.Date == nil {
+				inProgressFiles = append(inProgressFiles, types.InProgressFile{ID: strconv.Itoa(file.ID), UploadID: file.UploadID})
+			} else {
+				uploadedFiles = append(uploadedFiles, types.UploadedFile{ID: strconv.Itoa(file.ID)})
+			}
 		}
-	}
-	if rows.Err() != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	err = tx.Commit(ctx)
+		return rows.Err()
+	})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	// Delete files from s3.
 	err = storage.DeleteAllFiles(ctx, uploadedFiles, inProgressFiles)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
-	// Retry the transaction on serialization failure.
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	// Finish deleting the user even if the admin disconnects, their files are already gone from s3.
+	ctx, cancel := txutil.Detach(ctx)
+	defer cancel()
+	err = txutil.Run(ctx, conn, "admin.DeleteUser", func(tx pgx.Tx) error {
 		// Delete the user's files (not folders) that are not ON DELETE CASCADE from the database.
-		_, err = tx.Exec(ctx, "DELETE FROM file_ WHERE user_id_ = $1 AND type_ = 'file'::file_type_enum_", deleteID)
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
+		_, err := tx.Exec(ctx, "DELETE FROM file_ WHERE user_id_ = $1 AND type_ = 'file'::file_type_enum_", deleteID)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 		// Delete the user from the database.
 		_, err = tx.Exec(ctx, "DELETE FROM user_ WHERE id_ = $1", deleteID)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
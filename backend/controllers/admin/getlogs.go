@@ -0,0 +1,129 @@
+package admin
+
+import (
+	logdb "backend/logdatabase"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type logsResponse struct {
+	Logs []logEntry `json:"logs"`
+	// Pass it as the before query parameter to get the next page, null on the last page.
+	Next *int `json:"next"`
+}
+
+type logEntry struct {
+	ID            int       `json:"id"`
+	Date          time.Time `json:"date"`
+	IP            string    `json:"ip"`
+	UserID        int       `json:"userID"` // 0 if the request was not authenticated.
+	Username      *string   `json:"username"`
+	ExecutionTime float32   `json:"executionTime"` // In milliseconds.
+	Endpoint      string    `json:"endpoint"`
+	Route         *string   `json:"route"`
+	Method        string    `json:"method"`
+	Status        int       `json:"status"`
+	Bytes         *int64    `json:"bytes"`
+	RequestID     *string   `json:"requestID"`
+	UserAgent     *string   `json:"userAgent"`
+	ErrorCode     *string   `json:"errorCode"`
+	ErrorMessage  *string   `json:"errorMessage"`
+}
+
+const logColumns = `id_, date_, ip_, user_id_, username_, time_, endpoint_, route_, method_, status_, bytes_,
+	request_id_, user_agent_, error_code_, error_message_`
+
+func scanLog(rows pgx.Rows) (logEntry, error) {
+	log := logEntry{}
+	err := rows.Scan(&log.ID, &log.Date, &log.IP, &log.UserID, &log.Username, &log.ExecutionTime, &log.Endpoint, &log.Route,
+		&log.Method, &log.Status, &log.Bytes, &log.RequestID, &log.UserAgent, &log.ErrorCode, &log.ErrorMessage)
+	return log, err
+}
+
+// Get a page of request logs matching the filters of parseLogFilter, newest first.
+// Query parameters: before - only return logs with a smaller id, limit - page size (100 by default, up to 1000).
+func GetLogs(w http.ResponseWriter, r *http.Request) {
+	// Logging to the log database is optional.
+	if logdb.Pool == nil {
+		errorutil.Status(w, r, http.StatusServiceUnavailable)
+		return
+	}
+	filter, err := parseLogFilter(r)
+	var filterErr filterError
+	if errors.As(err, &filterErr) {
+		errorutil.Invalid(w, r, filterErr.Field, filterErr.Reason)
+		return
+	}
+	limit := 100
+	if limitString := r.URL.Query().Get("limit"); limitString != "" {
+		limit, err = strconv.Atoi(limitString)
+		if err != nil || limit < 1 || limit > 1000 {
+			errorutil.Invalid(w, r, "limit", "must be a number from 1 to 1000")
+			return
+		}
+	}
+	var before *int
+	if beforeString := r.URL.Query().Get("before"); beforeString != "" {
+		id, err := strconv.Atoi(beforeString)
+		if err != nil {
+			errorutil.Invalid(w, r, "before", "must be a number")
+			return
+		}
+		before = &id
+	}
+
+	// Get a connection from the log database.
+	ctx := r.Context()
+	conn, err := logdb.GetConnection(ctx)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	defer conn.Release()
+
+	// Get one more log than the limit to know if there is a next page.
+	args := filter.args()
+	args["before"] = before
+	args["limit"] = limit + 1
+	rows, err := conn.Query(ctx, `SELECT `+logColumns+` FROM log_ WHERE `+logFilterWhere+`
+	AND (@before::INT IS NULL OR id_ < @before) ORDER BY id_ DESC LIMIT @limit`, args)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	// Scan the rows into an array.
+	res := logsResponse{Logs: []logEntry{}}
+	for rows.Next() {
+		log, err := scanLog(rows)
+		if err != nil {
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
+			return
+		}
+		res.Logs = append(res.Logs, log)
+	}
+	if rows.Err() != nil {
+		logutil.LogError(r.Context(), rows.Err())
+		errorutil.Error(w, r, rows.Err())
+		return
+	}
+	if len(res.Logs) > limit {
+		res.Logs = res.Logs[:limit]
+		res.Next = &res.Logs[limit-1].ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
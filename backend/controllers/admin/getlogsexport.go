@@ -0,0 +1,126 @@
+package admin
+
+import (
+	logdb "backend/logdatabase"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export request logs matching the filters of parseLogFilter, newest first.
+// Query parameters: format - csv or ndjson, limit - max number of logs (10000 by default, up to 1000000).
+// The logs are streamed, so an error after the first row only ends the response early.
+func GetLogsExport(w http.ResponseWriter, r *http.Request) {
+	// Logging to the log database is optional.
+	if logdb.Pool == nil {
+		errorutil.Status(w, r, http.StatusServiceUnavailable)
+		return
+	}
+	filter, err := parseLogFilter(r)
+	var filterErr filterError
+	if errors.As(err, &filterErr) {
+		errorutil.Invalid(w, r, filterErr.Field, filterErr.Reason)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "csv" && format != "ndjson" {
+		errorutil.Invalid(w, r, "format", "must be csv or ndjson")
+		return
+	}
+	limit := 10000
+	if limitString := r.URL.Query().Get("limit"); limitString != "" {
+		limit, err = strconv.Atoi(limitString)
+		if err != nil || limit < 1 || limit > 1000000 {
+			errorutil.Invalid(w, r, "limit", "must be a number from 1 to 1000000")
+			return
+		}
+	}
+
+	// Get a connection from the log database, an export can take longer than other requests.
+	ctx := r.Context()
+	conn, err := logdb.GetConnection(ctx)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	defer conn.Release()
+
+	args := filter.args()
+	args["limit"] = limit
+	rows, err := conn.Query(ctx, `SELECT `+logColumns+` FROM log_ WHERE `+logFilterWhere+` ORDER BY id_ DESC LIMIT @limit`, args)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	filename := "logs-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	var writeLog func(log logEntry) error
+	var csvWriter *csv.Writer
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		csvWriter = csv.NewWriter(w)
+		defer csvWriter.Flush()
+		csvWriter.Write([]string{"id", "date", "ip", "userID", "username", "executionTime", "endpoint", "route", "method",
+			"status", "bytes", "requestID", "userAgent", "errorCode", "errorMessage"})
+		writeLog = func(log logEntry) error {
+			bytes := ""
+			if log.Bytes != nil {
+				bytes = strconv.FormatInt(*log.Bytes, 10)
+			}
+			return csvWriter.Write([]string{strconv.Itoa(log.ID), log.Date.UTC().Format(time.RFC3339Nano), log.IP,
+				strconv.Itoa(log.UserID), csvText(stringOrEmpty(log.Username)), strconv.FormatFloat(float64(log.ExecutionTime), 'f', -1, 32),
+				csvText(log.Endpoint), csvText(stringOrEmpty(log.Route)), csvText(log.Method), strconv.Itoa(log.Status), bytes,
+				csvText(stringOrEmpty(log.RequestID)), csvText(stringOrEmpty(log.UserAgent)), csvText(stringOrEmpty(log.ErrorCode)),
+				csvText(stringOrEmpty(log.ErrorMessage))})
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		writeLog = func(log logEntry) error {
+			return encoder.Encode(log)
+		}
+	}
+
+	for rows.Next() {
+		log, err := scanLog(rows)
+		if err != nil {
+			logutil.LogError(r.Context(), err)
+			return
+		}
+		if err = writeLog(log); err != nil {
+			// The client most likely disconnected.
+			return
+		}
+	}
+	if rows.Err() != nil {
+		logutil.LogError(r.Context(), rows.Err())
+	}
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Make a cell with text from a request safe to open in a spreadsheet, which runs cells starting with
+// one of "=+-@" (or a tab or carriage return before them) as formulas. Such cells are prefixed with a quote.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,26 @@
+package admin
+
+import "testing"
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"alice", "alice"},
+		{"/api/user", "/api/user"},
+		{"Mozilla/5.0 (X11; Linux x86_64)", "Mozilla/5.0 (X11; Linux x86_64)"},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1+1", "'+1+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"a=1", "a=1"},
+	}
+	for _, test := range tests {
+		if got := csvText(test.in); got != test.want {
+			t.Errorf("csvText(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
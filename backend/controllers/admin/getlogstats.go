@@ -0,0 +1,187 @@
+package admin
+
+import (
+	logdb "backend/logdatabase"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type logStatsResponse struct {
+	From      time.Time         `json:"from"`
+	Latency   []endpointLatency `json:"latency"`
+	ErrorRate []errorRate       `json:"errorRate"`
+	TopUsers  []topUser         `json:"topUsers"`
+	TopIPs    []topIP           `json:"topIPs"`
+}
+
+// Latency percentiles in milliseconds, grouped by route pattern so that paths with ids are counted together.
+type endpointLatency struct {
+	Endpoint string  `json:"endpoint"`
+	Method   string  `json:"method"`
+	Requests int     `json:"requests"`
+	P50      float64 `json:"p50"`
+	P95      float64 `json:"p95"`
+	P99      float64 `json:"p99"`
+}
+
+type errorRate struct {
+	Date         time.Time `json:"date"` // Start of the interval.
+	Requests     int       `json:"requests"`
+	ClientErrors int       `json:"clientErrors"` // 4xx responses.
+	ServerErrors int       `json:"serverErrors"` // 5xx responses.
+	Rate         float64   `json:"rate"`         // Server errors divided by requests.
+}
+
+type topUser struct {
+	UserID   int     `json:"userID"`
+	Username *string `json:"username"`
+	Requests int     `json:"requests"`
+}
+
+type topIP struct {
+	IP       string `json:"ip"`
+	Requests int    `json:"requests"`
+}
+
+// Get aggregated views of the request logs matching the filters of parseLogFilter, from the last 24 hours if from is not set.
+// Query parameters: interval - minute, hour (default) or day for the error rate, top - number of top users and ips (10 by default, up to 100).
+func GetLogStats(w http.ResponseWriter, r *http.Request) {
+	// Logging to the log database is optional.
+	if logdb.Pool == nil {
+		errorutil.Status(w, r, http.StatusServiceUnavailable)
+		return
+	}
+	filter, err := parseLogFilter(r)
+	var filterErr filterError
+	if errors.As(err, &filterErr) {
+		errorutil.Invalid(w, r, filterErr.Field, filterErr.Reason)
+		return
+	}
+	if filter.From == nil {
+		from := time.Now().Add(-time.Hour * 24).Truncate(time.Second)
+		filter.From = &from
+	}
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "hour"
+	}
+	if interval != "minute" && interval != "hour" && interval != "day" {
+		errorutil.Invalid(w, r, "interval", "must be minute, hour or day")
+		return
+	}
+	top := 10
+	if topString := r.URL.Query().Get("top"); topString != "" {
+		top, err = strconv.Atoi(topString)
+		if err != nil || top < 1 || top > 100 {
+			errorutil.Invalid(w, r, "top", "must be a number from 1 to 100")
+			return
+		}
+	}
+
+	// Get a connection from the log database and start a transaction to compute every view from the same logs.
+	ctx := r.Context()
+	conn, err := logdb.GetConnection(ctx)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	defer conn.Release()
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	// If commit is not run first this will rollback the transaction.
+	defer tx.Rollback(ctx)
+
+	args := filter.args()
+	args["interval"] = interval
+	args["top"] = top
+	res := logStatsResponse{From: *filter.From, Latency: []endpointLatency{}, ErrorRate: []errorRate{}, TopUsers: []topUser{}, TopIPs: []topIP{}}
+
+	// Latency percentiles of the 100 most requested endpoints.
+	rows, err := tx.Query(ctx, `SELECT COALESCE(route_, endpoint_), method_, COUNT(*),
+	PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY time_), PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY time_),
+	PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY time_)
+	FROM log_ WHERE `+logFilterWhere+` GROUP BY 1, 2 ORDER BY 3 DESC LIMIT 100`, args)
+	if err == nil {
+		res.Latency, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (endpointLatency, error) {
+			latency := endpointLatency{}
+			err := row.Scan(&latency.Endpoint, &latency.Method, &latency.Requests, &latency.P50, &latency.P95, &latency.P99)
+			return latency, err
+		})
+	}
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+
+	// Requests and errors per interval.
+	rows, err = tx.Query(ctx, `SELECT DATE_TRUNC(@interval, date_) AS interval_, COUNT(*),
+	COUNT(*) FILTER (WHERE status_ BETWEEN 400 AND 499), COUNT(*) FILTER (WHERE status_ >= 500)
+	FROM log_ WHERE `+logFilterWhere+` GROUP BY interval_ ORDER BY interval_`, args)
+	if err == nil {
+		res.ErrorRate, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (errorRate, error) {
+			rate := errorRate{}
+			err := row.Scan(&rate.Date, &rate.Requests, &rate.ClientErrors, &rate.ServerErrors)
+			rate.Rate = float64(rate.ServerErrors) / float64(rate.Requests)
+			return rate, err
+		})
+	}
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+
+	// Users with the most requests, not counting requests without a logged in user.
+	rows, err = tx.Query(ctx, `SELECT user_id_, MAX(username_), COUNT(*) FROM log_
+	WHERE `+logFilterWhere+` AND user_id_ <> 0 GROUP BY user_id_ ORDER BY 3 DESC LIMIT @top`, args)
+	if err == nil {
+		res.TopUsers, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (topUser, error) {
+			user := topUser{}
+			err := row.Scan(&user.UserID, &user.Username, &user.Requests)
+			return user, err
+		})
+	}
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+
+	// IPs with the most requests.
+	rows, err = tx.Query(ctx, `SELECT ip_, COUNT(*) FROM log_ WHERE `+logFilterWhere+` GROUP BY ip_ ORDER BY 2 DESC LIMIT @top`, args)
+	if err == nil {
+		res.TopIPs, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (topIP, error) {
+			ip := topIP{}
+			err := row.Scan(&ip.IP, &ip.Requests)
+			return ip, err
+		})
+	}
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
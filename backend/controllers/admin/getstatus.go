@@ -0,0 +1,85 @@
+package admin
+
+import (
+	db "backend/database"
+	logdb "backend/logdatabase"
+	"backend/util/healthutil"
+	"backend/util/logutil"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+type statusResponse struct {
+	Status       string                  `json:"status"` // "ok" or "unavailable".
+	Uptime       float64                 `json:"uptime"` // In seconds.
+	Versions     versions                `json:"versions"`
+	Schema       schemaStatus            `json:"schema"`
+	Dependencies []healthutil.Dependency `json:"dependencies"`
+}
+
+type versions struct {
+	Go          string  `json:"go"`
+	Revision    *string `json:"revision"` // The VCS revision the backend was built from, if it was built in a repository.
+	Modified    bool    `json:"modified"` // The build had uncommitted changes.
+	Database    *string `json:"database"`
+	LogDatabase *string `json:"logDatabase"`
+}
+
+type schemaStatus struct {
+	Version        int  `json:"version"`        // The schema version of this backend.
+	AppliedVersion *int `json:"appliedVersion"` // The highest version applied to the database by any instance.
+}
+
+var started = time.Now()
+
+// Get the detailed status of the backend and its dependencies as an admin.
+func GetStatus(w http.ResponseWriter, r *http.Request) {
+	dependencies, ok := healthutil.Check(r.Context())
+	res := statusResponse{Status: "ok", Uptime: time.Since(started).Seconds(), Dependencies: dependencies,
+		Versions: versions{Go: runtime.Version()}, Schema: schemaStatus{Version: db.SchemaVersion}}
+	if !ok {
+		res.Status = "unavailable"
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				res.Versions.Revision = &setting.Value
+			}
+			if setting.Key == "vcs.modified" {
+				res.Versions.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	// The versions are only read from the databases that respond.
+	ctx := r.Context()
+	if dependencies[0].Status == "ok" {
+		conn, err := db.GetConnection(ctx)
+		if err == nil {
+			err = conn.QueryRow(ctx, "SELECT CURRENT_SETTING('server_version'), (SELECT version_ FROM schema_version_)").
+				Scan(&res.Versions.Database, &res.Schema.AppliedVersion)
+			conn.Release()
+		}
+		if err != nil {
+			logutil.LogError(r.Context(), err)
+		}
+	}
+	if dependencies[1].Status == "ok" {
+		conn, err := logdb.GetConnection(ctx)
+		if err == nil {
+			err = conn.QueryRow(ctx, "SELECT CURRENT_SETTING('server_version')").Scan(&res.Versions.LogDatabase)
+			conn.Release()
+		}
+		if err != nil {
+			logutil.LogError(r.Context(), err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
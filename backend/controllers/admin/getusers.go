@@ -2,11 +2,10 @@ package admin
 
 import (
 	db "backend/database"
-	"context"
+	"backend/util/errorutil"
+	"backend/util/logutil"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
@@ -28,19 +27,18 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 	search := chi.URLParam(r, "username")
 
 	// Get a connection from the database and start a transaction.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	// If commit is not run first this will rollback the transaction.
@@ -49,8 +47,8 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 	// Get the users.
 	rows, err := tx.Query(ctx, "SELECT id_, username_, role_, space_ FROM user_ WHERE LOWER(username_) LIKE '%' || LOWER($1) || '%' LIMIT 10", search)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -60,21 +58,21 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 		user := user{}
 		err = rows.Scan(&user.ID, &user.Username, &user.Role, &user.Space)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
 			return
 		}
 		userArr.Users = append(userArr.Users, user)
 	}
 	if rows.Err() != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	err = tx.Commit(ctx)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"backend/util/logutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Filters shared by the request log endpoints, every one of them is optional.
+// Query parameters: from, to - RFC 3339 time range, userID, ip - the client's ip or its anonymized form saved in the log,
+// endpoint - the request path or route pattern, method, status - a status code or a class like "5xx".
+type logFilter struct {
+	From      *time.Time
+	To        *time.Time
+	UserID    *int
+	IP        *string
+	LoggedIP  *string // IP anonymized like the saved logs, see LOG_IP_MODE.
+	Endpoint  *string
+	Method    *string
+	StatusMin *int
+	StatusMax *int
+}
+
+// A WHERE clause for the log_ table using the named arguments of logFilter.args.
+const logFilterWhere = `(@from::TIMESTAMPTZ IS NULL OR date_ >= @from) AND (@to::TIMESTAMPTZ IS NULL OR date_ < @to)
+	AND (@userID::INT IS NULL OR user_id_ = @userID) AND (@ip::TEXT IS NULL OR ip_ = @ip OR ip_ = @loggedIP)
+	AND (@endpoint::TEXT IS NULL OR endpoint_ = @endpoint OR route_ = @endpoint) AND (@method::TEXT IS NULL OR method_ = @method)
+	AND (@statusMin::INT IS NULL OR status_ >= @statusMin) AND (@statusMax::INT IS NULL OR status_ <= @statusMax)`
+
+// Returned by parseLogFilter for a query parameter that cannot be parsed.
+type filterError struct {
+	Field  string
+	Reason string
+}
+
+func (err filterError) Error() string {
+	return "invalid log filter " + err.Field + ": " + err.Reason
+}
+
+func parseLogFilter(r *http.Request) (logFilter, error) {
+	query := r.URL.Query()
+	filter := logFilter{}
+	for key, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(key); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, filterError{key, "must be an RFC 3339 time"}
+			}
+			*dst = &t
+		}
+	}
+	if value := query.Get("userID"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, filterError{"userID", "must be a number"}
+		}
+		filter.UserID = &id
+	}
+	if value := query.Get("ip"); value != "" {
+		filter.IP = &value
+		loggedIP := logutil.AnonymizeIP(value)
+		filter.LoggedIP = &loggedIP
+	}
+	if value := query.Get("endpoint"); value != "" {
+		filter.Endpoint = &value
+	}
+	if value := query.Get("method"); value != "" {
+		method := strings.ToUpper(value)
+		filter.Method = &method
+	}
+	if value := query.Get("status"); value != "" {
+		// A class of status codes, for example "4xx" is 400 to 499.
+		if len(value) == 3 && strings.HasSuffix(strings.ToLower(value), "xx") && value[0] >= '1' && value[0] <= '5' {
+			statusMin := int(value[0]-'0') * 100
+			statusMax := statusMin + 99
+			filter.StatusMin, filter.StatusMax = &statusMin, &statusMax
+		} else {
+			status, err := strconv.Atoi(value)
+			if err != nil {
+				return filter, filterError{"status", "must be a status code or a class like 5xx"}
+			}
+			filter.StatusMin, filter.StatusMax = &status, &status
+		}
+	}
+	return filter, nil
+}
+
+func (filter logFilter) args() pgx.NamedArgs {
+	return pgx.NamedArgs{"from": filter.From, "to": filter.To, "userID": filter.UserID, "ip": filter.IP, "loggedIP": filter.LoggedIP,
+		"endpoint": filter.Endpoint, "method": filter.Method, "statusMin": filter.StatusMin, "statusMax": filter.StatusMax}
+}
@@ -0,0 +1,52 @@
+package admin
+
+import (
+	c "backend/util/config"
+	"backend/util/logutil"
+	"net/http/httptest"
+	"testing"
+)
+
+// The ip of a log is anonymized when it is written, the filter has to find it by the client's real ip.
+func TestLogFilterIP(t *testing.T) {
+	oldMode, oldKey := c.Config.LogIPMode, c.Config.LogIPHashKey
+	t.Cleanup(func() { c.Config.LogIPMode, c.Config.LogIPHashKey = oldMode, oldKey })
+	c.Config.LogIPHashKey = "secret"
+
+	tests := []struct {
+		mode, logged string
+	}{
+		{"full", "203.0.113.5"},
+		{"truncate", "203.0.113.0"},
+		{"hash", "c3565eede5987d19bbfb6f55d9e95d83"},
+	}
+	for _, test := range tests {
+		c.Config.LogIPMode = test.mode
+		if written := logutil.AnonymizeIP("203.0.113.5"); written != test.logged {
+			t.Fatalf("%s: ip written as %q, want %q", test.mode, written, test.logged)
+		}
+
+		filter, err := parseLogFilter(httptest.NewRequest("GET", "/api/admin/logs?ip=203.0.113.5", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		args := filter.args()
+		if *args["ip"].(*string) != "203.0.113.5" || *args["loggedIP"].(*string) != test.logged {
+			t.Fatalf("%s: filtering by ip %q or %q, want the written %q", test.mode, *filter.IP, *filter.LoggedIP, test.logged)
+		}
+
+		// The anonymized ip shown in the logs can be searched for too.
+		filter, err = parseLogFilter(httptest.NewRequest("GET", "/api/admin/logs?ip="+test.logged, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *filter.IP != test.logged {
+			t.Fatalf("%s: filtering by ip %q, want %q", test.mode, *filter.IP, test.logged)
+		}
+	}
+
+	filter, err := parseLogFilter(httptest.NewRequest("GET", "/api/admin/logs", nil))
+	if err != nil || filter.IP != nil || filter.LoggedIP != nil {
+		t.Fatal("filtering by ip without the ip parameter")
+	}
+}
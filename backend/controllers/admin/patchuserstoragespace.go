@@ -2,17 +2,12 @@ package admin
 
 import (
 	db "backend/database"
-	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"net/http"
-	"time"
 
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type userStorage struct {
@@ -22,67 +17,28 @@ type userStorage struct {
 
 func PatchUserStorageSpace(w http.ResponseWriter, r *http.Request) {
 	uStorage := userStorage{}
-	err := json.NewDecoder(io.LimitReader(r.Body, 1000)).Decode(&uStorage)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 1000, &uStorage) {
 		return
 	}
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 
-	// Retry the transaction on serialization failure.
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	err = txutil.Run(ctx, conn, "admin.PatchUserStorageSpace", func(tx pgx.Tx) error {
 		// Change user's storage space in the database.
-		_, err = tx.Exec(ctx, "UPDATE user_ SET space_ = $1 WHERE id_ = $2", uStorage.Amount, uStorage.ID)
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		_, err := tx.Exec(ctx, "UPDATE user_ SET space_ = $1 WHERE id_ = $2", uStorage.Amount, uStorage.ID)
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
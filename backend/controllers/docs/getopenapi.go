@@ -0,0 +1,14 @@
+package docs
+
+import (
+	"backend/openapi"
+	"net/http"
+)
+
+// Get the OpenAPI document describing every route of the API.
+func GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.JSON)
+}
@@ -2,20 +2,16 @@ package file
 
 import (
 	db "backend/database"
-	"backend/database/errorcodes"
 	"backend/storage"
 	"backend/types"
-	"context"
-	"errors"
-	"fmt"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func DeleteFolder(w http.ResponseWriter, r *http.Request) {
@@ -24,157 +20,86 @@ func DeleteFolder(w http.ResponseWriter, r *http.Request) {
 	// Check if the id to delete is a number.
 	id, err := strconv.Atoi(idString)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "id", "must be a number")
 		return
 	}
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	// If commit is not run first this will rollback the transaction.
-	defer tx.Rollback(ctx)
-
-	// Check if the user can delete this file.
-	_, err = tx.Exec(ctx, "CALL check_permission_modify_file_(@userID, @fileID)", pgx.NamedArgs{"userID": userID, "fileID": id})
-	var pgErr *pgconn.PgError
-	ok := errors.As(err, &pgErr)
-	if ok && pgErr.Code == pgerrcode.PrivilegeNotGranted {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusForbidden)
-		return
-	}
-	if ok && pgErr.Code == errorcodes.ResourceDoesNotExist {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-
-	// Get data needed for later.
+	defer conn.Release()
 	var (
 		repositoryID int
 		folderPath   string
 	)
-	err = tx.QueryRow(ctx, `SELECT repository_id_, path_ FROM file_ WHERE id_ = @fileID`,
-		pgx.NamedArgs{"userID": userID, "fileID": id}).Scan(&repositoryID, &folderPath)
-	if errors.Is(err, pgx.ErrNoRows) {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// Delete all files in this folder.
-	// Get all files with this folder's path_ at the start of their own path_ and delete them from s3.
-	rows, err := tx.Query(ctx, "SELECT id_, upload_date_, upload_id_ FROM file_ WHERE repository_id_ = @repositoryID AND type_ = 'file'::file_type_enum_ AND path_ LIKE @path || '%'",
-		pgx.NamedArgs{"repositoryID": repositoryID, "path": folderPath})
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	// Scan the rows into two arrays for deletion.
 	uploadedFiles := []types.UploadedFile{}
 	inProgressFiles := []types.InProgressFile{}
-	for rows.Next() {
-		file := types.FileData{}
-		err = rows.Scan(&file.ID, &file.Date, &file.UploadID)
+	// Check if the user can delete this file and get the files to delete from s3.
+	err = txutil.RunReadOnly(ctx, conn, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "CALL check_permission_modify_file_(@userID, @fileID)", pgx.NamedArgs{"userID": userID, "fileID": id})
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
-		if file.Date == nil {
-			inProgressFiles = append(inProgressFiles, types.InProgressFile{ID: strconv.Itoa(file.ID), UploadID: file.UploadID})
-		} else {
-			uploadedFiles = append(uploadedFiles, types.UploadedFile{ID: strconv.Itoa(file.ID)})
+
+		// Get data needed for later.
+		err = tx.QueryRow(ctx, `SELECT repository_id_, path_ FROM file_ WHERE id_ = @fileID`,
+			pgx.NamedArgs{"userID": userID, "fileID": id}).Scan(&repositoryID, &folderPath)
+		if err != nil {
+			return err
 		}
-	}
-	if rows.Err() != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	err = tx.Commit(ctx)
+
+		// Delete all files in this folder.
+		// Get all files with this folder's path_ at the start of their own path_ and delete them from s3.
+		rows, err := tx.Query(ctx, "SELECT id_, upload_date_, upload_id_ FROM file_ WHERE repository_id_ = @repositoryID AND type_ = 'file'::file_type_enum_ AND path_ LIKE @path || '%'",
+			pgx.NamedArgs{"repositoryID": repositoryID, "path": folderPath})
+		if err != nil {
+			return err
+		}
+		// Scan the rows into two arrays for deletion.
+		for rows.Next() {
+			file := types.FileData{}
+			err = rows.Scan(&file.ID, &file.Date, &file.UploadID)
+			if err != nil {
+				return err
+			}
+			if file.Date == nil {
+				inProgressFiles = append(inProgressFiles, types.InProgressFile{ID: strconv.Itoa(file.ID), UploadID: file.UploadID})
+			} else {
+				uploadedFiles = append(uploadedFiles, types.UploadedFile{ID: strconv.Itoa(file.ID)})
+			}
+		}
+		return rows.Err()
+	})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	// Delete files from s3.
 	err = storage.DeleteAllFiles(ctx, uploadedFiles, inProgressFiles)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
-	// Retry the transaction on serialization failure.
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	// The files are already deleted from s3, so delete their rows even if the client disconnects now.
+	ctx, cancel := txutil.Detach(ctx)
+	defer cancel()
+	err = txutil.Run(ctx, conn, "file.DeleteFolder", func(tx pgx.Tx) error {
 		// Delete the files.
-		_, err = tx.Exec(ctx, "DELETE FROM file_ WHERE repository_id_ = @repositoryID AND (path_ LIKE @path || '/%' OR path_ = @path)",
+		_, err := tx.Exec(ctx, "DELETE FROM file_ WHERE repository_id_ = @repositoryID AND (path_ LIKE @path || '/%' OR path_ = @path)",
 			pgx.NamedArgs{"repositoryID": repositoryID, "path": folderPath})
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
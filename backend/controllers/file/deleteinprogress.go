@@ -2,20 +2,17 @@ package file
 
 import (
 	db "backend/database"
-	"backend/database/errorcodes"
 	"backend/storage"
 	"backend/types"
-	"context"
-	"errors"
-	"fmt"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func DeleteInProgress(w http.ResponseWriter, r *http.Request) {
@@ -24,122 +21,53 @@ func DeleteInProgress(w http.ResponseWriter, r *http.Request) {
 	// Check if the id to delete is a number.
 	id, err := strconv.Atoi(idString)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "id", "must be a number")
 		return
 	}
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	// If commit is not run first this will rollback the transaction.
-	defer tx.Rollback(ctx)
-
+	defer conn.Release()
+	var uploadID string
 	// Check if the user can delete this file.
-	_, err = tx.Exec(ctx, "CALL check_permission_modify_file_(@userID, @fileID)", pgx.NamedArgs{"userID": userID, "fileID": id})
-	var pgErr *pgconn.PgError
-	ok := errors.As(err, &pgErr)
-	if ok && pgErr.Code == pgerrcode.PrivilegeNotGranted {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusForbidden)
-		return
-	}
-	if ok && pgErr.Code == errorcodes.ResourceDoesNotExist {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+	err = txutil.RunReadOnly(ctx, conn, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "CALL check_permission_modify_file_(@userID, @fileID)", pgx.NamedArgs{"userID": userID, "fileID": id})
+		if err != nil {
+			return err
+		}
 
-	// Get the file to abort the upload for.
-	var uploadID string
-	err = tx.QueryRow(ctx, "SELECT upload_id_ FROM file_ WHERE id_ = @fileID AND upload_date_ IS NULL",
-		pgx.NamedArgs{"fileID": id, "userID": userID}).Scan(&uploadID)
-	if errors.Is(err, pgx.ErrNoRows) {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	err = tx.Commit(ctx)
+		// Get the file to abort the upload for.
+		return tx.QueryRow(ctx, "SELECT upload_id_ FROM file_ WHERE id_ = @fileID AND upload_date_ IS NULL",
+			pgx.NamedArgs{"fileID": id, "userID": userID}).Scan(&uploadID)
+	})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
 	err = storage.AbortUpload(ctx, strconv.Itoa(id), uploadID)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err, slog.String("storage_key", strconv.Itoa(id)))
+		errorutil.Error(w, r, err)
 		return
 	}
 
-	// Retry the transaction on serialization failure.
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	err = txutil.Run(ctx, conn, "file.DeleteInProgress", func(tx pgx.Tx) error {
 		// Delete the in progress upload.
-		_, err = tx.Exec(ctx, "DELETE FROM file_ WHERE id_ = @fileID AND upload_date_ IS NULL",
+		_, err := tx.Exec(ctx, "DELETE FROM file_ WHERE id_ = @fileID AND upload_date_ IS NULL",
 			pgx.NamedArgs{"fileID": id})
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
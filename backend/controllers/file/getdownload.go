@@ -4,14 +4,13 @@ import (
 	db "backend/database"
 	"backend/storage"
 	"backend/types"
-	"context"
+	"backend/util/errorutil"
+	"backend/util/logutil"
 	"encoding/json"
-	"errors"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"path"
 	"strconv"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
@@ -26,8 +25,8 @@ type downloadResponse struct {
 func GetDownload(w http.ResponseWriter, r *http.Request) {
 	fileID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
+		logutil.LogError(r.Context(), err)
+		errorutil.Invalid(w, r, "id", "must be a number")
 		return
 	}
 	var userID int
@@ -36,19 +35,18 @@ func GetDownload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get a connection from the database and start a transaction.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	// If commit is not run first this will rollback the transaction.
@@ -61,21 +59,16 @@ func GetDownload(w http.ResponseWriter, r *http.Request) {
 	var filePath string
 	err = tx.QueryRow(ctx, `SELECT repository_.id_, repository_.visibility_, repository_.user_id_, file_.path_ FROM repository_ JOIN 
 	file_ ON repository_.id_ = file_.repository_id_ WHERE file_.id_ = $1 LIMIT 1`, fileID).Scan(&repositoryID, &visibility, &ownerUserID, &filePath)
-	if errors.Is(err, pgx.ErrNoRows) {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
 	// If the repository is private and the user is not logged in return status 401.
 	if visibility == "private" && userID == 0 {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusUnauthorized)
+		logutil.LogError(r.Context(), err)
+		errorutil.Status(w, r, http.StatusUnauthorized)
 		return
 	}
 
@@ -85,8 +78,8 @@ func GetDownload(w http.ResponseWriter, r *http.Request) {
 		err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM member_ WHERE repository_id_ = $1 AND user_id_ = $2)",
 			repositoryID, userID).Scan(&found)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
 			return
 		}
 	}
@@ -94,8 +87,8 @@ func GetDownload(w http.ResponseWriter, r *http.Request) {
 	// Get the download url.
 	url, err := storage.GetDownload(ctx, strconv.Itoa(fileID), path.Base(filePath))
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err, slog.String("storage_key", strconv.Itoa(fileID)))
+		errorutil.Error(w, r, err)
 		return
 	}
 	res := downloadResponse{URL: url}
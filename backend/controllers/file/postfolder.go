@@ -2,20 +2,16 @@ package file
 
 import (
 	db "backend/database"
-	"backend/database/errorcodes"
 	"backend/types"
-	"context"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
 	"net/http"
 	"path"
 	"time"
 
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type folder struct {
@@ -31,20 +27,18 @@ type folderResponse struct {
 func PostFolder(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(types.ContextKey("id")).(int)
 	f := folder{}
-	err := json.NewDecoder(io.LimitReader(r.Body, 1000)).Decode(&f)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 1000, &f) {
 		return
 	}
 	f.Key = path.Clean(f.Key)
 	if f.Key == "." {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "key", "must not be empty")
 		return
 	}
 	// Check if the cleaned key (path) is valid.
 	runes := []rune(f.Key)
 	if string(runes[0]) == "/" {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "key", "must be a relative path")
 		return
 	}
 	folderPath := path.Dir(f.Key)
@@ -54,94 +48,37 @@ func PostFolder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 
-	// Retry the transaction on serialization failure.
 	var res folderResponse
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	err = txutil.Run(ctx, conn, "file.PostFolder", func(tx pgx.Tx) error {
 		// Check if the user can add this folder.
-		_, err = tx.Exec(ctx, "CALL prepare_folder_(@repoID, @userID, @path, @folderPath)",
+		_, err := tx.Exec(ctx, "CALL prepare_folder_(@repoID, @userID, @path, @folderPath)",
 			pgx.NamedArgs{"repoID": f.RepositoryID, "userID": userID, "path": f.Key, "folderPath": folderPath})
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.PrivilegeNotGranted {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		if ok && pgErr.Code == errorcodes.FileAlreadyExists {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusConflict)
-			return
-		}
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		// Save the folder to the db.
 		var date time.Time
 		err = tx.QueryRow(ctx, "INSERT INTO file_ VALUES (DEFAULT, @repoID, @userID, @path, @type, 0, NULL, CURRENT_TIMESTAMP(0)) RETURNING id_, upload_date_",
 			pgx.NamedArgs{"repoID": f.RepositoryID, "userID": userID, "path": f.Key, "type": "folder"}).Scan(&res.ID, &date)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.UniqueViolation {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusConflict)
-			return
-		}
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 		res.Date = int(date.Unix())
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		return nil
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -4,14 +4,13 @@ import (
 	db "backend/database"
 	"backend/storage"
 	"backend/types"
-	"context"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -27,72 +26,51 @@ type resumeFileResponse struct {
 func PostResumeUpload(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(types.ContextKey("id")).(int)
 	f := resumeFile{}
-	err := json.NewDecoder(io.LimitReader(r.Body, 1000)).Decode(&f)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 1000, &f) {
 		return
 	}
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-	// If commit is not run first this will rollback the transaction.
-	defer tx.Rollback(ctx)
-
-	// Get the file to resume upload for.
+	defer conn.Release()
 	var (
 		uploadID string
 		bytes    int
 	)
-	err = tx.QueryRow(ctx, "SELECT upload_id_, size_ FROM file_ WHERE id_ = @fileID AND user_id_ = @userID AND type_ = 'file'::file_type_enum_",
-		pgx.NamedArgs{"fileID": f.ID, "userID": userID}).Scan(&uploadID, &bytes)
-	if errors.Is(err, pgx.ErrNoRows) {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// Get the file's uploaded parts.
-	rows, err := tx.Query(ctx, "SELECT part_ FROM file_part_ WHERE file_id_ = $1", f.ID)
-	// Scan the rows into an array.
 	completeParts := []types.CompletePart{}
-	for rows.Next() {
-		part := types.CompletePart{}
-		err = rows.Scan(&part.Part)
+	// Get the file to resume upload for and its uploaded parts.
+	err = txutil.RunReadOnly(ctx, conn, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, "SELECT upload_id_, size_ FROM file_ WHERE id_ = @fileID AND user_id_ = @userID AND type_ = 'file'::file_type_enum_",
+			pgx.NamedArgs{"fileID": f.ID, "userID": userID}).Scan(&uploadID, &bytes)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
-		completeParts = append(completeParts, part)
-	}
-	if rows.Err() != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	err = tx.Commit(ctx)
+
+		// Get the file's uploaded parts.
+		rows, err := tx.Query(ctx, "SELECT part_ FROM file_part_ WHERE file_id_ = $1", f.ID)
+		if err != nil {
+			return err
+		}
+		// Scan the rows into an array.
+		for rows.Next() {
+			part := types.CompletePart{}
+			err = rows.Scan(&part.Part)
+			if err != nil {
+				return err
+			}
+			completeParts = append(completeParts, part)
+		}
+		return rows.Err()
+	})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -100,8 +78,8 @@ func PostResumeUpload(w http.ResponseWriter, r *http.Request) {
 	res := resumeFileResponse{}
 	res.UploadParts, err = storage.ResumeUpload(ctx, strconv.Itoa(f.ID), uploadID, bytes, completeParts)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err, slog.String("storage_key", strconv.Itoa(f.ID)))
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -4,25 +4,27 @@ import (
 	db "backend/database"
 	"backend/storage"
 	"backend/types"
+	"backend/util/errorutil"
 	"backend/util/fileutil"
-	"context"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type uploadComplete struct {
 	ID int
 }
 
+// Returned when the parts uploaded so far do not make up the whole file.
+var errMissingParts = errors.New("not all parts of the file are uploaded")
+
 // Date will be Unix time in seconds.
 type uploadCompleteResponse struct {
 	Date int `json:"date"`
@@ -31,156 +33,85 @@ type uploadCompleteResponse struct {
 func PostUploadComplete(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(types.ContextKey("id")).(int)
 	req := uploadComplete{}
-	err := json.NewDecoder(io.LimitReader(r.Body, 10*1000)).Decode(&req)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 10*1000, &req) {
 		return
 	}
 
 	// Get a connection from the database and start a transaction.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	// If commit is not run first this will rollback the transaction.
-	defer tx.Rollback(ctx)
-
-	// Get the parts and check if user owns this file.
-	rows, err := tx.Query(ctx, "SELECT * FROM get_file_parts_(@fileID, @userID)",
-		pgx.NamedArgs{"fileID": req.ID, "userID": userID})
-	var pgErr *pgconn.PgError
-	ok := errors.As(err, &pgErr)
-	if ok && pgErr.Code == pgerrcode.PrivilegeNotGranted {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusForbidden)
-		return
-	}
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-
-	// Scan the rows into an array.
+	defer conn.Release()
 	parts := []types.CompletePart{}
-	for rows.Next() {
-		part := types.CompletePart{}
-		err = rows.Scan(&part.ETag, &part.Part)
+	var uploadID string
+	// Get the parts and check if user owns this file.
+	err = txutil.RunReadOnly(ctx, conn, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "SELECT * FROM get_file_parts_(@fileID, @userID)",
+			pgx.NamedArgs{"fileID": req.ID, "userID": userID})
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
-		parts = append(parts, part)
-	}
-	if rows.Err() != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	if len(parts) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
 
-	// Get the file size to know how many parts are required and check if that amount matches.
-	var size int
-	err = tx.QueryRow(ctx, "SELECT size_ FROM file_ WHERE id_ = $1", req.ID).Scan(&size)
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	partCount, _, _ := fileutil.SplitFile(size)
-	if partCount != len(parts) {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+		// Scan the rows into an array.
+		for rows.Next() {
+			part := types.CompletePart{}
+			err = rows.Scan(&part.ETag, &part.Part)
+			if err != nil {
+				return err
+			}
+			parts = append(parts, part)
+		}
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		if len(parts) == 0 {
+			return errMissingParts
+		}
 
-	// Get the file to complete upload for.
-	var uploadID string
-	err = tx.QueryRow(ctx, "SELECT upload_id_ FROM file_ WHERE id_ = @fileID AND user_id_ = @userID AND type_ = 'file'::file_type_enum_",
-		pgx.NamedArgs{"fileID": req.ID, "userID": userID}).Scan(&uploadID)
-	if errors.Is(err, pgx.ErrNoRows) {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		// Get the file size to know how many parts are required and check if that amount matches.
+		var size int
+		err = tx.QueryRow(ctx, "SELECT size_ FROM file_ WHERE id_ = $1", req.ID).Scan(&size)
+		if err != nil {
+			return err
+		}
+		partCount, _, _ := fileutil.SplitFile(size)
+		if partCount != len(parts) {
+			return errMissingParts
+		}
+
+		// Get the file to complete upload for.
+		return tx.QueryRow(ctx, "SELECT upload_id_ FROM file_ WHERE id_ = @fileID AND user_id_ = @userID AND type_ = 'file'::file_type_enum_",
+			pgx.NamedArgs{"fileID": req.ID, "userID": userID}).Scan(&uploadID)
+	})
+	if errors.Is(err, errMissingParts) {
+		errorutil.Write(w, r, http.StatusBadRequest, types.MissingPartsCode, types.MissingParts, nil)
 		return
 	}
-	err = tx.Commit(ctx)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
 	err = storage.CompleteUpload(ctx, strconv.Itoa(req.ID), uploadID, parts)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err, slog.String("storage_key", strconv.Itoa(req.ID)))
+		errorutil.Error(w, r, err)
 		return
 	}
 
-	// Retry the transaction on serialization failure.
 	var date time.Time
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	err = txutil.Run(ctx, conn, "file.PostUploadComplete", func(tx pgx.Tx) error {
 		// Update the file's date in db from null, to mark the file has been fully uploaded.
-		err = tx.QueryRow(ctx, "UPDATE file_ SET upload_date_ = CURRENT_TIMESTAMP(0) WHERE id_ = $1 RETURNING upload_date_", req.ID).Scan(&date)
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		return tx.QueryRow(ctx, "UPDATE file_ SET upload_date_ = CURRENT_TIMESTAMP(0) WHERE id_ = $1 RETURNING upload_date_", req.ID).Scan(&date)
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	res := uploadCompleteResponse{Date: int(date.Unix())}
@@ -3,17 +3,12 @@ package file
 import (
 	db "backend/database"
 	"backend/types"
-	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"net/http"
-	"time"
 
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type filePartRequest struct {
@@ -24,78 +19,29 @@ type filePartRequest struct {
 func PostUploadPart(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(types.ContextKey("id"))
 	part := filePartRequest{}
-	err := json.NewDecoder(io.LimitReader(r.Body, 1000)).Decode(&part)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 1000, &part) {
 		return
 	}
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 
-	// Retry the transaction on serialization failure.
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	err = txutil.Run(ctx, conn, "file.PostUploadPart", func(tx pgx.Tx) error {
 		// Insert the file part.
-		_, err = tx.Exec(ctx, "CALL create_file_part_(@fileID, @eTag, @part, @userID)",
+		_, err := tx.Exec(ctx, "CALL create_file_part_(@fileID, @eTag, @part, @userID)",
 			pgx.NamedArgs{"fileID": part.FileID, "eTag": part.ETag, "part": part.Part, "userID": userID})
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.UniqueViolation {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusConflict)
-			return
-		}
-		if ok && pgErr.Code == pgerrcode.PrivilegeNotGranted {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
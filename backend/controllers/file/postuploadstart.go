@@ -2,23 +2,19 @@ package file
 
 import (
 	db "backend/database"
-	"backend/database/errorcodes"
 	"backend/storage"
 	"backend/types"
 	"backend/util/config"
-	"context"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"path"
 	"strconv"
-	"time"
 
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type uploadFile struct {
@@ -31,24 +27,22 @@ type uploadFile struct {
 
 func PostUploadStart(w http.ResponseWriter, r *http.Request) {
 	f := uploadFile{}
-	err := json.NewDecoder(io.LimitReader(r.Body, 1000)).Decode(&f)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 1000, &f) {
 		return
 	}
-	if f.Size < config.MinFileSize {
-		w.WriteHeader(http.StatusBadRequest)
+	if f.Size < config.Config.MinFileSize {
+		errorutil.Invalid(w, r, "size", "must be at least "+strconv.Itoa(config.Config.MinFileSize)+" bytes")
 		return
 	}
 	f.Key = path.Clean(f.Key)
 	if f.Key == "." {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "key", "must not be empty")
 		return
 	}
 	// Check if the cleaned key (path) is valid.
 	runes := []rune(f.Key)
 	if string(runes[0]) == "/" {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "key", "must be a relative path")
 		return
 	}
 	folderPath := path.Dir(f.Key)
@@ -59,104 +53,35 @@ func PostUploadStart(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(types.ContextKey("id")).(int)
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 
-	// Retry the transaction on serialization failure.
 	var data types.UploadStart
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	err = txutil.Run(ctx, conn, "file.PostUploadStart", func(tx pgx.Tx) error {
 		// Check if user can upload the file.
-		_, err = tx.Exec(ctx, "CALL prepare_file_(@repoID, @userID, @path, @folderPath, @size)",
+		_, err := tx.Exec(ctx, "CALL prepare_file_(@repoID, @userID, @path, @folderPath, @size)",
 			pgx.NamedArgs{"repoID": f.RepositoryID, "userID": userID, "path": f.Key, "folderPath": folderPath, "size": f.Size})
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == errorcodes.UserHasNoSpace {
-			fmt.Println(err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(types.ErrorResponse{Message: types.UserHasNoSpace})
-			return
-		}
-		if ok && pgErr.Code == errorcodes.InsufficientPermission {
-			fmt.Println(err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(types.ErrorResponse{Message: types.InsufficientPermission})
-			return
-		}
-		if ok && pgErr.Code == errorcodes.FileAlreadyExists {
-			fmt.Println(err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(types.ErrorResponse{Message: types.FileAlreadyExists})
-			return
-		}
-		if ok && pgErr.Code == errorcodes.ContainingFolderDoesNotExist {
-			fmt.Println(err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(types.ErrorResponse{Message: types.ContainingFolderDoesNotExist})
-			return
-		}
-		if ok && pgErr.Code == pgerrcode.PrivilegeNotGranted {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		// Save the file to the db.
 		var fileID int
 		err = tx.QueryRow(ctx, "INSERT INTO file_ VALUES (DEFAULT, @repoID, @userID, @path, @type, @size, '', NULL) RETURNING id_",
 			pgx.NamedArgs{"repoID": f.RepositoryID, "userID": userID, "path": f.Key, "type": "file", "size": f.Size}).Scan(&fileID)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.UniqueViolation {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusConflict)
-			return
-		}
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		data, err = storage.StartUpload(ctx, strconv.Itoa(fileID), path.Base(f.Key), f.Size)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return fmt.Errorf("starting upload of storage key %d: %w", fileID, err)
 		}
 		data.FileID = fileID
 
@@ -164,35 +89,11 @@ func PostUploadStart(w http.ResponseWriter, r *http.Request) {
 		var found string
 		err = tx.QueryRow(ctx, "UPDATE file_ SET upload_id_ = @uploadID WHERE id_ = @fileID RETURNING upload_id_",
 			pgx.NamedArgs{"uploadID": data.UploadID, "fileID": fileID}).Scan(&found)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	res := types.UploadStartResponse{UploadParts: data.UploadParts, FileID: data.FileID}
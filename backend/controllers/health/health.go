@@ -0,0 +1,51 @@
+package health
+
+import (
+	"backend/util/healthutil"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type readyResponse struct {
+	Status string `json:"status"` // "ok", "unavailable" or "shutting_down".
+	// Only the status of each dependency, errors can contain addresses and are only shown to admins.
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// Liveness check, the process is running and serving requests.
+func GetHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+// Readiness check, the databases and the storage bucket respond and the backend is not shutting down.
+func GetReady(w http.ResponseWriter, r *http.Request) {
+	if healthutil.ShuttingDown() {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(readyResponse{Status: "shutting_down", Dependencies: map[string]string{}})
+		return
+	}
+	dependencies, ok := healthutil.Check(r.Context())
+	res := readyResponse{Status: "ok", Dependencies: map[string]string{}}
+	status := http.StatusOK
+	for _, dependency := range dependencies {
+		res.Dependencies[dependency.Name] = dependency.Status
+		if dependency.Status == "error" {
+			slog.WarnContext(r.Context(), "Dependency not ready", "dependency", dependency.Name, "error", dependency.Error)
+		}
+	}
+	if !ok {
+		res.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
@@ -3,17 +3,12 @@ package member
 import (
 	db "backend/database"
 	"backend/types"
-	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"net/http"
-	"time"
 
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type memberPermission struct {
@@ -23,101 +18,48 @@ type memberPermission struct {
 
 func PatchPermission(w http.ResponseWriter, r *http.Request) {
 	mPermission := memberPermission{}
-	err := json.NewDecoder(io.LimitReader(r.Body, 1000)).Decode(&mPermission)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 1000, &mPermission) {
 		return
 	}
 	if mPermission.Permission != "full" && mPermission.Permission != "read" {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "permission", "must be full or read")
 		return
 	}
 	userID := r.Context().Value(types.ContextKey("id")).(int)
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	// If commit is not run first this will rollback the transaction.
-	defer tx.Rollback(ctx)
-
+	defer conn.Release()
 	// Check if the user can modify this member.
 	var found bool
-	err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM member_ JOIN repository_ ON member_.repository_id_ = repository_.id_ WHERE repository_.user_id_ = @userID AND member_.id_ = @memberID)",
-		pgx.NamedArgs{"userID": userID, "memberID": mPermission.ID}).Scan(&found)
-	if errors.Is(err, pgx.ErrNoRows) {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusForbidden)
-		return
-	}
+	err = txutil.RunReadOnly(ctx, conn, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM member_ JOIN repository_ ON member_.repository_id_ = repository_.id_ WHERE repository_.user_id_ = @userID AND member_.id_ = @memberID)",
+			pgx.NamedArgs{"userID": userID, "memberID": mPermission.ID}).Scan(&found)
+	})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-	err = tx.Commit(ctx)
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if !found {
+		errorutil.Status(w, r, http.StatusForbidden)
 		return
 	}
 
-	// Retry the transaction on serialization failure.
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	err = txutil.Run(ctx, conn, "member.PatchPermission", func(tx pgx.Tx) error {
 		// Change member's permission in the database.
-		_, err = tx.Exec(ctx, "UPDATE member_ SET permission_ = $1::permission_enum_ WHERE id_ = $2", mPermission.Permission, mPermission.ID)
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		_, err := tx.Exec(ctx, "UPDATE member_ SET permission_ = $1::permission_enum_ WHERE id_ = $2", mPermission.Permission, mPermission.ID)
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -4,17 +4,14 @@ import (
 	db "backend/database"
 	"backend/storage"
 	"backend/types"
-	"context"
-	"errors"
-	"fmt"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // Delete all files the user and other users have in the repository.
@@ -24,135 +21,77 @@ func DeleteRepository(w http.ResponseWriter, r *http.Request) {
 	// Check if the id to delete is a number.
 	id, err := strconv.Atoi(idString)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "id", "must be a number")
 		return
 	}
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-	// If commit is not run first this will rollback the transaction.
-	defer tx.Rollback(ctx)
-
-	// Check if the user owns the repository.
+	defer conn.Release()
 	var found bool
-	err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM repository_ WHERE id_ = $1 AND user_id_ = $2)", id, userID).Scan(&found)
-	if errors.Is(err, pgx.ErrNoRows) {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// Get all files in the repository.
-	rows, err := tx.Query(ctx, "SELECT id_, upload_date_, upload_id_ FROM file_ WHERE repository_id_ = $1 AND type_ = 'file'::file_type_enum_", id)
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	// Scan the rows into two arrays for deletion.
 	uploadedFiles := []types.UploadedFile{}
 	inProgressFiles := []types.InProgressFile{}
-	for rows.Next() {
-		file := types.FileData{}
-		err = rows.Scan(&file.ID, &file.Date, &file.UploadID)
+	// Check if the user owns the repository.
+	err = txutil.RunReadOnly(ctx, conn, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM repository_ WHERE id_ = $1 AND user_id_ = $2)", id, userID).Scan(&found)
+		if err != nil || !found {
+			return err
+		}
+
+		// Get all files in the repository.
+		rows, err := tx.Query(ctx, "SELECT id_, upload_date_, upload_id_ FROM file_ WHERE repository_id_ = $1 AND type_ = 'file'::file_type_enum_", id)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
-		if file.Date == nil {
-			inProgressFiles = append(inProgressFiles, types.InProgressFile{ID: strconv.Itoa(file.ID), UploadID: file.UploadID})
-		} else {
-			uploadedFiles = append(uploadedFiles, types.UploadedFile{ID: strconv.Itoa(file.ID)})
+		// Scan the rows into two arrays for deletion.
+		for rows.Next() {
+			file := types.FileData{}
+			err = rows.Scan(&file.ID, &file.Date, &file.UploadID)
+			if err != nil {
+				return err
+			}
+			if file.Date == nil {
+				inProgressFiles = append(inProgressFiles, types.InProgressFile{ID: strconv.Itoa(file.ID), UploadID: file.UploadID})
+			} else {
+				uploadedFiles = append(uploadedFiles, types.UploadedFile{ID: strconv.Itoa(file.ID)})
+			}
 		}
-	}
-	if rows.Err() != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		return rows.Err()
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-	err = tx.Commit(ctx)
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if !found {
+		errorutil.Status(w, r, http.StatusNotFound)
 		return
 	}
 	// Delete files from s3.
 	err = storage.DeleteAllFiles(ctx, uploadedFiles, inProgressFiles)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
-	// Retry the transaction on serialization failure.
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
-		// Check if the user can add this folder.
-		_, err = tx.Exec(ctx, "DELETE FROM repository_ WHERE user_id_ = $1 AND id_ = $2", userID, id)
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.PrivilegeNotGranted {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+	// Finish deleting the repository even if the client disconnects, its files are already gone from s3.
+	ctx, cancel := txutil.Detach(ctx)
+	defer cancel()
+	err = txutil.Run(ctx, conn, "repository.DeleteRepository", func(tx pgx.Tx) error {
+		// Delete the repository.
+		_, err := tx.Exec(ctx, "DELETE FROM repository_ WHERE user_id_ = $1 AND id_ = $2", userID, id)
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
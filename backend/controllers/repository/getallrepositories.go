@@ -3,11 +3,10 @@ package repository
 import (
 	db "backend/database"
 	"backend/types"
-	"context"
+	"backend/util/errorutil"
+	"backend/util/logutil"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -26,19 +25,18 @@ type repositoryData struct {
 func GetAllRepositories(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(types.ContextKey("id"))
 	// Get a connection from the database and start a transaction.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	// If commit is not run first this will rollback the transaction.
@@ -50,8 +48,8 @@ func GetAllRepositories(w http.ResponseWriter, r *http.Request) {
 	COALESCE((SELECT SUM(size_) FROM file_ f WHERE f.repository_id_ = r.id_ AND f.user_id_ = @userID), 0) FROM repository_ r 
 	JOIN user_ u ON r.user_id_ = u.id_ WHERE r.user_id_ = @userID GROUP BY r.id_, r.name_, u.username_`, pgx.NamedArgs{"userID": userID})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	res.Repositories, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (repositoryData, error) {
@@ -60,8 +58,8 @@ func GetAllRepositories(w http.ResponseWriter, r *http.Request) {
 		return repo, err
 	})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -72,8 +70,8 @@ func GetAllRepositories(w http.ResponseWriter, r *http.Request) {
 	WHERE m.user_id_ = @userID GROUP BY r.id_, r.name_, u.username_`,
 		pgx.NamedArgs{"userID": userID})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	memberRepos, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (repositoryData, error) {
@@ -82,8 +80,8 @@ func GetAllRepositories(w http.ResponseWriter, r *http.Request) {
 		return repo, err
 	})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	res.Repositories = append(res.Repositories, memberRepos...)
@@ -3,10 +3,10 @@ package repository
 import (
 	db "backend/database"
 	"backend/types"
-	"context"
+	"backend/util/errorutil"
+	"backend/util/logutil"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -54,8 +54,8 @@ type member struct {
 func GetRepository(w http.ResponseWriter, r *http.Request) {
 	repositoryID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusBadRequest)
+		logutil.LogError(r.Context(), err)
+		errorutil.Invalid(w, r, "id", "must be a number")
 		return
 	}
 	var userID int
@@ -64,19 +64,18 @@ func GetRepository(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get a connection from the database and start a transaction.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	// If commit is not run first this will rollback the transaction.
@@ -88,14 +87,9 @@ func GetRepository(w http.ResponseWriter, r *http.Request) {
 	var ownerUserID int
 	err = tx.QueryRow(ctx, "SELECT f.name_, f.visibility_, f.user_id_, u.username_, f.visibility_ FROM repository_ f JOIN user_ u ON f.user_id_ = u.id_ WHERE f.id_ = $1",
 		repositoryID).Scan(&res.Name, &visibility, &ownerUserID, &res.OwnerUsername, &res.Visibility)
-	if errors.Is(err, pgx.ErrNoRows) {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	if userID == ownerUserID {
@@ -106,8 +100,8 @@ func GetRepository(w http.ResponseWriter, r *http.Request) {
 
 	// If the repository is private and the user is not logged in return status 401.
 	if visibility == "private" && userID == 0 {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusUnauthorized)
+		logutil.LogError(r.Context(), err)
+		errorutil.Status(w, r, http.StatusUnauthorized)
 		return
 	}
 
@@ -117,8 +111,8 @@ func GetRepository(w http.ResponseWriter, r *http.Request) {
 		err = tx.QueryRow(ctx, "SELECT permission_ FROM member_ WHERE repository_id_ = $1 AND user_id_ = $2",
 			repositoryID, userID).Scan(&res.UserPermission)
 		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
 			return
 		}
 	}
@@ -129,7 +123,7 @@ func GetRepository(w http.ResponseWriter, r *http.Request) {
 		isMember = true
 	}
 	if userID != 0 && visibility == "private" && userID != ownerUserID && !isMember {
-		w.WriteHeader(http.StatusForbidden)
+		errorutil.Status(w, r, http.StatusForbidden)
 		return
 	}
 
@@ -137,8 +131,8 @@ func GetRepository(w http.ResponseWriter, r *http.Request) {
 	rows, err := tx.Query(ctx, `SELECT file_.id_, user_.username_, file_.path_, file_.type_, file_.size_, file_.upload_date_
 		FROM file_ JOIN user_ ON file_.user_id_ = user_.id_ WHERE file_.repository_id_ = $1`, repositoryID)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	res.Files, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (file, error) {
@@ -153,8 +147,8 @@ func GetRepository(w http.ResponseWriter, r *http.Request) {
 		return file, err
 	})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -170,8 +164,8 @@ func GetRepository(w http.ResponseWriter, r *http.Request) {
 	rows, err = tx.Query(ctx, `SELECT member_.id_, user_.username_, member_.permission_
 		FROM member_ JOIN user_ ON member_.user_id_ = user_.id_ WHERE member_.repository_id_ = $1`, repositoryID)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	res.Members, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (member, error) {
@@ -184,15 +178,15 @@ func GetRepository(w http.ResponseWriter, r *http.Request) {
 		return member, err
 	})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -3,18 +3,13 @@ package repository
 import (
 	db "backend/database"
 	"backend/types"
-	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"net/http"
-	"time"
 	"unicode/utf8"
 
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type repositoryNamePatch struct {
@@ -24,72 +19,33 @@ type repositoryNamePatch struct {
 
 func PatchName(w http.ResponseWriter, r *http.Request) {
 	repo := repositoryNamePatch{}
-	err := json.NewDecoder(io.LimitReader(r.Body, 1000)).Decode(&repo)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 1000, &repo) {
 		return
 	}
 	if utf8.RuneCountInString(repo.Name) > 35 || utf8.RuneCountInString(repo.Name) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "name", "must be 1 to 35 characters")
 		return
 	}
 	userID := r.Context().Value(types.ContextKey("id"))
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 
-	// Retry the transaction on serialization failure.
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	err = txutil.Run(ctx, conn, "repository.PatchName", func(tx pgx.Tx) error {
 		// Change repository visibility.
-		_, err = tx.Exec(ctx, "UPDATE repository_ SET name_ = $1 WHERE id_ = $2 AND user_id_ = $3", repo.Name, repo.ID, userID)
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		_, err := tx.Exec(ctx, "UPDATE repository_ SET name_ = $1 WHERE id_ = $2 AND user_id_ = $3", repo.Name, repo.ID, userID)
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -3,9 +3,9 @@ package user
 import (
 	db "backend/database"
 	"backend/types"
-	"context"
+	"backend/util/errorutil"
+	"backend/util/logutil"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"time"
 
@@ -17,40 +17,48 @@ type allSessionsResponse struct {
 }
 
 type session struct {
-	ID         int       `json:"id"`
-	ExpiryDate time.Time `json:"expiryDate"`
-	Device     string    `json:"device"`
+	ID            int        `json:"id"`
+	ExpiryDate    time.Time  `json:"expiryDate"`
+	Device        string     `json:"device"`
+	RevokedDate   *time.Time `json:"revokedDate"`   // Null if the session was not revoked.
+	RevokedReason *string    `json:"revokedReason"` // For example "token_reuse" if an old refresh token was used again.
+	CreatedDate   time.Time  `json:"createdDate"`
+	LastUsedDate  time.Time  `json:"lastUsedDate"` // Updated when the access token is refreshed.
+	LastIP        *string    `json:"lastIP"`
+	Name          *string    `json:"name"`    // Device name set by the user.
+	Current       bool       `json:"current"` // True for the session that made the request.
 }
 
-// Get all valid sessions for a user.
+// Get all valid sessions for a user, including revoked sessions that have not expired yet.
 func GetSessions(w http.ResponseWriter, r *http.Request) {
 	// Get the userID from the auth middleware.
 	userID := r.Context().Value(types.ContextKey("id"))
+	refreshToken := r.Context().Value(types.ContextKey("session"))
 
 	// Get a connection from the database and start a transaction.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	// If commit is not run first this will rollback the transaction.
 	defer tx.Rollback(ctx)
 
 	// Get the sessions.
-	rows, err := tx.Query(ctx, "SELECT id_, expiry_date_, device_ FROM session_ WHERE user_id_ = $1", userID)
+	rows, err := tx.Query(ctx, `SELECT id_, expiry_date_, device_, revoked_date_, revoked_reason_, created_date_, last_used_date_, last_ip_, name_, 
+	token_ = $2 FROM session_ WHERE user_id_ = $1 ORDER BY last_used_date_ DESC`, userID, refreshToken)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -58,23 +66,25 @@ func GetSessions(w http.ResponseWriter, r *http.Request) {
 	sessionArr := allSessionsResponse{}
 	for rows.Next() {
 		session := session{}
-		err = rows.Scan(&session.ID, &session.ExpiryDate, &session.Device)
+		err = rows.Scan(&session.ID, &session.ExpiryDate, &session.Device, &session.RevokedDate, &session.RevokedReason,
+			&session.CreatedDate, &session.LastUsedDate, &session.LastIP, &session.Name, &session.Current)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
 			return
 		}
 		sessionArr.Sessions = append(sessionArr.Sessions, session)
 	}
-	if rows.Err() != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+	err = rows.Err()
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	err = tx.Commit(ctx)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -0,0 +1,63 @@
+package user
+
+import (
+	db "backend/database"
+	"backend/types"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"backend/util/txutil"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type sessionNamePatch struct {
+	Name string
+	ID   int
+}
+
+// Set the device name of a user's session, an empty name removes it.
+func PatchName(w http.ResponseWriter, r *http.Request) {
+	session := sessionNamePatch{}
+	if !errorutil.DecodeJSON(w, r, 1000, &session) {
+		return
+	}
+	if utf8.RuneCountInString(session.Name) > 50 {
+		errorutil.Invalid(w, r, "name", "must be at most 50 characters")
+		return
+	}
+	userID := r.Context().Value(types.ContextKey("id"))
+
+	// Get a connection from the database.
+	ctx := r.Context()
+	conn, err := db.GetConnection(ctx)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	defer conn.Release()
+
+	var updated int64
+	err = txutil.Run(ctx, conn, "session.PatchName", func(tx pgx.Tx) error {
+		// Change the session name, a blank name is saved as NULL.
+		tag, err := tx.Exec(ctx, "UPDATE session_ SET name_ = NULLIF(TRIM($1), '') WHERE id_ = $2 AND user_id_ = $3", session.Name, session.ID, userID)
+		if err != nil {
+			return err
+		}
+		updated = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	if updated == 0 {
+		errorutil.Status(w, r, http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
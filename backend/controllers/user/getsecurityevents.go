@@ -0,0 +1,113 @@
+package user
+
+import (
+	db "backend/database"
+	"backend/types"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type securityEventsResponse struct {
+	Events []securityEvent `json:"events"`
+	// Pass it as the before query parameter to get the next page, null on the last page.
+	Next *int `json:"next"`
+}
+
+type securityEvent struct {
+	ID      int       `json:"id"`
+	Type    string    `json:"type"` // One of the values of security_event_enum_, for example "login_failed".
+	Date    time.Time `json:"date"`
+	IP      *string   `json:"ip"`
+	Device  *string   `json:"device"`
+	Details *string   `json:"details"` // For example the id of a deleted session or the new username.
+}
+
+// Get a page of the user's security events, newest first.
+// Query parameters: before - only return events with a smaller id, limit - page size (50 by default, up to 100).
+func GetSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(types.ContextKey("id"))
+	limit := 50
+	if limitString := r.URL.Query().Get("limit"); limitString != "" {
+		var err error
+		limit, err = strconv.Atoi(limitString)
+		if err != nil || limit < 1 || limit > 100 {
+			errorutil.Invalid(w, r, "limit", "must be a number from 1 to 100")
+			return
+		}
+	}
+	var before *int
+	if beforeString := r.URL.Query().Get("before"); beforeString != "" {
+		id, err := strconv.Atoi(beforeString)
+		if err != nil {
+			errorutil.Invalid(w, r, "before", "must be a number")
+			return
+		}
+		before = &id
+	}
+
+	// Get a connection from the database and start a transaction.
+	ctx := r.Context()
+	conn, err := db.GetConnection(ctx)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	defer conn.Release()
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	// If commit is not run first this will rollback the transaction.
+	defer tx.Rollback(ctx)
+
+	// Get one more event than the limit to know if there is a next page.
+	rows, err := tx.Query(ctx, `SELECT id_, type_::TEXT, date_, ip_, device_, details_ FROM security_event_ 
+	WHERE user_id_ = @userID AND (@before::BIGINT IS NULL OR id_ < @before) ORDER BY id_ DESC LIMIT @limit`,
+		pgx.NamedArgs{"userID": userID, "before": before, "limit": limit + 1})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+
+	// Scan the rows into an array.
+	res := securityEventsResponse{Events: []securityEvent{}}
+	for rows.Next() {
+		event := securityEvent{}
+		err = rows.Scan(&event.ID, &event.Type, &event.Date, &event.IP, &event.Device, &event.Details)
+		if err != nil {
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
+			return
+		}
+		res.Events = append(res.Events, event)
+	}
+	if rows.Err() != nil {
+		logutil.LogError(r.Context(), rows.Err())
+		errorutil.Error(w, r, rows.Err())
+		return
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
+		return
+	}
+	if len(res.Events) > limit {
+		res.Events = res.Events[:limit]
+		res.Next = &res.Events[limit-1].ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+}
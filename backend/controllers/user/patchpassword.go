@@ -3,19 +3,16 @@ package user
 import (
 	db "backend/database"
 	"backend/types"
-	"context"
-	"encoding/json"
+	"backend/util/errorutil"
+	"backend/util/iputil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"errors"
-	"fmt"
-	"io"
 	"net/http"
-	"time"
 	"unicode/utf8"
 
 	"github.com/alexedwards/argon2id"
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type passwords struct {
@@ -23,22 +20,23 @@ type passwords struct {
 	NewPassword     string
 }
 
+// Returned when the current password sent to change it does not match.
+var errWrongPassword = errors.New("current password does not match")
+
 func PatchPassword(w http.ResponseWriter, r *http.Request) {
 	user := passwords{}
 	// Limit reading the request body up to 1kB.
 	// Input characters get automatically changed to � if they are invalid utf8:
 	// Decode() acts the same as: https://pkg.go.dev/encoding/json#Unmarshal
-	err := json.NewDecoder(io.LimitReader(r.Body, 1000)).Decode(&user)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 1000, &user) {
 		return
 	}
-	if utf8.RuneCountInString(user.CurrentPassword) > 25 || utf8.RuneCountInString(user.NewPassword) > 25 {
-		w.WriteHeader(http.StatusBadRequest)
+	if utf8.RuneCountInString(user.CurrentPassword) == 0 || utf8.RuneCountInString(user.CurrentPassword) > 25 {
+		errorutil.Invalid(w, r, "currentPassword", "must be 1 to 25 characters")
 		return
 	}
-	if utf8.RuneCountInString(user.CurrentPassword) == 0 || utf8.RuneCountInString(user.NewPassword) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
+	if utf8.RuneCountInString(user.NewPassword) == 0 || utf8.RuneCountInString(user.NewPassword) > 25 {
+		errorutil.Invalid(w, r, "newPassword", "must be 1 to 25 characters")
 		return
 	}
 
@@ -46,93 +44,54 @@ func PatchPassword(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(types.ContextKey("id"))
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 
-	// Retry the transaction on serialization failure.
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	err = txutil.Run(ctx, conn, "user.PatchPassword", func(tx pgx.Tx) error {
 		// Check if the current password matches.
 		var hash string
-		err = tx.QueryRow(ctx, "SELECT password_ FROM user_ WHERE id_ = $1", userID).Scan(&hash)
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
+		err := tx.QueryRow(ctx, "SELECT password_ FROM user_ WHERE id_ = $1", userID).Scan(&hash)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 		match, err := argon2id.ComparePasswordAndHash(user.CurrentPassword, hash)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 		if !match {
-			w.WriteHeader(http.StatusBadRequest)
-			return
+			return errWrongPassword
 		}
 
 		// Hash is salted by default
 		newHash, err := argon2id.CreateHash(user.NewPassword, argon2id.DefaultParams)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 
 		// Change the password in the database.
 		_, err = tx.Exec(ctx, "UPDATE user_ SET password_ = $1 WHERE id_ = $2", newHash, userID)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
+		// Add the event to the user's security log.
+		_, err = tx.Exec(ctx, "CALL add_security_event_(@userID, @type, @ip, @device, @details)",
+			pgx.NamedArgs{"userID": userID, "type": "password_changed", "ip": iputil.GetIP(r), "device": r.UserAgent(), "details": nil})
+		return err
+	})
+	if errors.Is(err, errWrongPassword) {
+		errorutil.Write(w, r, http.StatusBadRequest, types.WrongPasswordCode, types.WrongPassword, nil)
+		return
 	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
@@ -2,22 +2,17 @@ package user
 
 import (
 	db "backend/database"
-	logdb "backend/logdatabase"
 	m "backend/middleware"
 	"backend/types"
-	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
+	"backend/util/errorutil"
+	"backend/util/iputil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"net/http"
 	"strings"
-	"time"
 	"unicode/utf8"
 
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func PatchUsername(w http.ResponseWriter, r *http.Request) {
@@ -25,18 +20,16 @@ func PatchUsername(w http.ResponseWriter, r *http.Request) {
 	// Limit reading the request body up to 1kB.
 	// Input characters get automatically changed to � if they are invalid utf8:
 	// Decode() acts the same as: https://pkg.go.dev/encoding/json#Unmarshal
-	err := json.NewDecoder(io.LimitReader(r.Body, 1000)).Decode(&user)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 1000, &user) {
 		return
 	}
 	user.Username = strings.TrimSpace(user.Username)
 	if utf8.RuneCountInString(user.Username) > 25 {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "username", "must be at most 25 characters")
 		return
 	}
 	if utf8.RuneCountInString(user.Username) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
+		errorutil.Invalid(w, r, "username", "must not be empty")
 		return
 	}
 
@@ -44,73 +37,37 @@ func PatchUsername(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(types.ContextKey("id"))
 
 	// Get a connection from the database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
+	defer conn.Release()
 
-	// Retry the transaction on serialization failure.
-	var i int
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
-
+	err = txutil.Run(ctx, conn, "user.PatchUsername", func(tx pgx.Tx) error {
 		// Change the username in the database.
-		_, err = tx.Exec(ctx, "UPDATE user_ SET username_ = $1 WHERE id_ = $2", user.Username, userID)
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.UniqueViolation {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusConflict)
-			return
-		}
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
+		_, err := tx.Exec(ctx, "UPDATE user_ SET username_ = $1 WHERE id_ = $2", user.Username, userID)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		// Add the event to the user's security log.
+		_, err = tx.Exec(ctx, "CALL add_security_event_(@userID, @type, @ip, @device, @details)",
+			pgx.NamedArgs{"userID": userID, "type": "username_changed", "ip": iputil.GetIP(r), "device": r.UserAgent(), "details": user.Username})
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 
-	if logdb.Pool != nil {
-		// Pass down user's username for deferred logging middleware.
-		meta := r.Context().Value(types.ContextKey("meta")).(*m.RequestMeta)
+	// Pass down user's username for deferred logging middleware.
+	if meta, ok := r.Context().Value(types.ContextKey("meta")).(*m.RequestMeta); ok {
 		meta.Username = user.Username
 	}
 }
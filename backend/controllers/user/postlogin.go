@@ -2,96 +2,127 @@ package user
 
 import (
 	db "backend/database"
-	logdb "backend/logdatabase"
 	m "backend/middleware"
 	"backend/types"
+	c "backend/util/config"
 	"backend/util/cookieutil"
-	"context"
-	"encoding/json"
+	"backend/util/errorutil"
+	"backend/util/iputil"
+	"backend/util/logutil"
+	"backend/util/txutil"
 	"errors"
-	"fmt"
-	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 
 	"github.com/alexedwards/argon2id"
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// Hash compared against when the username does not exist,
+// so that the response takes the same time as for a wrong password.
+var dummyHash, _ = argon2id.CreateHash("file_hosting", argon2id.DefaultParams)
+
+// Log in a user and create a new session.
+// Failed attempts are counted per username from an ip and per ip, and after too many of them
+// the login is locked for some time, see register_login_failure_ procedure.
+// A username is only locked for the ip the attempts came from, so that others cannot lock the user out.
 func PostLogin(w http.ResponseWriter, r *http.Request) {
 	user := user{}
 	// Limit reading the request body up to 1kB.
 	// Input characters get automatically changed to � if they are invalid utf8:
 	// Decode() acts the same as: https://pkg.go.dev/encoding/json#Unmarshal
-	err := json.NewDecoder(io.LimitReader(r.Body, 1000)).Decode(&user)
-	if err != nil {
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	if !errorutil.DecodeJSON(w, r, 1000, &user) {
 		return
 	}
 	user.Username = strings.TrimSpace(user.Username)
-	if utf8.RuneCountInString(user.Username) > 25 || utf8.RuneCountInString(user.Password) > 60 {
-		w.WriteHeader(http.StatusUnprocessableEntity)
+	if utf8.RuneCountInString(user.Username) == 0 || utf8.RuneCountInString(user.Username) > 25 {
+		errorutil.Invalid(w, r, "username", "must be 1 to 25 characters")
 		return
 	}
-	if utf8.RuneCountInString(user.Username) == 0 || utf8.RuneCountInString(user.Password) == 0 {
-		w.WriteHeader(http.StatusUnprocessableEntity)
+	if utf8.RuneCountInString(user.Password) == 0 || utf8.RuneCountInString(user.Password) > 60 {
+		errorutil.Invalid(w, r, "password", "must be 1 to 60 characters")
 		return
 	}
 
 	// Get a connection from the database and start a transaction.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	ctx := r.Context()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	// If commit is not run first this will rollback the transaction.
-	defer tx.Rollback(ctx)
-
-	// Check if the user exists.
+	defer conn.Release()
+	// Check if the username is locked for this ip or the ip is locked after too many failed attempts.
+	ip := iputil.GetIP(r)
+	var lockedUntil *time.Time
 	var userID int
 	var hash string
-	// Get the user's id in case the credentials match.
-	err = tx.QueryRow(ctx, "SELECT id_, password_ FROM user_ WHERE LOWER(username_) = LOWER($1)", user.Username).Scan(&userID, &hash)
-	if err != nil && errors.Is(err, pgx.ErrNoRows) {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
+	found := true
+	err = txutil.RunReadOnly(ctx, conn, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `SELECT MAX(locked_until_) FROM (SELECT locked_until_ FROM account_lockout_ WHERE username_ = LOWER(@username) AND ip_ = @ip 
+		UNION ALL SELECT locked_until_ FROM ip_lockout_ WHERE ip_ = @ip) AS lockout_ WHERE locked_until_ > CURRENT_TIMESTAMP(0)`,
+			pgx.NamedArgs{"username": user.Username, "ip": ip}).Scan(&lockedUntil)
+		if err != nil || lockedUntil != nil {
+			return err
+		}
+
+		// Check if the user exists.
+		// Get the user's id in case the credentials match.
+		err = tx.QueryRow(ctx, "SELECT id_, password_ FROM user_ WHERE LOWER(username_) = LOWER($1)", user.Username).Scan(&userID, &hash)
+		if errors.Is(err, pgx.ErrNoRows) {
+			found = false
+			hash = dummyHash
+			return nil
+		}
+		return err
+	})
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-	err = tx.Commit(ctx)
-	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if lockedUntil != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(*lockedUntil).Seconds())+1))
+		errorutil.Write(w, r, http.StatusTooManyRequests, types.TooManyLoginAttemptsCode, types.TooManyLoginAttempts, nil)
 		return
 	}
 
 	// Check if the credentials match.
+	// The hash is compared even if the user was not found to not reveal that by the response time.
 	match, err := argon2id.ComparePasswordAndHash(user.Password, hash)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
-	if !match {
-		w.WriteHeader(http.StatusBadRequest)
+	if !match || !found {
+		// Count the failed attempt for the username and the ip.
+		err = txutil.Run(ctx, conn, "user.PostLogin", func(tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, "CALL register_login_failure_(@username, @ip, @maxAttempts, @maxIPAttempts, @lockoutTime)",
+				pgx.NamedArgs{"username": user.Username, "ip": ip, "maxAttempts": c.Config.LoginMaxAttempts, "maxIPAttempts": c.Config.LoginMaxIPAttempts, "lockoutTime": c.Config.LoginLockoutTime})
+			if err != nil {
+				return err
+			}
+
+			if found {
+				// Add the event to the user's security log.
+				_, err = tx.Exec(ctx, "CALL add_security_event_(@userID, @type, @ip, @device, @details)",
+					pgx.NamedArgs{"userID": userID, "type": "login_failed", "ip": ip, "device": r.UserAgent(), "details": nil})
+			}
+			return err
+		})
+		if err != nil {
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
+			return
+		}
+
+		// Use the same response for a wrong username and a wrong password.
+		errorutil.Write(w, r, http.StatusUnauthorized, types.InvalidCredentialsCode, types.InvalidCredentials, nil)
 		return
 	}
 
@@ -104,65 +135,42 @@ func PostLogin(w http.ResponseWriter, r *http.Request) {
 	} else {
 		userAgent = r.UserAgent()
 	}
-	var i int
-	// Retry the transaction on serialization failure.
-	for i = 1; i <= 3; i++ {
-		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+	err = txutil.Run(ctx, conn, "user.PostLogin", func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, "CALL create_session_(@userID, @device, @ip, @lifetime, @maxSessions, NULL)",
+			pgx.NamedArgs{"userID": userID, "device": userAgent, "ip": ip, "lifetime": c.Config.SessionLifetime, "maxSessions": c.Config.MaxSessions}).Scan(&refreshToken)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
-		// If commit is not run first this will rollback the transaction.
-		defer tx.Rollback(ctx)
 
-		err = tx.QueryRow(ctx, "INSERT INTO session_ VALUES (DEFAULT, $1, GEN_RANDOM_UUID(), CURRENT_TIMESTAMP(0) + INTERVAL '14 day', $2) RETURNING token_", userID, userAgent).Scan(&refreshToken)
-		var pgErr *pgconn.PgError
-		ok := errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
+		// Reset the failed attempts for the username from this ip after a successful login.
+		_, err = tx.Exec(ctx, "DELETE FROM account_lockout_ WHERE username_ = LOWER($1) AND ip_ = $2", user.Username, ip)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
 
-		err = tx.Commit(ctx)
-		ok = errors.As(err, &pgErr)
-		if ok && pgErr.Code == pgerrcode.SerializationFailure {
-			// End the transaction now to start another transaction.
-			tx.Rollback(ctx)
-			continue
-		}
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		break
-	}
-	if i == 4 {
-		fmt.Println("Failed serializing transaction after", i-1, "times")
-		w.WriteHeader(http.StatusInternalServerError)
+		// Add the event to the user's security log.
+		_, err = tx.Exec(ctx, "CALL add_security_event_(@userID, @type, @ip, @device, @details)",
+			pgx.NamedArgs{"userID": userID, "type": "login", "ip": ip, "device": userAgent, "details": nil})
+		return err
+	})
+	if err != nil {
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 
 	// Create a cookie to be sent.
 	newCookie, err := cookieutil.CreateJWTCookie(userID, refreshToken)
 	if err != nil {
-		fmt.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		logutil.LogError(r.Context(), err)
+		errorutil.Error(w, r, err)
 		return
 	}
 	http.SetCookie(w, newCookie)
 	w.WriteHeader(http.StatusOK)
 
-	if logdb.Pool != nil {
-		// Pass down user's username and id for deferred logging middleware.
-		meta := r.Context().Value(types.ContextKey("meta")).(*m.RequestMeta)
+	// Pass down user's username and id for deferred logging middleware.
+	if meta, ok := r.Context().Value(types.ContextKey("meta")).(*m.RequestMeta); ok {
 		meta.ID = userID
 		meta.Username = user.Username
 	}
@@ -0,0 +1,17 @@
+package wellknown
+
+import (
+	"backend/util/jwtutil"
+	"encoding/json"
+	"net/http"
+)
+
+// Get the public keys used to sign JWTs, so that other services can verify the tokens
+// without the shared secret. Keys using HS256 are not included.
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// Let clients cache the keys for a few minutes, new keys should be added before they are used for signing.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(jwtutil.GetJWKS())
+}
@@ -3,11 +3,12 @@ package database
 import (
 	f "backend/database/functions"
 	p "backend/database/procedures"
+	"backend/tracing"
+	c "backend/util/config"
 	"context"
 	"errors"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -19,8 +20,14 @@ func InitDB() {
 	// Set up a database connection pool.
 	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
 	defer cancel()
-	var err error
-	pool, err = pgxpool.New(ctx, os.Getenv("DB_URL"))
+	config, err := pgxpool.ParseConfig(c.Config.DBURL)
+	if err != nil {
+		fmt.Println("Error parsing DB_URL")
+		log.Fatalln(err)
+	}
+	// Create a span for every query and transaction attempt.
+	config.ConnConfig.Tracer = tracing.NewQueryTracer()
+	pool, err = pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		fmt.Println("Error creating DB connection pool")
 		log.Fatalln(err)
@@ -30,7 +37,7 @@ func InitDB() {
 	ctx, cancel = context.WithTimeout(context.Background(), 4*time.Second)
 	defer cancel()
 	// Make sure procedures/functions are created after any table they use.
-	tables := userSchema + sessionSchema + repositorySchema + fileSchema + filePartSchema + memberSchema
+	tables := userSchema + sessionSchema + repositorySchema + fileSchema + filePartSchema + memberSchema + loginLockoutSchema + rateLimitSchema + securityEventSchema + schemaVersionSchema
 	createSchema := "START TRANSACTION;" + tables + p.CreateProcedures + f.CreateFunctions + "COMMIT;"
 	// Use Exec instead of Query to use multiple statements.
 	_, err = pool.Exec(ctx, createSchema)
@@ -41,8 +48,30 @@ func InitDB() {
 		fmt.Println("Error creating DB schema")
 		log.Fatal(err)
 	}
+	// Only raise the version, so that an older instance started during a deploy does not lower it.
+	_, err = pool.Exec(ctx, `INSERT INTO schema_version_ (version_) VALUES ($1) ON CONFLICT (id_) DO UPDATE
+	SET version_ = EXCLUDED.version_, updated_date_ = CURRENT_TIMESTAMP(0) WHERE schema_version_.version_ < EXCLUDED.version_`, SchemaVersion)
+	if err != nil {
+		fmt.Println("Error saving DB schema version")
+		log.Fatal(err)
+	}
 }
 
 func GetConnection(ctx context.Context) (*pgxpool.Conn, error) {
 	return pool.Acquire(ctx)
 }
+
+// Close all connections, waiting for the acquired ones to be released.
+func Close() {
+	pool.Close()
+}
+
+// Check if the database responds, for example for readiness checks.
+func Ping(ctx context.Context) error {
+	return pool.Ping(ctx)
+}
+
+// Get the connection pool stats, for example for metrics.
+func Stat() *pgxpool.Stat {
+	return pool.Stat()
+}
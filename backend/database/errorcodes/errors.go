@@ -0,0 +1,48 @@
+package errorcodes
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Typed errors for the postgres error codes handled by the controllers, check them with errors.Is.
+var (
+	ErrUserHasNoSpace               = errors.New("user has no space")
+	ErrInsufficientPermission       = errors.New("insufficient permission")
+	ErrPrivilegeNotGranted          = errors.New("privilege not granted")
+	ErrFileAlreadyExists            = errors.New("file already exists")
+	ErrContainingFolderDoesNotExist = errors.New("containing folder does not exist")
+	ErrResourceDoesNotExist         = errors.New("resource does not exist")
+	ErrUniqueViolation              = errors.New("unique violation")
+	ErrSerializationFailure         = errors.New("failed serializing transaction")
+	ErrDeadlockDetected             = errors.New("deadlock detected")
+)
+
+var typedErrors = map[string]error{
+	UserHasNoSpace:                 ErrUserHasNoSpace,
+	InsufficientPermission:         ErrInsufficientPermission,
+	pgerrcode.PrivilegeNotGranted:  ErrPrivilegeNotGranted,
+	FileAlreadyExists:              ErrFileAlreadyExists,
+	ContainingFolderDoesNotExist:   ErrContainingFolderDoesNotExist,
+	ResourceDoesNotExist:           ErrResourceDoesNotExist,
+	pgerrcode.UniqueViolation:      ErrUniqueViolation,
+	pgerrcode.SerializationFailure: ErrSerializationFailure,
+	pgerrcode.DeadlockDetected:     ErrDeadlockDetected,
+}
+
+// Wrap a postgres error with the typed error for its code, keeping the original error in the chain.
+// Other errors are returned unchanged.
+func Map(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	typed, ok := typedErrors[pgErr.Code]
+	if !ok || errors.Is(err, typed) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", typed, err)
+}
@@ -0,0 +1,25 @@
+package procedures
+
+// Count a failed login attempt for the username from the ip it was made from, and for the ip.
+// Once the attempts reach the limit the username (only for that ip) or the ip is locked for lockout_time seconds,
+// and the time is doubled with every further failed attempt, up to a day.
+// Attempts made while locked are rejected before calling this procedure, so they are not counted.
+const registerLoginFailure = `CREATE OR REPLACE PROCEDURE
+register_login_failure_(username TEXT, ip TEXT, max_account_attempts INT, max_ip_attempts INT, lockout_time INT)
+LANGUAGE PLPGSQL
+AS $$
+BEGIN
+	INSERT INTO account_lockout_ AS a (username_, ip_, attempts_, last_attempt_date_) VALUES (LOWER(username), ip, 1, CURRENT_TIMESTAMP(0))
+	ON CONFLICT (username_, ip_) DO UPDATE SET attempts_ = a.attempts_ + 1, last_attempt_date_ = CURRENT_TIMESTAMP(0);
+	UPDATE account_lockout_ SET locked_until_ = CURRENT_TIMESTAMP(0) + 
+	MAKE_INTERVAL(secs => LEAST(lockout_time * POWER(2, LEAST(attempts_ - max_account_attempts, 20)), 86400))
+	WHERE username_ = LOWER(username) AND ip_ = ip AND attempts_ >= max_account_attempts;
+
+	INSERT INTO ip_lockout_ AS i VALUES (DEFAULT, ip, 1, CURRENT_TIMESTAMP(0), NULL)
+	ON CONFLICT (ip_) DO UPDATE SET attempts_ = i.attempts_ + 1, last_attempt_date_ = CURRENT_TIMESTAMP(0);
+	UPDATE ip_lockout_ SET locked_until_ = CURRENT_TIMESTAMP(0) + 
+	MAKE_INTERVAL(secs => LEAST(lockout_time * POWER(2, LEAST(attempts_ - max_ip_attempts, 20)), 86400))
+	WHERE ip_ = ip AND attempts_ >= max_ip_attempts;
+END
+$$;
+`
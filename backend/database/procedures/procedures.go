@@ -3,4 +3,5 @@ package procedures
 // This is the main file to concatenate all queries that create a procedure,
 // and export one string to be executed in db.go init function.
 
-const CreateProcedures = createUserAndSession + createRepository + prepareFile + createFilePart + createMember + prepareFolder + checkPermissionModifyFile + checkPermissionDeleteMember
+const CreateProcedures = createUserAndSession + createRepository + prepareFile + createFilePart + createMember + prepareFolder + checkPermissionModifyFile + checkPermissionDeleteMember +
+	registerLoginFailure + takeRateLimitToken + addSecurityEvent + refreshSession + createSession
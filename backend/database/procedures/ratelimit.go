@@ -0,0 +1,21 @@
+package procedures
+
+// Take a token from a rate limit bucket, refilling it first with capacity tokens per period (in seconds).
+// A new bucket starts full. If there is no whole token to take, allowed is false and nothing is taken.
+// CLOCK_TIMESTAMP() is used instead of CURRENT_TIMESTAMP since the time is needed with ms precision.
+const takeRateLimitToken = `CREATE OR REPLACE PROCEDURE
+take_rate_limit_token_(key TEXT, capacity INT, period DOUBLE PRECISION, OUT allowed BOOLEAN, OUT tokens DOUBLE PRECISION)
+LANGUAGE PLPGSQL
+AS $$
+BEGIN
+	INSERT INTO rate_limit_ VALUES (DEFAULT, key, capacity, CLOCK_TIMESTAMP()) ON CONFLICT (key_) DO NOTHING;
+	SELECT LEAST(capacity, tokens_ + EXTRACT(EPOCH FROM CLOCK_TIMESTAMP() - updated_date_) * capacity / period) INTO tokens 
+	FROM rate_limit_ WHERE key_ = key FOR UPDATE;
+	allowed := tokens >= 1;
+	IF allowed THEN
+		tokens := tokens - 1;
+	END IF;
+	UPDATE rate_limit_ SET tokens_ = tokens, updated_date_ = CLOCK_TIMESTAMP() WHERE key_ = key;
+END
+$$;
+`
@@ -0,0 +1,14 @@
+package procedures
+
+// Add an event to the user's security log, device is truncated to 255 characters.
+// event_type is one of the values of security_event_enum_.
+const addSecurityEvent = `CREATE OR REPLACE PROCEDURE
+add_security_event_(user_id BIGINT, event_type TEXT, ip TEXT, device TEXT, details TEXT)
+LANGUAGE PLPGSQL
+AS $$
+BEGIN
+	INSERT INTO security_event_ (user_id_, type_, ip_, device_, details_) 
+	VALUES (user_id, event_type::security_event_enum_, ip, LEFT(device, 255), details);
+END
+$$;
+`
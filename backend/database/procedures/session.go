@@ -0,0 +1,67 @@
+package procedures
+
+// Rotate the session's refresh token and prolong the session, called when the access token has expired.
+// The old token is kept in session_token_, so that a reuse of it can be detected.
+// The session's last used date and ip are updated, lifetime is the new session lifetime in seconds.
+// Refreshes and revocations are added to the user's security events.
+// status is set to:
+// 'refreshed' - the token was rotated and new_token is the new refresh token,
+// 'reused' - the token was rotated by another request in the last reuse_interval seconds (for example by parallel requests
+// from the same browser), new_token is the session's current token but it should not be sent to the client,
+// 'revoked' - the token was rotated earlier, meaning it was stolen or the client was, so the whole session is revoked,
+// 'invalid' - the token does not belong to any valid session.
+const refreshSession = `CREATE OR REPLACE PROCEDURE
+refresh_session_(user_id BIGINT, token UUID, reuse_interval INT, ip TEXT, lifetime INT, OUT new_token UUID, OUT status TEXT)
+LANGUAGE PLPGSQL
+AS $$
+DECLARE
+	session_id BIGINT;
+	rotated_date TIMESTAMPTZ;
+BEGIN
+	SELECT id_ INTO session_id FROM session_ WHERE user_id_ = user_id AND token_ = token AND 
+	expiry_date_ > CURRENT_TIMESTAMP(0) AND revoked_date_ IS NULL;
+	IF FOUND THEN
+		INSERT INTO session_token_ VALUES (DEFAULT, session_id, token, CURRENT_TIMESTAMP(0));
+		UPDATE session_ SET token_ = GEN_RANDOM_UUID(), expiry_date_ = CURRENT_TIMESTAMP(0) + MAKE_INTERVAL(secs => lifetime), 
+		last_used_date_ = CURRENT_TIMESTAMP(0), last_ip_ = ip WHERE id_ = session_id RETURNING token_ INTO new_token;
+		CALL add_security_event_(user_id, 'token_refreshed', ip, NULL, session_id::TEXT);
+		status := 'refreshed';
+		RETURN;
+	END IF;
+
+	SELECT session_.id_, session_.token_, session_token_.rotated_date_ INTO session_id, new_token, rotated_date FROM session_token_ 
+	JOIN session_ ON session_token_.session_id_ = session_.id_ WHERE session_.user_id_ = user_id AND session_token_.token_ = token AND 
+	session_.expiry_date_ > CURRENT_TIMESTAMP(0) AND session_.revoked_date_ IS NULL;
+	IF NOT FOUND THEN
+		status := 'invalid';
+		RETURN;
+	END IF;
+	IF rotated_date + MAKE_INTERVAL(secs => reuse_interval) >= CURRENT_TIMESTAMP(0) THEN
+		status := 'reused';
+		RETURN;
+	END IF;
+	UPDATE session_ SET revoked_date_ = CURRENT_TIMESTAMP(0), revoked_reason_ = 'token_reuse' WHERE id_ = session_id;
+	CALL add_security_event_(user_id, 'session_revoked', ip, NULL, session_id::TEXT);
+	new_token := NULL;
+	status := 'revoked';
+END
+$$;
+`
+
+// Create a new session for a user, returning its refresh token.
+// If the user has more than max_sessions active sessions, the oldest ones are deleted, max_sessions = 0 disables the limit.
+// Revoked and expired sessions are not counted, so that they cannot push out a session that is still in use.
+const createSession = `CREATE OR REPLACE PROCEDURE
+create_session_(user_id BIGINT, device TEXT, ip TEXT, lifetime INT, max_sessions INT, OUT token UUID)
+LANGUAGE PLPGSQL
+AS $$
+BEGIN
+	INSERT INTO session_ (user_id_, token_, expiry_date_, device_, last_ip_) 
+	VALUES (user_id, GEN_RANDOM_UUID(), CURRENT_TIMESTAMP(0) + MAKE_INTERVAL(secs => lifetime), device, ip) RETURNING token_ INTO token;
+	IF max_sessions > 0 THEN
+		DELETE FROM session_ WHERE id_ IN (SELECT id_ FROM session_ WHERE user_id_ = user_id 
+		AND expiry_date_ > CURRENT_TIMESTAMP(0) AND revoked_date_ IS NULL ORDER BY created_date_ DESC, id_ DESC OFFSET max_sessions);
+	END IF;
+END
+$$;
+`
@@ -29,19 +29,46 @@ CREATE UNIQUE INDEX IF NOT EXISTS UX_user_username_ ON user_ (LOWER(username_));
 `
 
 // Set up a cron job to delete expired refresh tokens.
-// Refresh Tokens currently expire after 14 days.
+// Refresh Tokens expire after SESSION_LIFETIME seconds (14 days by default), prolonged on every refresh.
 // Cron job "*/30 * * * *" means it will run in 30 minute intervals.
 // Template: "minute hour day(of the month) month day(of the week)".
 // UUID and TIMESTAMPTZ should be automatically generated on an insert query.
+// token_ is rotated on every refresh, see refresh_session_ procedure.
+// revoked_date_ is set when the session was revoked, for example when an old refresh token was reused,
+// the session is kept until it expires to be shown to the user with the reason in revoked_reason_.
+// session_token_ keeps the refresh tokens rotated out of a session (the session's token family).
+// last_used_date_ and last_ip_ are updated on every refresh, name_ is a device name set by the user.
 const sessionSchema = `CREATE TABLE IF NOT EXISTS
 session_ (
-	id_			 BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
-	user_id_ 	 BIGINT NOT NULL REFERENCES user_(id_) ON DELETE CASCADE,
-	token_   	 UUID NOT NULL,
-	expiry_date_ TIMESTAMPTZ NOT NULL,
-	device_  	 TEXT
+	id_			 	BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	user_id_ 	 	BIGINT NOT NULL REFERENCES user_(id_) ON DELETE CASCADE,
+	token_   	 	UUID NOT NULL,
+	expiry_date_ 	TIMESTAMPTZ NOT NULL,
+	device_  	 	TEXT,
+	revoked_date_	TIMESTAMPTZ,
+	revoked_reason_ TEXT,
+	created_date_	TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+	last_used_date_ TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+	last_ip_		TEXT,
+	name_			TEXT CHECK (TRIM(name_) <> '')
 );
+ALTER TABLE session_ ADD COLUMN IF NOT EXISTS revoked_date_ TIMESTAMPTZ;
+ALTER TABLE session_ ADD COLUMN IF NOT EXISTS revoked_reason_ TEXT;
+ALTER TABLE session_ ADD COLUMN IF NOT EXISTS created_date_ TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP(0);
+ALTER TABLE session_ ADD COLUMN IF NOT EXISTS last_used_date_ TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP(0);
+ALTER TABLE session_ ADD COLUMN IF NOT EXISTS last_ip_ TEXT;
+ALTER TABLE session_ ADD COLUMN IF NOT EXISTS name_ TEXT CHECK (TRIM(name_) <> '');
 CREATE INDEX IF NOT EXISTS I_session_user_id_token_ ON session_ (user_id_, token_);
+CREATE INDEX IF NOT EXISTS I_session_user_id_created_date_ ON session_ (user_id_, created_date_);
+CREATE TABLE IF NOT EXISTS
+session_token_ (
+	id_			  BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	session_id_	  BIGINT NOT NULL REFERENCES session_(id_) ON DELETE CASCADE,
+	token_		  UUID NOT NULL,
+	rotated_date_ TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS I_session_token_token_ ON session_token_ (token_);
+CREATE INDEX IF NOT EXISTS I_session_token_session_id_ ON session_token_ (session_id_);
 CREATE EXTENSION IF NOT EXISTS pg_cron;
 SELECT cron.schedule('delete_expired_sessions', '*/30 * * * *', $$DELETE FROM session_ WHERE expiry_date_ < CURRENT_TIMESTAMP(0)$$);
 `
@@ -111,3 +138,94 @@ file_part_ (
 );
 CREATE UNIQUE INDEX IF NOT EXISTS UX_file_part_file_id_part_ ON file_part_ (file_id_, part_);
 `
+
+// Failed login attempts are counted per username and ip address pair (also for usernames that do not exist,
+// to not reveal which accounts exist) and per ip address.
+// Locking a username only for the ip the attempts came from keeps an attacker from locking the real user out,
+// the ip lockout limits how many usernames an ip can try.
+// Lockouts kept per username before the ip_ column was added are dropped.
+// locked_until_ is NULL until the attempts reach the limit set in the config.
+// Remove rows that had no failed attempts for a day and are no longer locked.
+// Clean the db every hour.
+const loginLockoutSchema = `CREATE TABLE IF NOT EXISTS
+account_lockout_ (
+	id_				   BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	username_		   TEXT NOT NULL CHECK (TRIM(username_) <> ''),
+	ip_				   TEXT NOT NULL CHECK (TRIM(ip_) <> ''),
+	attempts_		   INT NOT NULL,
+	last_attempt_date_ TIMESTAMPTZ NOT NULL,
+	locked_until_	   TIMESTAMPTZ
+);
+ALTER TABLE account_lockout_ ADD COLUMN IF NOT EXISTS ip_ TEXT CHECK (TRIM(ip_) <> '');
+DELETE FROM account_lockout_ WHERE ip_ IS NULL;
+ALTER TABLE account_lockout_ ALTER COLUMN ip_ SET NOT NULL;
+DROP INDEX IF EXISTS UX_account_lockout_username_;
+CREATE UNIQUE INDEX IF NOT EXISTS UX_account_lockout_username_ip_ ON account_lockout_ (username_, ip_);
+CREATE TABLE IF NOT EXISTS
+ip_lockout_ (
+	id_				   BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	ip_				   TEXT NOT NULL CHECK (TRIM(ip_) <> ''),
+	attempts_		   INT NOT NULL,
+	last_attempt_date_ TIMESTAMPTZ NOT NULL,
+	locked_until_	   TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS UX_ip_lockout_ip_ ON ip_lockout_ (ip_);
+CREATE EXTENSION IF NOT EXISTS pg_cron;
+SELECT cron.schedule('delete_old_account_lockouts', '0 */1 * * *', $$DELETE FROM account_lockout_ WHERE last_attempt_date_ + INTERVAL '1 day' < CURRENT_TIMESTAMP(0) 
+AND (locked_until_ IS NULL OR locked_until_ < CURRENT_TIMESTAMP(0))$$);
+SELECT cron.schedule('delete_old_ip_lockouts', '0 */1 * * *', $$DELETE FROM ip_lockout_ WHERE last_attempt_date_ + INTERVAL '1 day' < CURRENT_TIMESTAMP(0) 
+AND (locked_until_ IS NULL OR locked_until_ < CURRENT_TIMESTAMP(0))$$);
+`
+
+// Token buckets of the rate limit middleware, used when the rate limit store is set to postgres.
+// key_ is the policy name with the user's id or ip.
+// Remove buckets that were not used for a day (those are full anyway).
+const rateLimitSchema = `CREATE TABLE IF NOT EXISTS
+rate_limit_ (
+	id_			  BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	key_		  TEXT NOT NULL CHECK (TRIM(key_) <> ''),
+	tokens_		  DOUBLE PRECISION NOT NULL,
+	updated_date_ TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS UX_rate_limit_key_ ON rate_limit_ (key_);
+CREATE EXTENSION IF NOT EXISTS pg_cron;
+SELECT cron.schedule('delete_old_rate_limits', '0 */1 * * *', $$DELETE FROM rate_limit_ WHERE updated_date_ + INTERVAL '1 day' < CURRENT_TIMESTAMP(0)$$);
+`
+
+// Security events of a user's account shown to the user, for example logins, password changes and session revocations.
+// Unlike the request log it is kept in the main database, since every user can read their own events.
+// details_ holds event specific data, for example the id of a deleted session.
+// Remove events older than a year, every day.
+const securityEventSchema = `
+DO $$BEGIN 
+CREATE TYPE security_event_enum_ AS ENUM ('login', 'login_failed', 'password_changed', 'username_changed', 
+'session_deleted', 'all_sessions_deleted', 'token_refreshed', 'session_revoked');
+EXCEPTION
+    WHEN DUPLICATE_OBJECT THEN NULL;
+END$$;
+CREATE TABLE IF NOT EXISTS
+security_event_ (
+	id_		  BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	user_id_  BIGINT NOT NULL REFERENCES user_(id_) ON DELETE CASCADE,
+	type_	  security_event_enum_ NOT NULL,
+	date_	  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+	ip_		  TEXT,
+	device_	  TEXT,
+	details_  TEXT
+);
+CREATE INDEX IF NOT EXISTS I_security_event_user_id_id_ ON security_event_ (user_id_, id_);
+CREATE EXTENSION IF NOT EXISTS pg_cron;
+SELECT cron.schedule('delete_old_security_events', '0 3 * * *', $$DELETE FROM security_event_ WHERE date_ + INTERVAL '1 year' < CURRENT_TIMESTAMP(0)$$);
+`
+
+// Version of the schema, procedures and functions created by this backend, bump it with every change to them.
+// schema_version_ has a single row with the highest version applied by any instance.
+const SchemaVersion = 2
+
+const schemaVersionSchema = `CREATE TABLE IF NOT EXISTS
+schema_version_ (
+	id_			  BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id_),
+	version_	  INT NOT NULL,
+	updated_date_ TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP(0)
+);
+`
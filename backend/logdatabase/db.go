@@ -1,11 +1,11 @@
 package logdatabase
 
 import (
+	c "backend/util/config"
 	"context"
 	"errors"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -19,7 +19,7 @@ func InitDB() {
 	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
 	defer cancel()
 	var err error
-	Pool, err = pgxpool.New(ctx, os.Getenv("LOG_DB_URL"))
+	Pool, err = pgxpool.New(ctx, c.Config.LogDBURL)
 	if err != nil {
 		fmt.Println("Error creating log DB connection pool")
 		log.Fatalln(err)
@@ -29,7 +29,7 @@ func InitDB() {
 	ctx, cancel = context.WithTimeout(context.Background(), 4*time.Second)
 	defer cancel()
 	// Make sure procedures are created after any table they use.
-	createSchema := "START TRANSACTION;" + logSchema + "COMMIT;"
+	createSchema := "START TRANSACTION;" + logSchema + logDailySchema + "COMMIT;"
 	// Use Exec instead of Query to use multiple statements.
 	_, err = Pool.Exec(ctx, createSchema)
 	if errors.Is(err, context.DeadlineExceeded) {
@@ -39,6 +39,29 @@ func InitDB() {
 		fmt.Println("Error creating log DB schema")
 		log.Fatal(err)
 	}
+
+	// Remove logs older than the retention every hour, replacing the job scheduled with a previous retention.
+	// Cron template: "minute hour day(of the month) month day(of the week)".
+	ctx, cancel = context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+	_, err = Pool.Exec(ctx, `SELECT cron.schedule('delete_old_logs', '0 */1 * * *',
+	FORMAT('DELETE FROM log_ WHERE date_ + INTERVAL ''%s day'' < CURRENT_TIMESTAMP(0)', $1::INT))`, c.Config.LogRetentionDays)
+	if err != nil {
+		fmt.Println("Error scheduling log retention")
+		log.Fatal(err)
+	}
+}
+
+// Close all connections, waiting for the acquired ones to be released.
+func Close() {
+	if Pool != nil {
+		Pool.Close()
+	}
+}
+
+// Check if the log database responds, for example for readiness checks.
+func Ping(ctx context.Context) error {
+	return Pool.Ping(ctx)
 }
 
 func GetConnection(ctx context.Context) (*pgxpool.Conn, error) {
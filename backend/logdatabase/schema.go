@@ -5,11 +5,11 @@ package logdatabase
 // Keywords are written with uppercase for easy reading.
 
 // This is the main logging table.
-// Cron template: "minute hour day(of the month) month day(of the week)".
-// Remove logs older than 30 days.
-// Clean the db every hour.
+// Logs older than LOG_RETENTION_DAYS are removed every hour by a cron job scheduled in InitDB.
 // method_ is the http method used, for example "POST".
 // time_ is the time in milliseconds it took to complete a request.
+// endpoint_ is the request path and route_ the matched route pattern, for example "/api/repository/{id}".
+// error_code_ is machine-readable, for example "db_40001", error_message_ has the details.
 const logSchema = `CREATE TABLE IF NOT EXISTS
 log_ (
 	id_		  INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
@@ -20,10 +20,62 @@ log_ (
 	time_	  REAL NOT NULL,
 	endpoint_ TEXT NOT NULL CHECK (TRIM(endpoint_) <> ''),
 	method_	  TEXT NOT NULL CHECK (TRIM(method_) <> ''),
-	status_	  INT NOT NULL
+	status_	  INT NOT NULL,
+	route_	  TEXT,
+	bytes_	  BIGINT,
+	request_id_ TEXT,
+	user_agent_ TEXT,
+	error_code_ TEXT,
+	error_message_ TEXT
 );
+ALTER TABLE log_ ADD COLUMN IF NOT EXISTS route_ TEXT;
+ALTER TABLE log_ ADD COLUMN IF NOT EXISTS bytes_ BIGINT;
+ALTER TABLE log_ ADD COLUMN IF NOT EXISTS request_id_ TEXT;
+ALTER TABLE log_ ADD COLUMN IF NOT EXISTS user_agent_ TEXT;
+ALTER TABLE log_ ADD COLUMN IF NOT EXISTS error_code_ TEXT;
+ALTER TABLE log_ ADD COLUMN IF NOT EXISTS error_message_ TEXT;
+CREATE INDEX IF NOT EXISTS I_log_request_id_ ON log_ (request_id_);
 CREATE INDEX IF NOT EXISTS I_log_date_ ON log_ (date_);
 CREATE INDEX IF NOT EXISTS I_log_user_id_ ON log_ (user_id_);
+CREATE INDEX IF NOT EXISTS I_log_ip_ ON log_ (ip_);
+CREATE EXTENSION IF NOT EXISTS pg_cron;
+`
+
+// Daily aggregates per endpoint, so that trends survive after the raw logs are removed.
+// route_ is the route pattern, or the request path if no route matched.
+// Cron template: "minute hour day(of the month) month day(of the week)".
+// Roll up every day that ended and is not aggregated yet, a few minutes after midnight UTC.
+const logDailySchema = `CREATE TABLE IF NOT EXISTS
+log_daily_ (
+	day_			DATE NOT NULL,
+	route_			TEXT NOT NULL,
+	method_			TEXT NOT NULL,
+	requests_		BIGINT NOT NULL,
+	client_errors_	BIGINT NOT NULL,
+	server_errors_	BIGINT NOT NULL,
+	users_			BIGINT NOT NULL,
+	bytes_			BIGINT NOT NULL,
+	avg_time_		REAL NOT NULL,
+	p50_time_		REAL NOT NULL,
+	p95_time_		REAL NOT NULL,
+	p99_time_		REAL NOT NULL,
+	PRIMARY KEY (day_, route_, method_)
+);
+CREATE OR REPLACE PROCEDURE roll_up_logs_()
+LANGUAGE SQL
+AS $$
+INSERT INTO log_daily_
+SELECT (date_ AT TIME ZONE 'UTC')::DATE, COALESCE(route_, endpoint_), method_, COUNT(*),
+COUNT(*) FILTER (WHERE status_ BETWEEN 400 AND 499), COUNT(*) FILTER (WHERE status_ >= 500),
+COUNT(DISTINCT user_id_) FILTER (WHERE user_id_ <> 0), COALESCE(SUM(bytes_), 0), AVG(time_),
+PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY time_), PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY time_),
+PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY time_)
+FROM log_
+WHERE date_ < (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')::DATE::TIMESTAMP AT TIME ZONE 'UTC'
+AND date_ >= COALESCE((SELECT MAX(day_) + 1 FROM log_daily_)::TIMESTAMP AT TIME ZONE 'UTC', '-infinity')
+GROUP BY 1, 2, 3
+ON CONFLICT DO NOTHING;
+$$;
 CREATE EXTENSION IF NOT EXISTS pg_cron;
-SELECT cron.schedule('delete_old_logs', '0 */1 * * *', $$DELETE FROM log_ WHERE date_ + INTERVAL '30 day' < CURRENT_TIMESTAMP(0)$$);
+SELECT cron.schedule('roll_up_logs', '10 0 * * *', $$CALL roll_up_logs_()$$);
 `
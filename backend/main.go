@@ -1,63 +1,183 @@
 package main
 
 import (
+	"backend/controllers/health"
 	db "backend/database"
 	logdb "backend/logdatabase"
+	"backend/metrics"
 	m "backend/middleware"
 	"backend/routes"
 	"backend/storage"
+	"backend/tracing"
+	c "backend/util/config"
+	"backend/util/healthutil"
+	"backend/util/iputil"
+	"backend/util/jwtutil"
+	"backend/util/logutil"
+	"backend/util/tlsutil"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
 func main() {
+	printConfig := flag.Bool("print-config", false, "print the configuration with secrets hidden and exit")
+	flag.Parse()
+	if *printConfig {
+		c.Print(os.Stdout)
+	}
+	// Check all settings before starting anything, so that every problem is reported at once.
+	err := c.Validate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:")
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		return
+	}
+
+	// Log JSON records with the level set by LOG_LEVEL.
+	logutil.InitLogger()
+	jwtutil.InitKeys()
+	iputil.InitTrustedProxies()
+	m.InitRateLimiter()
 	r := chi.NewRouter()
-	r.Use(m.DBRequestLogger, m.RequestLogger)
-	r.Mount("/api/user", routes.InitUser())
-	r.Mount("/api/session", routes.InitSession())
-	r.Mount("/api/admin", routes.InitAdmin())
-	r.Mount("/api/repository", routes.InitRepository())
-	r.Mount("/api/file", routes.InitFile())
-	r.Mount("/api/member", routes.InitMember())
+	// Liveness and readiness checks are not logged or measured, they are requested every few seconds.
+	r.Get("/healthz", health.GetHealth)
+	r.Get("/readyz", health.GetReady)
+	r.Group(func(r chi.Router) {
+		r.Use(m.ClientIP, m.RequestID, m.Tracing, m.Metrics, m.DBRequestLogger, m.RequestLogger)
+		routes.MountAPI(r)
+	})
 
 	p := http.Protocols{}
 	p.SetHTTP1(true)
-	// Below is https setup.
-	// p.SetHTTP2(true)
-
-	// Below is https setup.
-	// Generate a private key for TLS connections.
-	// c := []tls.Certificate{}
-	// privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	// if err != nil {
-	// 	fmt.Println("Cannot generate RSA key")
-	// 	log.Fatalln(err)
-	// }
-	// c = append(c, tls.Certificate{PrivateKey: privateKey})
-
 	server := http.Server{
-		Addr:    os.Getenv("SERVER_HOST"),
+		Addr:    c.Config.ServerHost,
 		Handler: r,
 		// Max size is 2^13 Bytes being about 8 kB.
 		MaxHeaderBytes: 1 << 13,
 		Protocols:      &p,
 		// Close the keep-alive connection after receiving no requests for some time.
-		IdleTimeout:  time.Minute * 3,
-		ReadTimeout:  time.Second * 10,
-		WriteTimeout: time.Second * 10,
-		// Below is https setup.
-		// TLSConfig:    &tls.Config{Certificates: c},
+		IdleTimeout:  time.Second * time.Duration(c.Config.ServerIdleTimeout),
+		ReadTimeout:  time.Second * time.Duration(c.Config.ServerReadTimeout),
+		WriteTimeout: time.Second * time.Duration(c.Config.ServerWriteTimeout),
 	}
+	// Serve HTTPS natively if a certificate is set, otherwise TLS is left to a proxy like nginx.
+	var certReloader *tlsutil.CertReloader
+	if c.Config.TLSCertFile != "" {
+		certReloader, err = tlsutil.NewCertReloader(c.Config.TLSCertFile, c.Config.TLSKeyFile)
+		if err != nil {
+			log.Fatalln("Error loading the TLS certificate:", err)
+		}
+		p.SetHTTP2(true)
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: certReloader.GetCertificate}
+	}
+	servers := []*http.Server{&server}
+	// Redirect HTTP to HTTPS, liveness and readiness checks are also served over HTTP for probes.
+	if c.Config.HTTPRedirectHost != "" {
+		_, httpsPort, _ := net.SplitHostPort(c.Config.ServerHost)
+		redirect := chi.NewRouter()
+		redirect.Get("/healthz", health.GetHealth)
+		redirect.Get("/readyz", health.GetReady)
+		redirect.Handle("/*", tlsutil.RedirectHandler(httpsPort))
+		servers = append(servers, &http.Server{
+			Addr:              c.Config.HTTPRedirectHost,
+			Handler:           redirect,
+			MaxHeaderBytes:    1 << 13,
+			ReadHeaderTimeout: time.Second * 10,
+			IdleTimeout:       time.Second * time.Duration(c.Config.ServerIdleTimeout),
+		})
+	}
+	// Tracing is disabled if TRACING_EXPORTER is not set.
+	tracing.InitTracing()
 	storage.InitStorage()
 	db.InitDB()
-	// This is optional, you can disable logging by removing this line.
-	logdb.InitDB()
-	fmt.Println("Connected to DB, starting server")
-	fmt.Println(server.ListenAndServe())
-	// Below is https setup.
-	// fmt.Println(server.ListenAndServeTLS("util/cert.pem", "util/key.pem"))
+	// Logging requests to the log database is optional, it is disabled if LOG_DB_URL is not set.
+	if c.Config.LogDBURL != "" {
+		logdb.InitDB()
+	}
+	// Save request logs in batches from a background goroutine.
+	logutil.StartWriter()
+	// Serve /metrics on METRICS_HOST, disabled if it is not set.
+	metrics.Serve()
+
+	// Stop on SIGTERM (sent on deploys) or SIGINT (ctrl+c).
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	if certReloader != nil {
+		// Load a renewed certificate without a restart.
+		go certReloader.Watch(ctx, time.Second*time.Duration(c.Config.TLSReloadInterval))
+	}
+	slog.Info("Connected to DB, starting server", "tls", certReloader != nil)
+	for _, server := range servers {
+		go func() {
+			var err error
+			if server.TLSConfig != nil {
+				// The certificate is set with GetCertificate.
+				err = server.ListenAndServeTLS("", "")
+			} else {
+				err = server.ListenAndServe()
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Server stopped", "address", server.Addr, "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+	<-ctx.Done()
+	// A second signal stops the process right away.
+	stop()
+	shutdown(servers)
+}
+
+// Stop accepting connections and wait for the requests in progress to finish, then stop the background workers
+// and close the connection pools. Shutdown does not cancel the requests in progress, they run until they finish
+// or hit their route's deadline, and only the ones left when the timeout runs out are canceled by closing their connections.
+func shutdown(servers []*http.Server) {
+	timeout := time.Second * time.Duration(c.Config.ShutdownTimeout)
+	slog.Info("Shutting down", "timeout", timeout.String())
+	healthutil.SetShuttingDown()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for _, server := range servers {
+		err := server.Shutdown(ctx)
+		if err != nil {
+			slog.Error("Requests did not finish in time, closing their connections", "address", server.Addr, "error", err)
+			server.Close()
+		}
+	}
+	err := metrics.Shutdown(ctx)
+	if err != nil {
+		slog.Error("Error stopping the metrics server", "error", err)
+	}
+	// Flush the queued request logs before the log database pool is closed.
+	// Give it a few seconds even if draining used up the timeout.
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer flushCancel()
+	err = logutil.StopWriter(flushCtx)
+	if err != nil {
+		slog.Error("Error flushing request logs", "error", err)
+	}
+	err = tracing.Shutdown(flushCtx)
+	if err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
+	logdb.Close()
+	db.Close()
+	slog.Info("Server stopped")
 }
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	db "backend/database"
+	logdb "backend/logdatabase"
+	"backend/util/logutil"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collect pgxpool stats of the main and the log database pools on every scrape.
+type poolCollector struct {
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	totalConns        *prometheus.Desc
+	maxConns          *prometheus.Desc
+	acquireCount      *prometheus.Desc
+	acquireDuration   *prometheus.Desc
+	emptyAcquireCount *prometheus.Desc
+}
+
+func newPoolCollector() *poolCollector {
+	labels := []string{"pool"}
+	return &poolCollector{
+		acquiredConns:     prometheus.NewDesc(namespace+"_db_pool_acquired_connections", "Connections currently in use.", labels, nil),
+		idleConns:         prometheus.NewDesc(namespace+"_db_pool_idle_connections", "Idle connections in the pool.", labels, nil),
+		totalConns:        prometheus.NewDesc(namespace+"_db_pool_total_connections", "All open connections in the pool.", labels, nil),
+		maxConns:          prometheus.NewDesc(namespace+"_db_pool_max_connections", "Maximum size of the pool.", labels, nil),
+		acquireCount:      prometheus.NewDesc(namespace+"_db_pool_acquires_total", "Number of successful connection acquires.", labels, nil),
+		acquireDuration:   prometheus.NewDesc(namespace+"_db_pool_acquire_duration_seconds_total", "Total time spent acquiring connections.", labels, nil),
+		emptyAcquireCount: prometheus.NewDesc(namespace+"_db_pool_empty_acquires_total", "Acquires that had to wait for a connection.", labels, nil),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collectPool(ch, "main", db.Stat())
+	// The log database is optional.
+	if logdb.Pool != nil {
+		c.collectPool(ch, "log", logdb.Pool.Stat())
+	}
+}
+
+func (c *poolCollector) collectPool(ch chan<- prometheus.Metric, name string, stat *pgxpool.Stat) {
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()), name)
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()), name)
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()), name)
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()), name)
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()), name)
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), name)
+}
+
+// Reuse the upload stats for this long, the query sums every file so it is not run on every scrape.
+const uploadStatsMaxAge = time.Second * 30
+
+// Collect the uploaded bytes and the number of in-progress uploads from the database at most every uploadStatsMaxAge,
+// so that the values are shared between backend instances and survive restarts.
+type uploadCollector struct {
+	uploadedBytes     *prometheus.Desc
+	inProgressUploads *prometheus.Desc
+
+	// Protects the stats of the last query, a concurrent scrape waits for it instead of running another one.
+	mu                    sync.Mutex
+	updated               time.Time
+	lastUploadedBytes     int64
+	lastInProgressUploads int64
+}
+
+func newUploadCollector() *uploadCollector {
+	return &uploadCollector{
+		uploadedBytes:     prometheus.NewDesc(namespace+"_uploaded_bytes", "Size of all uploaded files in bytes.", nil, nil),
+		inProgressUploads: prometheus.NewDesc(namespace+"_in_progress_uploads", "Number of uploads that were started and not completed.", nil, nil),
+	}
+}
+
+func (c *uploadCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *uploadCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.updated) >= uploadStatsMaxAge {
+		err := c.update()
+		if err != nil {
+			logutil.LogError(context.Background(), err)
+			return
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.uploadedBytes, prometheus.GaugeValue, float64(c.lastUploadedBytes))
+	ch <- prometheus.MustNewConstMetric(c.inProgressUploads, prometheus.GaugeValue, float64(c.lastInProgressUploads))
+}
+
+func (c *uploadCollector) update() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	conn, err := db.GetConnection(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var uploadedBytes, inProgressUploads int64
+	err = conn.QueryRow(ctx, `SELECT COALESCE(SUM(size_) FILTER (WHERE upload_date_ IS NOT NULL), 0), COUNT(*) FILTER (WHERE upload_date_ IS NULL) 
+	FROM file_ WHERE type_ = 'file'::file_type_enum_`).Scan(&uploadedBytes, &inProgressUploads)
+	if err != nil {
+		return err
+	}
+	c.lastUploadedBytes, c.lastInProgressUploads = uploadedBytes, inProgressUploads
+	c.updated = time.Now()
+	return nil
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	c "backend/util/config"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "file_hosting"
+
+var (
+	// Requests by chi route pattern, for example "/api/repository/{id}".
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled HTTP requests.",
+	}, []string{"route", "method", "status"})
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle an HTTP request.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// Calls to s3 storage, operation is the name of the function in the storage package.
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Time taken by a storage (s3) operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+	StorageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_errors_total",
+		Help:      "Number of failed storage (s3) operations.",
+	}, []string{"operation"})
+
+	// Transactions retried after a serialization failure or a deadlock, handler is the controller (or middleware) running them.
+	SerializationRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "serialization_retries_total",
+		Help:      "Number of transactions retried after a serialization failure or a deadlock.",
+	}, []string{"handler"})
+	// Transactions that failed to serialize (or deadlocked) on all the attempts.
+	SerializationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "serialization_failures_total",
+		Help:      "Number of transactions that failed serializing after all retries.",
+	}, []string{"handler"})
+)
+
+// Record the duration of a storage operation and count it as failed if *err is not nil.
+// Use it with defer and a named error return value.
+func ObserveStorage(operation string, start time.Time, err *error) {
+	StorageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		StorageErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+var server *http.Server
+
+// Serve /metrics on METRICS_HOST, separately from the api so that it is not exposed with it.
+// Metrics are disabled if METRICS_HOST is not set.
+func Serve() {
+	host := c.Config.MetricsHost
+	if host == "" {
+		return
+	}
+	prometheus.MustRegister(newPoolCollector(), newUploadCollector())
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	server = &http.Server{
+		Addr:         host,
+		Handler:      mux,
+		ReadTimeout:  time.Second * 10,
+		WriteTimeout: time.Second * 10,
+	}
+	go func() {
+		err := server.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server stopped", "error", err)
+		}
+	}()
+}
+
+// Stop the metrics server, waiting for scrapes in progress.
+func Shutdown(ctx context.Context) error {
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
@@ -3,11 +3,10 @@ package middleware
 import (
 	db "backend/database"
 	"backend/types"
-	"context"
+	"backend/util/errorutil"
+	"backend/util/logutil"
 	"errors"
-	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -19,19 +18,18 @@ func Admin(next http.Handler) http.Handler {
 		userID := r.Context().Value(types.ContextKey("id"))
 
 		// Get a connection from the database and start a transaction.
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-		defer cancel()
+		ctx := r.Context()
 		conn, err := db.GetConnection(ctx)
-		defer conn.Release()
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
 			return
 		}
+		defer conn.Release()
 		tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable})
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
 			return
 		}
 		// If commit is not run first this will rollback the transaction.
@@ -41,19 +39,19 @@ func Admin(next http.Handler) http.Handler {
 		var admin int
 		err = tx.QueryRow(ctx, "SELECT 1 FROM user_ WHERE id_ = $1 AND role_ = 'admin'", userID).Scan(&admin)
 		if errors.Is(err, pgx.ErrNoRows) {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusUnauthorized)
+			logutil.LogError(r.Context(), err)
+			errorutil.Status(w, r, http.StatusUnauthorized)
 			return
 		}
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
 			return
 		}
 		err = tx.Commit(ctx)
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
 			return
 		}
 		next.ServeHTTP(w, r)
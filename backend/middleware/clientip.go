@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"backend/types"
+	"backend/util/iputil"
+	"context"
+	"net/http"
+)
+
+// Resolve the client's ip once, behind the proxies trusted in TRUSTED_PROXIES, and save it in the context
+// for iputil.GetIP, which is used by logging, sessions and rate limiting.
+func ClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), types.ContextKey("ip"), iputil.ResolveIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
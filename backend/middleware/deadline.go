@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Cancel the request's context after the route's deadline, so database and storage calls stop when it runs out
+// or when the client disconnects. The connection's write deadline is moved past it for routes that can take longer
+// than SERVER_WRITE_TIMEOUT, leaving time to respond with an error.
+func Deadline(seconds int) func(http.Handler) http.Handler {
+	timeout := time.Second * time.Duration(seconds)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + time.Second*5))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
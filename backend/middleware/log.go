@@ -3,69 +3,103 @@ package middleware
 import (
 	logdb "backend/logdatabase"
 	"backend/types"
-	"context"
-	"strings"
+	"log/slog"
 
+	"backend/util/iputil"
 	"backend/util/logutil"
-	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-// Logger for dev.
+// Log every request as a structured record, with the user's id if the request was authenticated.
 func RequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
 		t1 := time.Now()
 		defer func() {
-			fmt.Println("log:", r.Proto, "sent", ww.BytesWritten(), "bytes in", time.Since(t1), r.URL.Path+" "+r.Method, ww.Status())
+			attrs := []slog.Attr{
+				slog.String("proto", r.Proto),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", ww.Status()),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Float64("duration_ms", float64(time.Since(t1).Microseconds())/1000),
+			}
+			if meta, ok := r.Context().Value(types.ContextKey("meta")).(*RequestMeta); ok && meta.ID != 0 {
+				attrs = append(attrs, slog.Int("user_id", meta.ID))
+			}
+			slog.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
 		}()
 
 		next.ServeHTTP(ww, r)
 	})
 }
 
+// Created by the RequestID middleware for controllers to pass data to the logging middleware.
+// ErrorCode is machine-readable, for example "db_23505" or "file_already_exists".
 type RequestMeta struct {
-	ID       int
-	Username string
+	RequestID    string
+	ID           int
+	Username     string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// Save the error returned to the client, replacing an error saved by logutil.LogError.
+func (meta *RequestMeta) SetError(code, message string) {
+	meta.ErrorCode = code
+	meta.ErrorMessage = message
 }
 
-// TODO: add error messages to controllers and save them to RequestMeta
+// Save an error logged with logutil.LogError, only the first one is kept since later ones are usually caused by it.
+func (meta *RequestMeta) SetLoggedError(code, message string) {
+	if meta.ErrorCode != "" {
+		return
+	}
+	meta.ErrorCode = code
+	meta.ErrorMessage = message
+}
 
 // Log all requests into log db.
 func DBRequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if logdb.Pool == nil {
+		// Controllers write to the meta created by the RequestID middleware,
+		// then it is logged in this middleware. Without it there is nothing to log.
+		meta, ok := r.Context().Value(types.ContextKey("meta")).(*RequestMeta)
+		if logdb.Pool == nil || !ok {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-		// Create a struct to be passed in context for a controller to write to it,
-		// then to be logged in middleware.
-		meta := &RequestMeta{}
-		ctx := context.WithValue(r.Context(), types.ContextKey("meta"), meta)
 
 		t1 := time.Now()
 		defer func() {
 			if logdb.Pool != nil {
 				// Pass the execution time with float accuracy by diving by 1000.
-				ip := r.Header.Get("X-Real-IP")
-				if ip == "" {
-					r.Header.Get("X-Forwarded-For")
-					ips := strings.Split(ip, ",")
-					ip = strings.TrimSpace(ips[0])
-				}
-				if ip == "" {
-					ip = r.RemoteAddr
-				}
-				logutil.Log(ip, meta.ID, meta.Username, float64(time.Since(t1).Microseconds())/1000, r.URL.Path, r.Method, ww.Status())
+				logutil.Log(logutil.Entry{
+					Date:          t1,
+					IP:            iputil.GetIP(r),
+					UserID:        meta.ID,
+					Username:      meta.Username,
+					ExecutionTime: float64(time.Since(t1).Microseconds()) / 1000,
+					Endpoint:      r.URL.Path,
+					Route:         chi.RouteContext(r.Context()).RoutePattern(),
+					Method:        r.Method,
+					Status:        ww.Status(),
+					Bytes:         ww.BytesWritten(),
+					RequestID:     meta.RequestID,
+					UserAgent:     r.UserAgent(),
+					ErrorCode:     meta.ErrorCode,
+					ErrorMessage:  meta.ErrorMessage,
+				})
 			}
 		}()
 
-		next.ServeHTTP(ww, r.WithContext(ctx))
+		next.ServeHTTP(ww, r)
 	})
 }
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"backend/metrics"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Count requests and measure their latency per chi route pattern.
+// The pattern is used instead of the path to not create a time series for every id.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		t1 := time.Now()
+		defer func() {
+			// The pattern is known only after the request was routed.
+			route := chi.RouteContext(r.Context()).RoutePattern()
+			if route == "" {
+				route = "unmatched"
+			}
+			metrics.RequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(ww.Status())).Inc()
+			metrics.RequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(t1).Seconds())
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
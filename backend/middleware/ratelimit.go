@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"backend/types"
+	c "backend/util/config"
+	"backend/util/errorutil"
+	"backend/util/iputil"
+	"backend/util/logutil"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Token bucket rate limit for a route.
+// The bucket holds up to Limit tokens and is refilled with Limit tokens every Period,
+// each request takes one token.
+type RateLimitPolicy struct {
+	Name   string // Routes with the same name share the same buckets.
+	Limit  int
+	Period time.Duration
+}
+
+// Result of taking a token from a bucket.
+type rateLimitResult struct {
+	Allowed bool
+	Tokens  float64 // Tokens left in the bucket.
+}
+
+type rateLimitStore interface {
+	Take(ctx context.Context, key string, policy RateLimitPolicy) (rateLimitResult, error)
+}
+
+var rateLimiter rateLimitStore
+
+// Create the store of the buckets set by RATE_LIMIT_STORE.
+// Use the postgres store to share the limits between multiple backend instances.
+func InitRateLimiter() {
+	rateLimiter = newRateLimitStore(c.Config.RateLimitStore)
+}
+
+func newRateLimitStore(option string) rateLimitStore {
+	if option == "postgres" {
+		return postgresStore{}
+	}
+	return newMemoryStore()
+}
+
+// Limit requests to the route with a token bucket for each user, or for each ip if the user is not logged in.
+// Use after the auth middleware to limit logged in users by their id.
+// Sets the RateLimit-* headers, and Retry-After when the limit is exceeded.
+func RateLimit(policy RateLimitPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := policy.Name + ":ip:" + iputil.GetIP(r)
+			if userID, ok := r.Context().Value(types.ContextKey("id")).(int); ok {
+				key = policy.Name + ":user:" + strconv.Itoa(userID)
+			}
+
+			ctx := r.Context()
+			result, err := rateLimiter.Take(ctx, key, policy)
+			if err != nil {
+				logutil.LogError(r.Context(), err)
+				errorutil.Error(w, r, err)
+				return
+			}
+
+			// Time it takes to refill a single token.
+			tokenTime := policy.Period.Seconds() / float64(policy.Limit)
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Period.Seconds())))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(result.Tokens)))
+			// Seconds until the bucket is full again.
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil((float64(policy.Limit)-result.Tokens)*tokenTime))))
+			if !result.Allowed {
+				// Seconds until there is a token to take.
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil((1-result.Tokens)*tokenTime))))
+				errorutil.Write(w, r, http.StatusTooManyRequests, types.TooManyRequestsCode, types.TooManyRequests, nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"backend/types"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// A memory store with a clock that only moves when the test moves it.
+func newTestStore() (*memoryStore, *time.Time) {
+	now := time.Unix(1000, 0)
+	s := &memoryStore{buckets: map[string]*bucket{}, now: func() time.Time { return now }}
+	return s, &now
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	s, now := newTestStore()
+	// A token every second.
+	policy := RateLimitPolicy{Name: "test", Limit: 3, Period: time.Second * 3}
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		key     string
+		allowed bool
+		tokens  float64
+	}{
+		{name: "full bucket", key: "a", allowed: true, tokens: 2},
+		{name: "second token", key: "a", allowed: true, tokens: 1},
+		{name: "last token", key: "a", allowed: true, tokens: 0},
+		{name: "exhausted", key: "a", allowed: false, tokens: 0},
+		{name: "other key has its own bucket", key: "b", allowed: true, tokens: 2},
+		{name: "half a token refilled", advance: time.Millisecond * 500, key: "a", allowed: false, tokens: 0.5},
+		{name: "a token refilled", advance: time.Millisecond * 500, key: "a", allowed: true, tokens: 0},
+		{name: "refilled up to the limit", advance: time.Minute, key: "a", allowed: true, tokens: 2},
+	}
+	for _, step := range steps {
+		*now = now.Add(step.advance)
+		result, err := s.Take(context.Background(), step.key, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != step.allowed || result.Tokens != step.tokens {
+			t.Fatalf("%s: got allowed %t with %g tokens, want %t with %g", step.name, result.Allowed, result.Tokens, step.allowed, step.tokens)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s, now := newTestStore()
+	oldLimiter := rateLimiter
+	t.Cleanup(func() { rateLimiter = oldLimiter })
+	rateLimiter = s
+
+	// A token every 2 seconds.
+	policy := RateLimitPolicy{Name: "test", Limit: 2, Period: time.Second * 4}
+	handler := RateLimit(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func(remoteAddr string, userID int) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+		if userID != 0 {
+			r = r.WithContext(context.WithValue(r.Context(), types.ContextKey("id"), userID))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	steps := []struct {
+		name       string
+		advance    time.Duration
+		remoteAddr string
+		userID     int
+		status     int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{name: "first request", remoteAddr: "203.0.113.5:1", status: 200, remaining: "1", reset: "2"},
+		{name: "last token", remoteAddr: "203.0.113.5:2", status: 200, remaining: "0", reset: "4"},
+		{name: "limited", remoteAddr: "203.0.113.5:3", status: 429, remaining: "0", reset: "4", retryAfter: "2"},
+		{name: "limited with half a token", advance: time.Second, remoteAddr: "203.0.113.5:3", status: 429, remaining: "0", reset: "3", retryAfter: "1"},
+		{name: "other ip", remoteAddr: "203.0.113.6:1", status: 200, remaining: "1", reset: "2"},
+		{name: "logged in user from the limited ip", remoteAddr: "203.0.113.5:4", userID: 7, status: 200, remaining: "1", reset: "2"},
+		{name: "refilled", advance: time.Second, remoteAddr: "203.0.113.5:5", status: 200, remaining: "0", reset: "4"},
+	}
+	for _, step := range steps {
+		*now = now.Add(step.advance)
+		w := request(step.remoteAddr, step.userID)
+		header := w.Header()
+		if w.Code != step.status {
+			t.Fatalf("%s: got status %d, want %d", step.name, w.Code, step.status)
+		}
+		if header.Get("RateLimit-Limit") != "2" || header.Get("RateLimit-Policy") != "2;w=4" {
+			t.Fatalf("%s: got RateLimit-Limit %q and RateLimit-Policy %q", step.name, header.Get("RateLimit-Limit"), header.Get("RateLimit-Policy"))
+		}
+		if header.Get("RateLimit-Remaining") != step.remaining || header.Get("RateLimit-Reset") != step.reset {
+			t.Fatalf("%s: got RateLimit-Remaining %q and RateLimit-Reset %q, want %q and %q", step.name,
+				header.Get("RateLimit-Remaining"), header.Get("RateLimit-Reset"), step.remaining, step.reset)
+		}
+		if header.Get("Retry-After") != step.retryAfter {
+			t.Fatalf("%s: got Retry-After %q, want %q", step.name, header.Get("Retry-After"), step.retryAfter)
+		}
+	}
+}
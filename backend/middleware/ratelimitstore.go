@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	db "backend/database"
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Keep the buckets in memory, the limits are separate for each backend instance.
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time // Replaced in tests.
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{buckets: map[string]*bucket{}, now: time.Now}
+	go s.clean()
+	return s
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (rateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{Tokens: float64(policy.Limit), Updated: now}
+		s.buckets[key] = b
+	}
+	// Refill the tokens for the time since the last request.
+	b.Tokens = min(float64(policy.Limit), b.Tokens+now.Sub(b.Updated).Seconds()*float64(policy.Limit)/policy.Period.Seconds())
+	b.Updated = now
+	if b.Tokens < 1 {
+		return rateLimitResult{Allowed: false, Tokens: b.Tokens}, nil
+	}
+	b.Tokens--
+	return rateLimitResult{Allowed: true, Tokens: b.Tokens}, nil
+}
+
+// Remove buckets that were not used for an hour, every few minutes.
+// No policy should have a period longer than an hour, so those buckets are full anyway.
+func (s *memoryStore) clean() {
+	for range time.Tick(time.Minute * 5) {
+		s.mu.Lock()
+		for key, b := range s.buckets {
+			if time.Since(b.Updated) > time.Hour {
+				delete(s.buckets, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Keep the buckets in the database, the limits are shared between all backend instances.
+type postgresStore struct{}
+
+func (postgresStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (rateLimitResult, error) {
+	conn, err := db.GetConnection(ctx)
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	defer conn.Release()
+
+	// The procedure locks the bucket's row, so it is run in its own read committed transaction
+	// instead of a serializable one that would fail on concurrent requests.
+	var result rateLimitResult
+	err = conn.QueryRow(ctx, "CALL take_rate_limit_token_($1, $2, $3, NULL, NULL)", key, policy.Limit, policy.Period.Seconds()).Scan(&result.Allowed, &result.Tokens)
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	return result, nil
+}
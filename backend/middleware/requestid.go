@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"backend/types"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// Request ids passed by a proxy are kept if they are short and contain only safe characters.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Give every request an id, sent back in the X-Request-Id header and added to its logs.
+// It also creates the RequestMeta for controllers to pass data to the logging middleware.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-Id")
+		if !validRequestID.MatchString(requestID) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			requestID = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-Id", requestID)
+
+		meta := &RequestMeta{RequestID: requestID}
+		ctx := context.WithValue(r.Context(), types.ContextKey("requestID"), requestID)
+		ctx = context.WithValue(ctx, types.ContextKey("meta"), meta)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
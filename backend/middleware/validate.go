@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"backend/openapi"
+	"backend/types"
+	"backend/util/errorutil"
+	"backend/util/logutil"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Larger bodies are passed on without validation, the controllers refuse them with 413.
+const maxValidatedBody = 1 << 16
+
+// Check JSON request bodies against the schema of their operation in the OpenAPI document,
+// responding with 400 and the invalid fields in the details of the error.
+// Bodies that are not valid JSON are passed on, the controllers report them the same way as without validation.
+// Use after the auth middleware, so that only users allowed to call the route learn about its schema.
+func ValidateRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		operation, _ := openapi.Spec.Find(r.Method, r.URL.Path)
+		if operation == nil || operation.RequestSchema() == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+		if err != nil {
+			logutil.LogError(r.Context(), err)
+			errorutil.Error(w, r, err)
+			return
+		}
+		if len(body) > maxValidatedBody {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var value any
+		if json.Unmarshal(body, &value) == nil {
+			if problems := openapi.Spec.ValidateRequest(operation.RequestSchema(), value); problems != nil {
+				errorutil.Write(w, r, http.StatusBadRequest, types.ValidationFailedCode, types.ValidationFailed, problems)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
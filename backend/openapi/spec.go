@@ -0,0 +1,168 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// The OpenAPI 3 document of the API, served at /api/openapi.json.
+// Only the parts used to validate requests and responses are parsed.
+//
+//go:embed openapi.json
+var JSON []byte
+
+type Document struct {
+	Paths      map[string]map[string]*Operation `json:"paths"` // Operations by path and lower case method.
+	Components struct {
+		Responses map[string]*Response `json:"responses"`
+		Schemas   map[string]*Schema   `json:"schemas"`
+	} `json:"components"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []Parameter          `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"` // By status code or "default".
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// The subset of JSON Schema used by the document.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"-"` // Nil if any property is allowed.
+	Items                *Schema            `json:"items"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	// Use another type to not call UnmarshalJSON again.
+	type schema Schema
+	err := json.Unmarshal(data, (*schema)(s))
+	if err != nil {
+		return err
+	}
+	// additionalProperties can also be a schema, which the document does not use.
+	var additional struct {
+		AdditionalProperties any `json:"additionalProperties"`
+	}
+	err = json.Unmarshal(data, &additional)
+	if err != nil {
+		return err
+	}
+	if allowed, ok := additional.AdditionalProperties.(bool); ok {
+		s.AdditionalProperties = &allowed
+	}
+	return nil
+}
+
+// The parsed JSON document.
+var Spec = mustParse(JSON)
+
+func mustParse(data []byte) *Document {
+	doc := &Document{}
+	err := json.Unmarshal(data, doc)
+	if err != nil {
+		panic("parsing openapi.json: " + err.Error())
+	}
+	return doc
+}
+
+// Find the operation for a request method and path, and the path template it matched, for example "/api/file/{id}".
+// Templates with more literal segments are preferred, so "/api/session/all" is not matched by "/api/session/{id}".
+// Returns nil if the document has no such operation.
+func (doc *Document) Find(method, path string) (*Operation, string) {
+	segments := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	var found *Operation
+	var foundTemplate string
+	foundLiterals := -1
+	for template, operations := range doc.Paths {
+		operation, ok := operations[strings.ToLower(method)]
+		if !ok {
+			continue
+		}
+		literals, ok := match(strings.Split(strings.TrimSuffix(template, "/"), "/"), segments)
+		if ok && literals > foundLiterals {
+			found, foundTemplate, foundLiterals = operation, template, literals
+		}
+	}
+	return found, foundTemplate
+}
+
+// Match path segments against template segments, returning the number of literal segments matched.
+func match(template, segments []string) (int, bool) {
+	if len(template) != len(segments) {
+		return 0, false
+	}
+	literals := 0
+	for i, part := range template {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if segments[i] == "" {
+				return 0, false
+			}
+			continue
+		}
+		if part != segments[i] {
+			return 0, false
+		}
+		literals++
+	}
+	return literals, true
+}
+
+// Get the JSON schema of the request body, nil if the operation has no JSON body.
+func (operation *Operation) RequestSchema() *Schema {
+	if operation.RequestBody == nil {
+		return nil
+	}
+	return operation.RequestBody.Content["application/json"].Schema
+}
+
+// Get the JSON schema of the response with the status, falling back to the default response.
+// Returns nil if the response has no JSON body.
+func (doc *Document) ResponseSchema(operation *Operation, status int) *Schema {
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		response, ok = operation.Responses["default"]
+		if !ok {
+			return nil
+		}
+	}
+	if response.Ref != "" {
+		response = doc.Components.Responses[strings.TrimPrefix(response.Ref, "#/components/responses/")]
+		if response == nil {
+			return nil
+		}
+	}
+	return response.Content["application/json"].Schema
+}
@@ -0,0 +1,183 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type validator struct {
+	doc *Document
+	// Match property names case-insensitively and allow unknown properties, the way encoding/json decodes requests.
+	request  bool
+	problems map[string]any
+}
+
+// Validate a request body decoded with encoding/json into an any against schema.
+// Returns the problems by the JSON path of the invalid value, for example "members[0].id", nil if it is valid.
+func (doc *Document) ValidateRequest(schema *Schema, value any) map[string]any {
+	v := validator{doc: doc, request: true, problems: map[string]any{}}
+	v.validate(schema, value, "")
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return v.problems
+}
+
+// Validate a response body decoded with encoding/json into an any against schema.
+// Property names have to match exactly, so it can be used to check that the controllers follow the document.
+func (doc *Document) ValidateResponse(schema *Schema, value any) map[string]any {
+	v := validator{doc: doc, problems: map[string]any{}}
+	v.validate(schema, value, "")
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return v.problems
+}
+
+func (v *validator) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = v.doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+func (v *validator) fail(path, problem string) {
+	if path == "" {
+		path = "body"
+	}
+	v.problems[path] = problem
+}
+
+func (v *validator) validate(schema *Schema, value any, path string) {
+	schema = v.resolve(schema)
+	if schema == nil {
+		return
+	}
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			v.fail(path, "must not be null")
+		}
+		return
+	}
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, value) {
+		v.fail(path, "must be one of "+enumString(schema.Enum))
+		return
+	}
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			v.fail(path, "must be an object")
+			return
+		}
+		v.validateObject(schema, object, path)
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			v.fail(path, "must be an array")
+			return
+		}
+		for i, item := range array {
+			v.validate(schema.Items, item, path+"["+strconv.Itoa(i)+"]")
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			v.fail(path, "must be a string")
+			return
+		}
+		length := utf8.RuneCountInString(s)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			v.fail(path, "must be at least "+strconv.Itoa(*schema.MinLength)+" characters")
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			v.fail(path, "must be at most "+strconv.Itoa(*schema.MaxLength)+" characters")
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				v.fail(path, "must be an RFC 3339 time")
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			v.fail(path, "must be a number")
+			return
+		}
+		if schema.Type == "integer" && n != math.Trunc(n) {
+			v.fail(path, "must be an integer")
+			return
+		}
+		if schema.Minimum != nil && n < *schema.Minimum {
+			v.fail(path, "must be at least "+strconv.FormatFloat(*schema.Minimum, 'f', -1, 64))
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			v.fail(path, "must be at most "+strconv.FormatFloat(*schema.Maximum, 'f', -1, 64))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "must be a boolean")
+		}
+	}
+}
+
+func (v *validator) validateObject(schema *Schema, object map[string]any, path string) {
+	prefix := path
+	if prefix != "" {
+		prefix += "."
+	}
+	// Find the value of every property, the same way encoding/json would.
+	values := map[string]any{}
+	for key, value := range object {
+		name := v.property(schema, key)
+		if name == "" {
+			if !v.request && schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				v.fail(prefix+key, "is not in the schema")
+			}
+			continue
+		}
+		if _, ok := values[name]; !ok || key == name {
+			values[name] = value
+		}
+	}
+	for _, name := range schema.Required {
+		if _, ok := values[name]; !ok {
+			v.fail(prefix+name, "is required")
+		}
+	}
+	for name, value := range values {
+		v.validate(schema.Properties[name], value, prefix+name)
+	}
+}
+
+// Get the schema's name of a property, or an empty string if the schema does not have it.
+func (v *validator) property(schema *Schema, key string) string {
+	if _, ok := schema.Properties[key]; ok {
+		return key
+	}
+	if !v.request {
+		return ""
+	}
+	for name := range schema.Properties {
+		if strings.EqualFold(name, key) {
+			return name
+		}
+	}
+	return ""
+}
+
+func enumString(values []any) string {
+	s := make([]string, len(values))
+	for i, value := range values {
+		s[i] = fmt.Sprint(value)
+		if s[i] == "" {
+			s[i] = `""`
+		}
+	}
+	return strings.Join(s, ", ")
+}
@@ -11,9 +11,15 @@ import (
 // Define routes with their middleware and controller.
 func InitAdmin() *chi.Mux {
 	adminRouter := chi.NewRouter()
-	adminRouter.Handle("GET /users/{username}", m.Auth(m.Admin(http.HandlerFunc(a.GetUsers))))
-	adminRouter.Handle("DELETE /user/{id}", m.Auth(m.Admin(http.HandlerFunc(a.DeleteUser))))
-	adminRouter.Handle("PATCH /user/role/{id}", m.Auth(m.Admin(http.HandlerFunc(a.PatchUserRole))))
-	adminRouter.Handle("PATCH /user/storage-space", m.Auth(m.Admin(http.HandlerFunc(a.PatchUserStorageSpace))))
+	adminRouter.Handle("GET /users/{username}", readDeadline(m.Auth(m.Admin(http.HandlerFunc(a.GetUsers)))))
+	adminRouter.Handle("DELETE /user/{id}", bulkDeadline(m.Auth(m.Admin(http.HandlerFunc(a.DeleteUser)))))
+	adminRouter.Handle("PATCH /user/role/{id}", writeDeadline(m.Auth(m.Admin(m.ValidateRequest(http.HandlerFunc(a.PatchUserRole))))))
+	adminRouter.Handle("PATCH /user/storage-space", writeDeadline(m.Auth(m.Admin(m.ValidateRequest(http.HandlerFunc(a.PatchUserStorageSpace))))))
+	adminRouter.Handle("DELETE /lockout/account/{username}", writeDeadline(m.Auth(m.Admin(http.HandlerFunc(a.DeleteAccountLockout)))))
+	adminRouter.Handle("DELETE /lockout/ip/{ip}", writeDeadline(m.Auth(m.Admin(http.HandlerFunc(a.DeleteIPLockout)))))
+	adminRouter.Handle("GET /logs", readDeadline(m.Auth(m.Admin(http.HandlerFunc(a.GetLogs)))))
+	adminRouter.Handle("GET /logs/export", bulkDeadline(m.Auth(m.Admin(http.HandlerFunc(a.GetLogsExport)))))
+	adminRouter.Handle("GET /logs/stats", bulkDeadline(m.Auth(m.Admin(http.HandlerFunc(a.GetLogStats)))))
+	adminRouter.Handle("GET /status", readDeadline(m.Auth(m.Admin(http.HandlerFunc(a.GetStatus)))))
 	return adminRouter
 }
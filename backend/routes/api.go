@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"backend/controllers/docs"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Mount the routers of the API, every route has to be described in openapi/openapi.json.
+// Routes with a JSON body validate it with m.ValidateRequest after their auth middleware.
+func MountAPI(r chi.Router) {
+	r.Mount("/api/user", InitUser())
+	r.Mount("/api/session", InitSession())
+	r.Mount("/api/admin", InitAdmin())
+	r.Mount("/api/repository", InitRepository())
+	r.Mount("/api/file", InitFile())
+	r.Mount("/api/member", InitMember())
+	r.Mount("/api/.well-known", InitWellKnown())
+	r.Handle("GET /api/openapi.json", readDeadline(http.HandlerFunc(docs.GetOpenAPI)))
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	m "backend/middleware"
+	c "backend/util/config"
+	"net/http"
+)
+
+// Deadlines for handling requests, the bulk one is for routes that can delete or presign thousands of files
+// and for exporting logs. They are read from the config when the routes are mounted, after it is validated.
+
+func readDeadline(next http.Handler) http.Handler {
+	return m.Deadline(c.Config.RequestTimeoutRead)(next)
+}
+
+func writeDeadline(next http.Handler) http.Handler {
+	return m.Deadline(c.Config.RequestTimeoutWrite)(next)
+}
+
+func bulkDeadline(next http.Handler) http.Handler {
+	return m.Deadline(c.Config.RequestTimeoutBulk)(next)
+}
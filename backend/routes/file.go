@@ -11,16 +11,16 @@ import (
 // Define routes with their middleware and controller.
 func InitFile() *chi.Mux {
 	fileRouter := chi.NewRouter()
-	fileRouter.Handle("GET /{id}", m.OptionalAuth(http.HandlerFunc(f.GetDownload)))
-	fileRouter.Handle("POST /folder", m.Auth(http.HandlerFunc(f.PostFolder)))
-	fileRouter.Handle("POST /upload-start", m.Auth(http.HandlerFunc(f.PostUploadStart)))
-	fileRouter.Handle("POST /file-part", m.Auth(http.HandlerFunc(f.PostUploadPart)))
-	fileRouter.Handle("POST /upload-complete", m.Auth(http.HandlerFunc(f.PostUploadComplete)))
-	fileRouter.Handle("POST /upload-resume", m.Auth(http.HandlerFunc(f.PostResumeUpload)))
-	fileRouter.Handle("DELETE /folder/{id}", m.Auth(http.HandlerFunc(f.DeleteFolder)))
-	fileRouter.Handle("DELETE /{id}", m.Auth(http.HandlerFunc(f.DeleteFile)))
-	fileRouter.Handle("DELETE /in-progress/{id}", m.Auth(http.HandlerFunc(f.DeleteInProgress)))
-	fileRouter.Handle("PATCH /name", m.Auth(http.HandlerFunc(f.PatchFileName)))
-	fileRouter.Handle("PATCH /folder/name", m.Auth(http.HandlerFunc(f.PatchFolderName)))
+	fileRouter.Handle("GET /{id}", readDeadline(m.OptionalAuth(http.HandlerFunc(f.GetDownload))))
+	fileRouter.Handle("POST /folder", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(f.PostFolder)))))
+	fileRouter.Handle("POST /upload-start", bulkDeadline(m.Auth(m.RateLimit(uploadLimit())(m.ValidateRequest(http.HandlerFunc(f.PostUploadStart))))))
+	fileRouter.Handle("POST /file-part", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(f.PostUploadPart)))))
+	fileRouter.Handle("POST /upload-complete", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(f.PostUploadComplete)))))
+	fileRouter.Handle("POST /upload-resume", bulkDeadline(m.Auth(m.RateLimit(uploadLimit())(m.ValidateRequest(http.HandlerFunc(f.PostResumeUpload))))))
+	fileRouter.Handle("DELETE /folder/{id}", bulkDeadline(m.Auth(http.HandlerFunc(f.DeleteFolder))))
+	fileRouter.Handle("DELETE /{id}", writeDeadline(m.Auth(http.HandlerFunc(f.DeleteFile))))
+	fileRouter.Handle("DELETE /in-progress/{id}", writeDeadline(m.Auth(http.HandlerFunc(f.DeleteInProgress))))
+	fileRouter.Handle("PATCH /name", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(f.PatchFileName)))))
+	fileRouter.Handle("PATCH /folder/name", writeDeadline(m.Auth(m.RateLimit(folderNameLimit())(m.ValidateRequest(http.HandlerFunc(f.PatchFolderName))))))
 	return fileRouter
 }
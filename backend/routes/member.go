@@ -11,9 +11,9 @@ import (
 // Define routes with their middleware and controller.
 func InitMember() *chi.Mux {
 	memberRouter := chi.NewRouter()
-	memberRouter.Handle("POST /", m.Auth(http.HandlerFunc(member.PostMember)))
-	memberRouter.Handle("DELETE /{id}", m.Auth(http.HandlerFunc(member.DeleteMember)))
-	memberRouter.Handle("DELETE /leave/{id}", m.Auth(http.HandlerFunc(member.DeleteMemberLeave)))
-	memberRouter.Handle("PATCH /permission", m.Auth(http.HandlerFunc(member.PatchPermission)))
+	memberRouter.Handle("POST /", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(member.PostMember)))))
+	memberRouter.Handle("DELETE /{id}", bulkDeadline(m.Auth(http.HandlerFunc(member.DeleteMember))))
+	memberRouter.Handle("DELETE /leave/{id}", writeDeadline(m.Auth(http.HandlerFunc(member.DeleteMemberLeave))))
+	memberRouter.Handle("PATCH /permission", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(member.PatchPermission)))))
 	return memberRouter
 }
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	m "backend/middleware"
+	c "backend/util/config"
+	"time"
+)
+
+// Rate limits for expensive routes. They are read from the config when the routes are mounted, after it is validated.
+
+// Presigns a url for every part of a file, up to thousands of them.
+func uploadLimit() m.RateLimitPolicy {
+	return m.RateLimitPolicy{Name: "upload", Limit: c.Config.RateLimitUpload, Period: time.Second * time.Duration(c.Config.RateLimitUploadPeriod)}
+}
+
+// Searches all usernames with LIKE, can be used without logging in.
+func userSearchLimit() m.RateLimitPolicy {
+	return m.RateLimitPolicy{Name: "user-search", Limit: c.Config.RateLimitUserSearch, Period: time.Second * time.Duration(c.Config.RateLimitUserSearchPeriod)}
+}
+
+// Updates the path of every file in the folder.
+func folderNameLimit() m.RateLimitPolicy {
+	return m.RateLimitPolicy{Name: "folder-name", Limit: c.Config.RateLimitFolderName, Period: time.Second * time.Duration(c.Config.RateLimitFolderNamePeriod)}
+}
@@ -11,11 +11,11 @@ import (
 // Define routes with their middleware and controller.
 func InitRepository() *chi.Mux {
 	repositoryRouter := chi.NewRouter()
-	repositoryRouter.Handle("GET /{id}", m.OptionalAuth(http.HandlerFunc(r.GetRepository)))
-	repositoryRouter.Handle("GET /all-repositories", m.Auth(http.HandlerFunc(r.GetAllRepositories)))
-	repositoryRouter.Handle("POST /", m.Auth(http.HandlerFunc(r.PostRepository)))
-	repositoryRouter.Handle("DELETE /{id}", m.Auth(http.HandlerFunc(r.DeleteRepository)))
-	repositoryRouter.Handle("PATCH /name", m.Auth(http.HandlerFunc(r.PatchName)))
-	repositoryRouter.Handle("PATCH /visibility", m.Auth(http.HandlerFunc(r.PatchVisibility)))
+	repositoryRouter.Handle("GET /{id}", readDeadline(m.OptionalAuth(http.HandlerFunc(r.GetRepository))))
+	repositoryRouter.Handle("GET /all-repositories", readDeadline(m.Auth(http.HandlerFunc(r.GetAllRepositories))))
+	repositoryRouter.Handle("POST /", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(r.PostRepository)))))
+	repositoryRouter.Handle("DELETE /{id}", bulkDeadline(m.Auth(http.HandlerFunc(r.DeleteRepository))))
+	repositoryRouter.Handle("PATCH /name", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(r.PatchName)))))
+	repositoryRouter.Handle("PATCH /visibility", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(r.PatchVisibility)))))
 	return repositoryRouter
 }
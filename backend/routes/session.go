@@ -11,8 +11,9 @@ import (
 // Define routes with their middleware and controller.
 func InitSession() *chi.Mux {
 	sessionRouter := chi.NewRouter()
-	sessionRouter.Handle("GET /all", m.Auth(http.HandlerFunc(s.GetSessions)))
-	sessionRouter.Handle("DELETE /all", m.Auth(http.HandlerFunc(s.DeleteSessions)))
-	sessionRouter.Handle("DELETE /{id}", m.Auth(http.HandlerFunc(s.DeleteSession)))
+	sessionRouter.Handle("GET /all", readDeadline(m.Auth(http.HandlerFunc(s.GetSessions))))
+	sessionRouter.Handle("DELETE /all", writeDeadline(m.Auth(http.HandlerFunc(s.DeleteSessions))))
+	sessionRouter.Handle("DELETE /{id}", writeDeadline(m.Auth(http.HandlerFunc(s.DeleteSession))))
+	sessionRouter.Handle("PATCH /name", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(s.PatchName)))))
 	return sessionRouter
 }
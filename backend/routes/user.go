@@ -11,13 +11,14 @@ import (
 // Define routes with their middleware and controller.
 func InitUser() *chi.Mux {
 	userRouter := chi.NewRouter()
-	userRouter.Handle("GET /users/{username}", http.HandlerFunc(u.GetUsers))
-	userRouter.Handle("GET /account", m.Auth(http.HandlerFunc(u.GetAccount)))
-	userRouter.Handle("POST /", http.HandlerFunc(u.PostUser))
-	userRouter.Handle("POST /login", http.HandlerFunc(u.PostLogin))
-	userRouter.Handle("POST /logout", m.Auth(http.HandlerFunc(u.PostLogout)))
-	userRouter.Handle("DELETE /", m.Auth(http.HandlerFunc(u.DeleteUser)))
-	userRouter.Handle("PATCH /username", m.Auth(http.HandlerFunc(u.PatchUsername)))
-	userRouter.Handle("PATCH /password", m.Auth(http.HandlerFunc(u.PatchPassword)))
+	userRouter.Handle("GET /users/{username}", readDeadline(m.RateLimit(userSearchLimit())(http.HandlerFunc(u.GetUsers))))
+	userRouter.Handle("GET /account", readDeadline(m.Auth(http.HandlerFunc(u.GetAccount))))
+	userRouter.Handle("GET /security-events", readDeadline(m.Auth(http.HandlerFunc(u.GetSecurityEvents))))
+	userRouter.Handle("POST /", writeDeadline(m.ValidateRequest(http.HandlerFunc(u.PostUser))))
+	userRouter.Handle("POST /login", writeDeadline(m.ValidateRequest(http.HandlerFunc(u.PostLogin))))
+	userRouter.Handle("POST /logout", writeDeadline(m.Auth(http.HandlerFunc(u.PostLogout))))
+	userRouter.Handle("DELETE /", bulkDeadline(m.Auth(http.HandlerFunc(u.DeleteUser))))
+	userRouter.Handle("PATCH /username", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(u.PatchUsername)))))
+	userRouter.Handle("PATCH /password", writeDeadline(m.Auth(m.ValidateRequest(http.HandlerFunc(u.PatchPassword)))))
 	return userRouter
 }
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	wk "backend/controllers/wellknown"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Define routes with their middleware and controller.
+func InitWellKnown() *chi.Mux {
+	wellKnownRouter := chi.NewRouter()
+	wellKnownRouter.Handle("GET /jwks.json", readDeadline(http.HandlerFunc(wk.GetJWKS)))
+	return wellKnownRouter
+}
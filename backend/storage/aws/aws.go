@@ -1,16 +1,17 @@
 package aws
 
 import (
+	c "backend/util/config"
 	"context"
 	"errors"
 	"log"
-	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	"github.com/joho/godotenv"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 )
 
 type Storage struct {
@@ -20,29 +21,25 @@ type Storage struct {
 }
 
 func InitStorage(sClient *s3.Client, sPresigner *s3.PresignClient, sBucket *string) {
-	// Load .env file
-	err := godotenv.Load("./storage/aws/.env")
-	if err != nil {
-		log.Fatal("Error loading .env file for aws s3 storage: ", err)
-	}
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		log.Fatal("Loaded aws region from .env file is not specified")
-	}
-	bucket := os.Getenv("BUCKET")
-	if bucket == "" {
-		log.Fatal("Loaded aws bucket from .env file is not specified")
-	}
+	region := c.Config.AWSRegion
+	bucket := c.Config.Bucket
 	*sBucket = bucket
 
-	// LoadDefaultConfig reads AWS_SECRET_ACCESS_KEY and AWS_ACCESS_KEY_ID from
-	// environment variables.
+	// Use the access key from the config, or let LoadDefaultConfig find credentials,
+	// for example in the shared credentials file or from an IAM role.
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	options := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	if c.Config.AWSAccessKeyID != "" {
+		creds := credentials.NewStaticCredentialsProvider(c.Config.AWSAccessKeyID, c.Config.AWSSecretAccessKey, "")
+		options = append(options, config.WithCredentialsProvider(creds))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
 	if err != nil {
 		log.Fatal("Failed to load configuration for aws: ", err)
 	}
+	// Create a span for every s3 call.
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
 	*sClient = *s3.NewFromConfig(cfg)
 	*sPresigner = *s3.NewPresignClient(sClient)
 
@@ -1,6 +1,7 @@
 package aws
 
 import (
+	c "backend/util/config"
 	"context"
 	"fmt"
 	"net/url"
@@ -13,7 +14,7 @@ import (
 func (s Storage) GetDownload(ctx context.Context, key, name string) (string, error) {
 	res, err := s.Presigner.PresignGetObject(ctx, &s3.GetObjectInput{
 		Key: &key, Bucket: &s.Bucket, ResponseContentDisposition: aws.String(fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", "download", url.PathEscape(name))),
-	}, s3.WithPresignExpires(time.Minute))
+	}, s3.WithPresignExpires(time.Second*time.Duration(c.Config.PresignDownloadTTL)))
 	if err != nil {
 		return "", err
 	}
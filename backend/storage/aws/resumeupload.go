@@ -2,6 +2,7 @@ package aws
 
 import (
 	"backend/types"
+	c "backend/util/config"
 	"backend/util/fileutil"
 	"context"
 	"slices"
@@ -32,7 +33,7 @@ func (s Storage) ResumeMultipartUpload(ctx context.Context, key string, uploadID
 			UploadId:      aws.String(uploadID),
 			PartNumber:    aws.Int32(int32(part)),
 			ContentLength: aws.Int64(int64(currPartSize)),
-		}, s3.WithPresignExpires(4*24*time.Hour))
+		}, s3.WithPresignExpires(time.Second*time.Duration(c.Config.PresignUploadTTL)))
 		if err != nil {
 			return []types.UploadPart{}, err
 		}
@@ -2,6 +2,7 @@ package aws
 
 import (
 	"backend/types"
+	c "backend/util/config"
 	"backend/util/fileutil"
 	"context"
 	"time"
@@ -33,7 +34,7 @@ func (s Storage) StartMultipartUpload(ctx context.Context, key, filename string,
 			UploadId:      aws.String(uploadID),
 			PartNumber:    aws.Int32(int32(part)),
 			ContentLength: aws.Int64(int64(currPartSize)),
-		}, s3.WithPresignExpires(4*24*time.Hour))
+		}, s3.WithPresignExpires(time.Second*time.Duration(c.Config.PresignUploadTTL)))
 		if err != nil {
 			return types.UploadStart{}, err
 		}
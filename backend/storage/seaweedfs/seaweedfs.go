@@ -1,9 +1,9 @@
 package seaweedfs
 
 import (
+	c "backend/util/config"
 	"context"
 	"log"
-	"os"
 	"strings"
 	"time"
 
@@ -12,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
 )
 
 type Storage struct {
@@ -21,25 +22,12 @@ type Storage struct {
 }
 
 func InitStorage(sClient *s3.Client, sPresigner *s3.PresignClient, sBucket *string) {
-	bucket := os.Getenv("LOCAL_BUCKET")
-	if bucket == "" {
-		log.Fatal("Loaded seaweedfs bucket from environment is not specified")
-	}
-	*sBucket = bucket
-	key := os.Getenv("LOCAL_AWS_ACCESS_KEY_ID")
-	if key == "" {
-		log.Fatal("Loaded seaweedfs access key from environment is not specified")
-	}
-	secret := os.Getenv("LOCAL_AWS_SECRET_ACCESS_KEY")
-	if secret == "" {
-		log.Fatal("Loaded seaweedfs secret key from environment is not specified")
-	}
-	testMode := os.Getenv("LOCAL_BACKEND_TEST")
-	backendEndpoint := os.Getenv("LOCAL_BACKEND_S3_ENDPOINT")
-	presignEndpoint := os.Getenv("LOCAL_BROWSER_S3_ENDPOINT")
+	*sBucket = c.Config.LocalBucket
+	key := c.Config.LocalAWSAccessKeyID
+	secret := c.Config.LocalAWSSecretAccessKey
+	backendEndpoint := c.Config.LocalBackendS3Endpoint
+	presignEndpoint := c.Config.LocalBrowserS3Endpoint
 
-	// LoadDefaultConfig reads AWS_SECRET_ACCESS_KEY and AWS_ACCESS_KEY_ID from
-	// environment variables.
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	creds := credentials.NewStaticCredentialsProvider(key, secret, "")
@@ -47,6 +35,8 @@ func InitStorage(sClient *s3.Client, sPresigner *s3.PresignClient, sBucket *stri
 	if err != nil {
 		log.Fatal("Failed to load configuration for seaweedfs: ", err)
 	}
+	// Create a span for every s3 call.
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
 	// Create 2 clients: one for the backend to communicate with s3 (for example in docker network),
 	// one with the url for a browser to reach.
 	*sClient = *s3.NewFromConfig(cfg, func(o *s3.Options) {
@@ -61,7 +51,7 @@ func InitStorage(sClient *s3.Client, sPresigner *s3.PresignClient, sBucket *stri
 		// Important to find docker host.
 		o.UsePathStyle = true
 	})
-	if testMode == "1" {
+	if c.Config.LocalBackendTest {
 		*sPresigner = *s3.NewPresignClient(sClient)
 	} else {
 		*sPresigner = *s3.NewPresignClient(sPresignClient)
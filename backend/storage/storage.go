@@ -1,12 +1,13 @@
 package storage
 
 import (
+	"backend/metrics"
 	"backend/storage/aws"
 	"backend/storage/seaweedfs"
 	"backend/types"
+	c "backend/util/config"
 	"context"
-	"log"
-	"os"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
@@ -29,13 +30,8 @@ var (
 )
 
 func InitStorage() {
-	storageOption = os.Getenv("STORAGE_OPTION")
-	if storageOption == "" {
-		log.Fatal("Loaded STORAGE_OPTION from environment is not specified")
-	}
-	if storageOption != "cloud" && storageOption != "local" {
-		log.Fatal("Loaded STORAGE_OPTION from environment is invalid")
-	}
+	// Validated at startup by config.Validate.
+	storageOption = c.Config.StorageOption
 	if storageOption == "local" {
 		seaweedfs.InitStorage(ls.Seaweedfs.Client, ls.Seaweedfs.Presigner, &ls.Seaweedfs.Bucket)
 		seaweedfs.InitStorage(ls.AWS.Client, ls.AWS.Presigner, &ls.AWS.Bucket)
@@ -47,7 +43,8 @@ func InitStorage() {
 	}
 }
 
-func StartUpload(ctx context.Context, key, filename string, bytes int) (types.UploadStart, error) {
+func StartUpload(ctx context.Context, key, filename string, bytes int) (res types.UploadStart, err error) {
+	defer metrics.ObserveStorage("StartUpload", time.Now(), &err)
 	if storageOption == "local" {
 		return ls.AWS.StartMultipartUpload(ctx, key, filename, bytes)
 	}
@@ -57,7 +54,8 @@ func StartUpload(ctx context.Context, key, filename string, bytes int) (types.Up
 	return types.UploadStart{}, nil
 }
 
-func ResumeUpload(ctx context.Context, key string, uploadID string, bytes int, completeParts []types.CompletePart) ([]types.UploadPart, error) {
+func ResumeUpload(ctx context.Context, key string, uploadID string, bytes int, completeParts []types.CompletePart) (res []types.UploadPart, err error) {
+	defer metrics.ObserveStorage("ResumeUpload", time.Now(), &err)
 	if storageOption == "local" {
 		return ls.AWS.ResumeMultipartUpload(ctx, key, uploadID, bytes, completeParts)
 	}
@@ -67,7 +65,8 @@ func ResumeUpload(ctx context.Context, key string, uploadID string, bytes int, c
 	return []types.UploadPart{}, nil
 }
 
-func CompleteUpload(ctx context.Context, key, uploadID string, completedParts []types.CompletePart) error {
+func CompleteUpload(ctx context.Context, key, uploadID string, completedParts []types.CompletePart) (err error) {
+	defer metrics.ObserveStorage("CompleteUpload", time.Now(), &err)
 	if storageOption == "local" {
 		return ls.AWS.CompleteMultipartUpload(ctx, key, uploadID, completedParts)
 	}
@@ -78,7 +77,8 @@ func CompleteUpload(ctx context.Context, key, uploadID string, completedParts []
 }
 
 // Delete all uploaded and in-progress files that are passed in arrays.
-func DeleteAllFiles(ctx context.Context, uploadedFiles []types.UploadedFile, inProgressFiles []types.InProgressFile) error {
+func DeleteAllFiles(ctx context.Context, uploadedFiles []types.UploadedFile, inProgressFiles []types.InProgressFile) (err error) {
+	defer metrics.ObserveStorage("DeleteAllFiles", time.Now(), &err)
 	if storageOption == "local" {
 		return ls.AWS.DeleteAllFiles(ctx, uploadedFiles, inProgressFiles)
 	}
@@ -88,7 +88,8 @@ func DeleteAllFiles(ctx context.Context, uploadedFiles []types.UploadedFile, inP
 	return nil
 }
 
-func DeleteFile(ctx context.Context, key string) error {
+func DeleteFile(ctx context.Context, key string) (err error) {
+	defer metrics.ObserveStorage("DeleteFile", time.Now(), &err)
 	if storageOption == "local" {
 		return ls.AWS.DeleteFile(ctx, key)
 	}
@@ -98,7 +99,8 @@ func DeleteFile(ctx context.Context, key string) error {
 	return nil
 }
 
-func AbortUpload(ctx context.Context, key string, uploadID string) error {
+func AbortUpload(ctx context.Context, key string, uploadID string) (err error) {
+	defer metrics.ObserveStorage("AbortUpload", time.Now(), &err)
 	if storageOption == "local" {
 		return ls.AWS.AbortUpload(ctx, key, uploadID)
 	}
@@ -108,7 +110,8 @@ func AbortUpload(ctx context.Context, key string, uploadID string) error {
 	return nil
 }
 
-func GetDownload(ctx context.Context, key, name string) (string, error) {
+func GetDownload(ctx context.Context, key, name string) (res string, err error) {
+	defer metrics.ObserveStorage("GetDownload", time.Now(), &err)
 	if storageOption == "local" {
 		return ls.AWS.GetDownload(ctx, key, name)
 	}
@@ -117,3 +120,15 @@ func GetDownload(ctx context.Context, key, name string) (string, error) {
 	}
 	return "", nil
 }
+
+// Check if the bucket exists and can be accessed, for example for readiness checks.
+func HeadBucket(ctx context.Context) (err error) {
+	defer metrics.ObserveStorage("HeadBucket", time.Now(), &err)
+	if storageOption == "local" {
+		return ls.AWS.HeadBucket(ctx)
+	}
+	if storageOption == "cloud" {
+		return cs.AWS.HeadBucket(ctx)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"backend/types"
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
@@ -46,3 +47,40 @@ func TestBodyTooLarge(t *testing.T) {
 		t.Fatal("Server did not refuse a body that's too large")
 	}
 }
+
+// Test sending a body that is not valid JSON.
+func TestBodyInvalidJSON(t *testing.T) {
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		RootCAs:            rootCAs,
+	}
+	tr := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	client := &http.Client{Transport: tr}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	body := io.NopCloser(bytes.NewReader([]byte(`{"username": "bodytest",`)))
+	res, err := client.Do(&http.Request{Method: "POST", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/user/"}, Proto: "2.0", Header: header, Body: body})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Fatal("Server did not refuse a body that's not valid JSON, status:", res.StatusCode)
+	}
+	errorResponse := types.ErrorResponse{}
+	err = json.NewDecoder(res.Body).Decode(&errorResponse)
+	if err != nil {
+		t.Fatal("Error decoding the error response", err)
+	}
+	if errorResponse.Code != types.InvalidJSONCode {
+		t.Fatal("Wrong error code:", errorResponse.Code)
+	}
+	if errorResponse.RequestID == "" {
+		t.Fatal("Error response has no request id")
+	}
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"backend/client"
+	db "backend/database"
+	"backend/types"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test the client package against the server, authenticating with the bearer token instead of the cookie.
+func TestClient(t *testing.T) {
+	// Get a new SystemCertPool.
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trust the augmented cert pool in our client.
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		RootCAs:            rootCAs,
+	}
+	tr := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	c, err := client.New("https://"+serverHost, &http.Client{Transport: tr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err = c.CreateUser(ctx, "clientUser", "clientPassword")
+	if err != nil {
+		t.Fatal("Error creating the user:", err)
+	}
+	if c.Token() == "" {
+		t.Fatal("Client did not keep the session token")
+	}
+	account, err := c.Account(ctx)
+	if err != nil || account.Username != "clientUser" {
+		t.Fatal("Error getting the account:", err)
+	}
+
+	// Errors have the status and code of the error response.
+	_, err = c.CreateRepository(ctx, "client", "public")
+	apiErr := &client.Error{}
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden || apiErr.Code != types.ForbiddenCode || apiErr.RequestID == "" {
+		t.Fatal("Expected a forbidden error, got:", err)
+	}
+
+	conn, err := db.GetConnection(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	_, err = conn.Exec(ctx, "UPDATE user_ SET role_ = 'user', space_ = 1000000000 WHERE username_ = $1", "clientUser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repositoryID, err := c.CreateRepository(ctx, "client", "private")
+	if err != nil {
+		t.Fatal("Error creating the repository:", err)
+	}
+	_, err = c.CreateFolder(ctx, repositoryID, "folder")
+	if err != nil {
+		t.Fatal("Error creating the folder:", err)
+	}
+
+	// More than 10MB is uploaded in more than one part.
+	data := make([]byte, 11*1000*1000)
+	rand.Read(data)
+	uploaded, err := c.Upload(ctx, repositoryID, "folder/parts", bytes.NewReader(data))
+	if err != nil || uploaded.Date == 0 {
+		t.Fatal("Error uploading the file:", err)
+	}
+	downloaded := &bytes.Buffer{}
+	_, err = c.Download(ctx, uploaded.FileID, downloaded)
+	if err != nil || !bytes.Equal(downloaded.Bytes(), data) {
+		t.Fatal("Downloaded file differs from the uploaded one:", err)
+	}
+
+	// Readers that cannot seek are uploaded too.
+	_, err = c.Upload(ctx, repositoryID, "folder/stream", io.MultiReader(bytes.NewReader(data[:1000])))
+	if err != nil {
+		t.Fatal("Error uploading a stream:", err)
+	}
+
+	// An upload with no recorded parts is finished with ContinueUpload.
+	start, err := c.StartUpload(ctx, repositoryID, "folder/resumed", 1000)
+	if err != nil {
+		t.Fatal("Error starting the upload:", err)
+	}
+	_, err = c.ContinueUpload(ctx, start.FileID, bytes.NewReader(data[:1000]), 1000)
+	if err != nil {
+		t.Fatal("Error continuing the upload:", err)
+	}
+
+	repository, err := c.Repository(ctx, repositoryID)
+	if err != nil {
+		t.Fatal("Error getting the repository:", err)
+	}
+	for _, file := range repository.Files {
+		if file.Type == "file" && file.UploadDate == 0 {
+			t.Fatal("Upload was not completed:", file.Path)
+		}
+	}
+	if len(repository.Files) != 4 {
+		t.Fatal("Expected a folder and 3 files, got", len(repository.Files))
+	}
+
+	err = c.DeleteUser(ctx)
+	if err != nil {
+		t.Fatal("Error deleting the user:", err)
+	}
+	if c.Token() != "" {
+		t.Fatal("Client kept the token of a deleted user")
+	}
+}
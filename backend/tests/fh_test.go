@@ -0,0 +1,191 @@
+package test
+
+import (
+	"backend/client"
+	db "backend/database"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fhTransfer struct {
+	Path   string `json:"path"`
+	FileID int    `json:"fileID"`
+	Status string `json:"status"`
+}
+
+// Test uploading a directory with the fh command past the upload rate limit, resuming an interrupted upload
+// and downloading the directory back.
+func TestFh(t *testing.T) {
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}, ForceAttemptHTTP2: true}
+	c, err := client.New("https://"+serverHost, &http.Client{Transport: tr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	err = c.CreateUser(ctx, "fhUser", "fhPassword")
+	if err != nil {
+		t.Fatal("Error creating the user:", err)
+	}
+	defer c.DeleteUser(context.Background())
+	conn, err := db.GetConnection(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	_, err = conn.Exec(ctx, "UPDATE user_ SET role_ = 'user', space_ = 1000000000 WHERE username_ = $1", "fhUser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "fh")
+	out, err := exec.Command("go", "build", "-o", bin, "../cmd/fh").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Error building fh: %v\n%s", err, out)
+	}
+	ca, err := filepath.Abs(localCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.CommandContext(ctx, bin, args...)
+		cmd.Env = append(os.Environ(), "FH_CONFIG="+filepath.Join(dir, "config.json"), "FH_SERVER=https://"+serverHost,
+			"FH_CA="+ca, "FH_PASSWORD=fhPassword", "FH_TOKEN=")
+		stderr := &bytes.Buffer{}
+		cmd.Stderr = stderr
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("fh %v failed: %v\n%s", args, err, stderr)
+		}
+		return out
+	}
+	transfers := func(out []byte) map[string]fhTransfer {
+		t.Helper()
+		var list []fhTransfer
+		if err := json.Unmarshal(out, &list); err != nil {
+			t.Fatalf("Error decoding the transfers: %v\n%s", err, out)
+		}
+		m := map[string]fhTransfer{}
+		for _, transfer := range list {
+			m[transfer.Path] = transfer
+		}
+		return m
+	}
+
+	fh("login", "fhUser")
+	fh("repo", "create", "fhrepo")
+
+	// More files than the upload rate limit lets start in a minute, the rest wait for Retry-After.
+	local := filepath.Join(dir, "photos")
+	files := map[string][]byte{}
+	for i := range 36 {
+		name := strconv.Itoa(i) + ".jpg"
+		if i >= 30 {
+			name = "nested/" + name
+		}
+		data := make([]byte, 100+i)
+		rand.Read(data)
+		files[name] = data
+	}
+	for name, data := range files {
+		err = os.MkdirAll(filepath.Dir(filepath.Join(local, name)), 0755)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(local, name), data, 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	uploaded := transfers(fh("-json", "upload", "fhrepo", local, "albums"))
+	if len(uploaded) != len(files) {
+		t.Fatalf("Uploaded %d files, want %d", len(uploaded), len(files))
+	}
+	for name := range files {
+		if uploaded["albums/photos/"+name].Status != "uploaded" {
+			t.Fatalf("%s was not uploaded: %+v", name, uploaded["albums/photos/"+name])
+		}
+	}
+
+	// An upload interrupted after it was started, with none of its parts sent, is finished by resume.
+	// More than 10MB is uploaded in more than one part.
+	late := make([]byte, 11*1000*1000)
+	rand.Read(late)
+	files["late.bin"] = late
+	latePath := filepath.Join(local, "late.bin")
+	err = os.WriteFile(latePath, late, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(latePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repositories, err := c.Repositories(ctx)
+	if err != nil || len(repositories) != 1 {
+		t.Fatal("Error getting the repository:", err)
+	}
+	repositoryID := repositories[0].ID
+	start, err := c.StartUpload(ctx, repositoryID, "albums/photos/late.bin", info.Size())
+	if err != nil {
+		t.Fatal("Error starting the upload:", err)
+	}
+	// Record it the way fh does before sending the parts.
+	configPath := filepath.Join(dir, "config.json")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]any{}
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config["uploads"] = []map[string]any{{"server": "https://" + serverHost, "fileID": start.FileID, "repositoryID": repositoryID,
+		"path": "albums/photos/late.bin", "localPath": latePath, "size": info.Size(), "modTime": info.ModTime()}}
+	data, err = json.Marshal(config)
+	if err == nil {
+		err = os.WriteFile(configPath, data, 0600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := transfers(fh("-json", "resume"))
+	if resumed["albums/photos/late.bin"].Status != "resumed" {
+		t.Fatalf("The interrupted upload was not resumed: %+v", resumed)
+	}
+
+	// Running the upload again skips the files that are already uploaded.
+	skipped := transfers(fh("-json", "upload", "fhrepo", local, "albums"))
+	for name := range files {
+		if skipped["albums/photos/"+name].Status != "skipped" {
+			t.Fatalf("%s was not skipped: %+v", name, skipped["albums/photos/"+name])
+		}
+	}
+
+	// The downloaded folder has the same files.
+	downloadPath := filepath.Join(dir, "download")
+	fh("download", "fhrepo", "albums/photos", downloadPath)
+	for name, data := range files {
+		downloaded, err := os.ReadFile(filepath.Join(downloadPath, name))
+		if err != nil || !bytes.Equal(downloaded, data) {
+			t.Fatalf("Downloaded %s differs from the uploaded one: %v", name, err)
+		}
+	}
+}
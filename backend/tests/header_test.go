@@ -48,3 +48,40 @@ func TestHeaderTooLarge(t *testing.T) {
 		t.Fatal("Server did not refuse a header that's too large")
 	}
 }
+
+// Test that the server sends back a request id, keeping the one set by the client.
+func TestRequestID(t *testing.T) {
+	// Get a new SystemCertPool.
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trust the augmented cert pool in our client.
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		RootCAs:            rootCAs,
+	}
+	tr := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	client := &http.Client{Transport: tr}
+
+	header := http.Header{}
+	header.Set("X-Request-Id", "test-request-id")
+	res, err := client.Do(&http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/.well-known/jwks.json"}, Proto: "2.0", Header: header})
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	defer res.Body.Close()
+	if res.Header.Get("X-Request-Id") != "test-request-id" {
+		t.Fatal("Server did not send back the client's request id")
+	}
+
+	res, err = client.Do(&http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/.well-known/jwks.json"}, Proto: "2.0", Header: http.Header{}})
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	defer res.Body.Close()
+	if res.Header.Get("X-Request-Id") == "" {
+		t.Fatal("Server did not generate a request id")
+	}
+}
@@ -0,0 +1,54 @@
+package test
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// Test that the server is live and ready with all its dependencies.
+func TestHealth(t *testing.T) {
+	// Get a new SystemCertPool.
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trust the augmented cert pool in our client.
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		RootCAs:            rootCAs,
+	}
+	tr := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	client := &http.Client{Transport: tr}
+
+	res, err := client.Do(&http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/healthz"}, Proto: "2.0", Header: http.Header{}})
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatal("Server did not reply with 200 on GET healthz")
+	}
+
+	res, err = client.Do(&http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/readyz"}, Proto: "2.0", Header: http.Header{}})
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatal("Server did not reply with 200 on GET readyz")
+	}
+	var ready struct {
+		Status       string            `json:"status"`
+		Dependencies map[string]string `json:"dependencies"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&ready); err != nil {
+		t.Fatal("Error decoding JSON:", err)
+	}
+	if ready.Status != "ok" || ready.Dependencies["database"] != "ok" || ready.Dependencies["storage"] != "ok" {
+		t.Fatal("Server did not report its dependencies as ready")
+	}
+}
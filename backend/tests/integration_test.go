@@ -5,7 +5,6 @@ import (
 	c "backend/util/config"
 	"context"
 	"net/http"
-	"strconv"
 	"testing"
 	"time"
 )
@@ -42,10 +41,7 @@ func TestIntegration(t *testing.T) {
 	// Test creating and deleting a user with an expired JWT, but valid refresh token.
 	t.Run("create a user", subtestPostUser)
 	// JWT expiry time set in seconds.
-	expiryTime, err := strconv.Atoi(c.JWTExpiry)
-	if err != nil {
-		t.Fatal(err)
-	}
+	expiryTime := c.Config.JWTExpiry
 	// Make a request after the access token expires.
 	time.Sleep(time.Second*time.Duration(expiryTime) + time.Second)
 	t.Run("delete the user with the now expired JWT", subtestDeleteUser)
@@ -60,7 +56,9 @@ func TestIntegration(t *testing.T) {
 	t.Run("fail deleting the created user", subtestDeleteUserFail)
 	// Test using lower case in username for login.
 	testUser.Username = "testeduser2"
+	t.Run("fail logging in with a wrong password", subtestPostLoginFail)
 	t.Run("login as the created user", subtestPostLogin)
+	t.Run("get the user's security events", subtestGetSecurityEvents)
 	t.Run("delete the created user after logging in", subtestDeleteUser)
 
 	// Test creating a user and deleting his session.
@@ -68,6 +66,9 @@ func TestIntegration(t *testing.T) {
 	t.Run("create a user", subtestPostUser)
 	t.Run("delete user's session", subtestDeleteSession)
 	t.Run("login after deleting the session", subtestPostLogin)
+	t.Run("name the current session", subtestPatchSessionName)
+	t.Run("revoke the session by reusing a refresh token", subtestRefreshTokenReuse)
+	t.Run("login after the session was revoked", subtestPostLogin)
 	t.Run("delete all user's sessions", subtestDeleteSessions)
 
 	// Create an admin user, change the previously created user's
@@ -76,6 +77,8 @@ func TestIntegration(t *testing.T) {
 	t.Run("create an admin user", subtestCreateAdmin)
 	t.Run("login as created admin", subtestPostLogin)
 	t.Run("change the role of the found user", subtestPatchUserRole)
+	t.Run("unlock failed login attempts of a username", subtestDeleteAccountLockout)
+	t.Run("get request logs as an admin", subtestGetLogs)
 	t.Run("delete the found user", subtestDeleteUserAsAdmin)
 
 	// Test creating a repository and uploading a file with transaction retry,
@@ -214,10 +217,10 @@ func clean() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 	defer cancel()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
 		panic("Failed cleaning the database after the tests: " + err.Error())
 	}
+	defer conn.Release()
 
 	// Delete all records from the user_ table.
 	_, err = conn.Exec(ctx, "TRUNCATE user_ CASCADE")
@@ -225,3 +228,14 @@ func clean() {
 		panic("Failed cleaning the database after the tests: " + err.Error())
 	}
 }
+
+// Save the JWT cookie sent by the server after its refresh token was rotated,
+// so that the next request does not reuse the old refresh token and revoke the session.
+// The cookie is updated in place, so that copies of testUser made in integration_test.go get it too.
+func updateCookies(res *http.Response) {
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == "file_hosting" && cookie.MaxAge >= 0 && len(testUser.Cookies) > 0 {
+			*testUser.Cookies[0] = *cookie
+		}
+	}
+}
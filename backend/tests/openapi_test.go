@@ -0,0 +1,193 @@
+package test
+
+import (
+	db "backend/database"
+	"backend/openapi"
+	"backend/routes"
+	"backend/types"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Test that every route is described in the OpenAPI document, and that the document has no routes that do not exist.
+func TestOpenAPIRoutes(t *testing.T) {
+	router := chi.NewRouter()
+	routes.MountAPI(router)
+	documented := map[string]bool{}
+	for path, operations := range openapi.Spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	err := chi.Walk(router, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if !documented[method+" "+route] {
+			t.Error("Route is not in the OpenAPI document:", method, route)
+		}
+		delete(documented, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for operation := range documented {
+		t.Error("Route in the OpenAPI document does not exist:", operation)
+	}
+}
+
+// Checks every JSON response against the OpenAPI document.
+type contractTransport struct {
+	t         *testing.T
+	transport http.RoundTripper
+}
+
+func (c contractTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := c.transport.RoundTrip(req)
+	if err != nil || !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		return res, err
+	}
+	operation, template := openapi.Spec.Find(req.Method, req.URL.Path)
+	if operation == nil {
+		c.t.Error("Request is not in the OpenAPI document:", req.Method, req.URL.Path)
+		return res, nil
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	schema := openapi.Spec.ResponseSchema(operation, res.StatusCode)
+	if schema == nil {
+		c.t.Error("Response has no schema in the OpenAPI document:", req.Method, template, res.StatusCode)
+		return res, nil
+	}
+	var value any
+	err = json.Unmarshal(body, &value)
+	if err != nil {
+		c.t.Error("Response is not valid JSON:", req.Method, template, err)
+		return res, nil
+	}
+	if problems := openapi.Spec.ValidateResponse(schema, value); problems != nil {
+		c.t.Error("Response does not match the OpenAPI document:", req.Method, template, res.StatusCode, problems)
+	}
+	return res, nil
+}
+
+// Test that the responses of the controllers match the OpenAPI document, going through the main features as a new user.
+func TestOpenAPIContract(t *testing.T) {
+	// Get a new SystemCertPool.
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trust the augmented cert pool in our client.
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		RootCAs:            rootCAs,
+	}
+	tr := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: contractTransport{t: t, transport: tr}, Jar: jar}
+
+	do := func(method, path string, body any) *http.Response {
+		t.Helper()
+		header := http.Header{}
+		var reader io.ReadCloser
+		if body != nil {
+			header.Set("Content-Type", "application/json; charset=utf-8")
+			marshalled, err := json.Marshal(body)
+			if err != nil {
+				t.Fatal("Error marshalling body to be sent")
+			}
+			reader = io.NopCloser(bytes.NewReader(marshalled))
+		}
+		res, err := client.Do(&http.Request{Method: method, URL: &url.URL{Scheme: "https", Host: serverHost, Path: path}, Proto: "2.0", Header: header, Body: reader})
+		if err != nil || res == nil {
+			t.Fatal("Server request error")
+		}
+		return res
+	}
+	expect := func(res *http.Response, status int, v any) {
+		t.Helper()
+		defer res.Body.Close()
+		if res.StatusCode != status {
+			t.Fatal("Server replied with", res.StatusCode, "on", res.Request.Method, res.Request.URL.Path)
+		}
+		if v != nil {
+			err := json.NewDecoder(res.Body).Decode(v)
+			if err != nil {
+				t.Fatal("Error decoding the response", err)
+			}
+		}
+	}
+
+	// The served document is the one the responses are checked against.
+	res := do("GET", "/api/openapi.json", nil)
+	defer res.Body.Close()
+	served, err := io.ReadAll(res.Body)
+	if err != nil || !bytes.Equal(served, openapi.JSON) {
+		t.Fatal("Server did not serve the OpenAPI document")
+	}
+
+	// Bodies of routes that need a login are only validated for logged in users.
+	res = do("POST", "/api/repository/", map[string]string{"name": "contract", "visibility": "hidden"})
+	errorResponse := types.ErrorResponse{}
+	expect(res, 401, &errorResponse)
+	if errorResponse.Code != types.UnauthorizedCode {
+		t.Fatal("Server replied with the", errorResponse.Code, "code instead of", types.UnauthorizedCode)
+	}
+
+	user := map[string]string{"username": "contractUser", "password": "contractPassword"}
+	expect(do("POST", "/api/user/", user), 200, nil)
+	// Invalid bodies are refused before reaching the controller.
+	expect(do("POST", "/api/repository/", map[string]string{"name": "contract", "visibility": "hidden"}), 400, nil)
+	expect(do("GET", "/api/user/account", nil), 200, nil)
+	expect(do("GET", "/api/user/users/contract", nil), 200, nil)
+	expect(do("GET", "/api/user/security-events", nil), 200, nil)
+	expect(do("GET", "/api/session/all", nil), 200, nil)
+	expect(do("GET", "/api/.well-known/jwks.json", nil), 200, nil)
+
+	// Guests cannot create repositories, make the user an admin to test the other routes.
+	expect(do("POST", "/api/repository/", map[string]string{"name": "contract", "visibility": "public"}), 403, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	conn, err := db.GetConnection(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	_, err = conn.Exec(ctx, "UPDATE user_ SET role_ = 'admin', space_ = 1000000000 WHERE username_ = $1", user["username"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repository := struct{ ID int }{}
+	expect(do("POST", "/api/repository/", map[string]string{"name": "contract", "visibility": "public"}), 200, &repository)
+	id := strconv.Itoa(repository.ID)
+	expect(do("POST", "/api/file/folder", map[string]any{"key": "folder", "repositoryID": repository.ID}), 200, nil)
+	expect(do("POST", "/api/file/upload-start", map[string]any{"key": "folder/file", "size": 10, "repositoryID": repository.ID}), 200, nil)
+	expect(do("GET", "/api/repository/"+id, nil), 200, nil)
+	expect(do("GET", "/api/repository/all-repositories", nil), 200, nil)
+	expect(do("GET", "/api/admin/users/contract", nil), 200, nil)
+	expect(do("GET", "/api/admin/status", nil), 200, nil)
+	expect(do("DELETE", "/api/repository/"+id, nil), 200, nil)
+	expect(do("GET", "/api/repository/0", nil), 404, nil)
+
+	expect(do("DELETE", "/api/user/", nil), 200, nil)
+}
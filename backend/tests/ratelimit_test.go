@@ -0,0 +1,123 @@
+package test
+
+import (
+	db "backend/database"
+	"backend/types"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Rename a folder more times than the folder-name rate limit allows in a minute and check the 429 response.
+func TestRateLimit(t *testing.T) {
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}, ForceAttemptHTTP2: true}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var cookie *http.Cookie
+	send := func(method, path string, body any) *http.Response {
+		t.Helper()
+		marshalled, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal("Error marshalling body to be sent")
+		}
+		request, err := http.NewRequestWithContext(ctx, method, "https://"+serverHost+path, bytes.NewReader(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+		res, err := client.Do(request)
+		if err != nil {
+			t.Fatal("Server request error:", err)
+		}
+		// Keep the cookie refreshed by the server, the access token expires during the test.
+		for _, c := range res.Cookies() {
+			if c.Name == "file_hosting" && c.MaxAge >= 0 {
+				cookie = c
+			}
+		}
+		return res
+	}
+
+	res := send("POST", "/api/user/", integrationUser{Username: "rateLimitUser", Password: "rateLimitPassword"})
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || cookie == nil {
+		t.Fatal("Server did not reply with 200 and a cookie on POST user")
+	}
+	defer func() {
+		res := send("DELETE", "/api/user/", nil)
+		res.Body.Close()
+	}()
+	conn, err := db.GetConnection(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	_, err = conn.Exec(ctx, "UPDATE user_ SET role_ = 'user' WHERE username_ = $1", "rateLimitUser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res = send("POST", "/api/repository/", repository{Visibility: "private", Name: "ratelimit"})
+	repo := repositoryResponse{}
+	err = json.NewDecoder(res.Body).Decode(&repo)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || err != nil {
+		t.Fatal("Server did not reply with 200 on POST repository")
+	}
+	res = send("POST", "/api/file/folder", folder{Key: "folder0", RepositoryID: repo.ID})
+	created := folderResponse{}
+	err = json.NewDecoder(res.Body).Decode(&created)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || err != nil {
+		t.Fatal("Server did not reply with 200 on POST folder")
+	}
+
+	// The policy allows 20 renames a minute.
+	for i := 1; i <= 20; i++ {
+		res := send("PATCH", "/api/file/folder/name", folderNamePatch{Name: "folder" + strconv.Itoa(i), ID: created.ID})
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Rename %d got %d, want 200", i, res.StatusCode)
+		}
+		if res.Header.Get("RateLimit-Limit") != "20" || res.Header.Get("RateLimit-Remaining") != strconv.Itoa(20-i) {
+			t.Fatalf("Rename %d got RateLimit-Limit %q and RateLimit-Remaining %q", i, res.Header.Get("RateLimit-Limit"), res.Header.Get("RateLimit-Remaining"))
+		}
+	}
+
+	res = send("PATCH", "/api/file/folder/name", folderNamePatch{Name: "folder21", ID: created.ID})
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Rename over the limit got %d, want 429", res.StatusCode)
+	}
+	if res.Header.Get("RateLimit-Policy") != "20;w=60" || res.Header.Get("RateLimit-Limit") != "20" || res.Header.Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("Got RateLimit-Policy %q, RateLimit-Limit %q and RateLimit-Remaining %q", res.Header.Get("RateLimit-Policy"),
+			res.Header.Get("RateLimit-Limit"), res.Header.Get("RateLimit-Remaining"))
+	}
+	// A token is refilled every 3 seconds.
+	retryAfter, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 3 {
+		t.Fatalf("Got Retry-After %q, want 1 to 3 seconds", res.Header.Get("Retry-After"))
+	}
+	reset, err := strconv.Atoi(res.Header.Get("RateLimit-Reset"))
+	if err != nil || reset < 57 || reset > 60 {
+		t.Fatalf("Got RateLimit-Reset %q, want about a minute", res.Header.Get("RateLimit-Reset"))
+	}
+	var errorResponse types.ErrorResponse
+	err = json.NewDecoder(res.Body).Decode(&errorResponse)
+	if err != nil || errorResponse.Code != types.TooManyRequestsCode {
+		t.Fatalf("Got error %+v, want the %s code: %v", errorResponse, types.TooManyRequestsCode, err)
+	}
+}
@@ -22,10 +22,10 @@ func subtestCreateAdmin(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 	defer cancel()
 	conn, err := db.GetConnection(ctx)
-	defer conn.Release()
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer conn.Release()
 
 	// Create the admin with a maximum storage space of 1TB in the database.
 	ctx, cancel = context.WithTimeout(context.Background(), time.Second*3)
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// Fail logging in as a user that does not exist, then remove the failed attempts as an admin.
+func subtestDeleteAccountLockout(t *testing.T) {
+	// Get a new SystemCertPool.
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trust the augmented cert pool in our client.
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		RootCAs:            rootCAs,
+	}
+	tr := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	client := &http.Client{Transport: tr}
+
+	// Fail logging in as a user that does not exist.
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	user := integrationUser{
+		Username: "lockedUser",
+		Password: "password",
+	}
+	marshalled, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal("Error marshalling body to be sent")
+	}
+	// Wrap NewReader in NopCloser to get ReadCloser.
+	body := io.NopCloser(bytes.NewReader(marshalled))
+	res, err := client.Do(&http.Request{Method: "POST", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/user/login"}, Proto: "2.0", Header: header, Body: body})
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	if res.StatusCode != 401 {
+		t.Fatal("Server did not reply with 401 on POST login as a user that does not exist")
+	}
+
+	// Remove the failed attempts as an admin.
+	request := &http.Request{Method: "DELETE", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/admin/lockout/account/" + user.Username}, Proto: "2.0", Header: header}
+	if len(testUser.Cookies) == 0 {
+		t.Fatal("Found no user's cookies to be sent")
+	}
+	request.AddCookie(testUser.Cookies[0])
+	res, err = client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatal("Server did not reply with 200 on DELETE lockout/account")
+	}
+}
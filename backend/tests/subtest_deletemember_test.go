@@ -34,6 +34,7 @@ func subtestDeleteMember(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on DELETE member")
@@ -14,9 +14,11 @@ type allSessions struct {
 }
 
 type session struct {
-	ID         int    `json:"id"`
-	ExpiryDate string `json:"expirydate"`
-	Device     string `json:"device"`
+	ID         int     `json:"id"`
+	ExpiryDate string  `json:"expirydate"`
+	Device     string  `json:"device"`
+	Name       *string `json:"name"`
+	Current    bool    `json:"current"`
 }
 
 // Get all user's sessions and delete one.
@@ -47,6 +49,7 @@ func subtestDeleteSession(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	var sessions allSessions
 	if err := json.NewDecoder(res.Body).Decode(&sessions); err != nil {
@@ -66,6 +69,7 @@ func subtestDeleteSession(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on DELETE session")
@@ -100,6 +104,7 @@ func subtestDeleteSessions(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on DELETE session")
 	}
@@ -37,6 +37,7 @@ func subtestDeleteUserAsAdmin(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on GET users")
 	}
@@ -59,6 +60,7 @@ func subtestDeleteUserAsAdmin(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on GET users")
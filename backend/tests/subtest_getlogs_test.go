@@ -0,0 +1,115 @@
+package test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type requestLogs struct {
+	Logs []requestLog `json:"logs"`
+	Next *int         `json:"next"`
+}
+
+type requestLog struct {
+	ID     int    `json:"id"`
+	Method string `json:"method"`
+	Status int    `json:"status"`
+}
+
+// Get a filtered page of request logs, export them and get their stats as an admin.
+func subtestGetLogs(t *testing.T) {
+	// Get a new SystemCertPool.
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trust the augmented cert pool in our client.
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		RootCAs:            rootCAs,
+	}
+	tr := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	client := &http.Client{Transport: tr}
+
+	if len(testUser.Cookies) == 0 {
+		t.Fatal("Found no user's cookies to be sent")
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+
+	// Get a page of logs of successful POST requests.
+	request := &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/admin/logs", RawQuery: "method=post&status=2xx&limit=10"}, Proto: "2.0", Header: header}
+	request.AddCookie(testUser.Cookies[0])
+	res, err := client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatal("Server did not reply with 200 on GET logs")
+	}
+	var logs requestLogs
+	if err := json.NewDecoder(res.Body).Decode(&logs); err != nil {
+		t.Fatal("Error decoding JSON:", err)
+	}
+	if len(logs.Logs) > 10 {
+		t.Fatal("Server returned more logs than the limit")
+	}
+	for _, log := range logs.Logs {
+		if log.Method != "POST" || log.Status < 200 || log.Status > 299 {
+			t.Fatal("Server returned a log not matching the filters")
+		}
+	}
+
+	// Reject an invalid filter.
+	request = &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/admin/logs", RawQuery: "from=yesterday"}, Proto: "2.0", Header: header}
+	request.AddCookie(testUser.Cookies[0])
+	res, err = client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Fatal("Server did not reply with 400 on GET logs with an invalid time")
+	}
+
+	// Export the logs as NDJSON.
+	request = &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/admin/logs/export", RawQuery: "format=ndjson&limit=10"}, Proto: "2.0", Header: header}
+	request.AddCookie(testUser.Cookies[0])
+	res, err = client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatal("Server did not reply with 200 on GET logs/export")
+	}
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		var log requestLog
+		if err := json.Unmarshal(scanner.Bytes(), &log); err != nil {
+			t.Fatal("Error decoding exported log:", err)
+		}
+	}
+
+	// Get the stats.
+	request = &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/admin/logs/stats", RawQuery: "interval=minute"}, Proto: "2.0", Header: header}
+	request.AddCookie(testUser.Cookies[0])
+	res, err = client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatal("Server did not reply with 200 on GET logs/stats")
+	}
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type securityEvents struct {
+	Events []securityEvent `json:"events"`
+	Next   *int            `json:"next"`
+}
+
+type securityEvent struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"`
+}
+
+// Get the user's security events after a failed and a successful login.
+func subtestGetSecurityEvents(t *testing.T) {
+	// Get a new SystemCertPool.
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trust the augmented cert pool in our client.
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		RootCAs:            rootCAs,
+	}
+	tr := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	client := &http.Client{Transport: tr}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	request := &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/user/security-events", RawQuery: "limit=10"}, Proto: "2.0", Header: header}
+	if len(testUser.Cookies) == 0 {
+		t.Fatal("Found no user's cookies to be sent")
+	}
+	request.AddCookie(testUser.Cookies[0])
+	res, err := client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatal("Server did not reply with 200 on GET security events")
+	}
+	var events securityEvents
+	if err := json.NewDecoder(res.Body).Decode(&events); err != nil {
+		t.Fatal("Error decoding JSON:", err)
+	}
+	var login, loginFailed bool
+	for _, event := range events.Events {
+		if event.Type == "login" {
+			login = true
+		}
+		if event.Type == "login_failed" {
+			loginFailed = true
+		}
+	}
+	if !login || !loginFailed {
+		t.Fatal("Server did not return the login events")
+	}
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type sessionNamePatch struct {
+	Name string
+	ID   int
+}
+
+// Name the current user's session and check that the name is returned with the sessions.
+func subtestPatchSessionName(t *testing.T) {
+	// Get a new SystemCertPool.
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trust the augmented cert pool in our client.
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		RootCAs:            rootCAs,
+	}
+	tr := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	client := &http.Client{Transport: tr}
+
+	// Get all user's sessions.
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	request := &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/session/all"}, Proto: "2.0", Header: header}
+	if len(testUser.Cookies) == 0 {
+		t.Fatal("Found no user's cookies to be sent")
+	}
+	request.AddCookie(testUser.Cookies[0])
+	res, err := client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	var sessions allSessions
+	if err := json.NewDecoder(res.Body).Decode(&sessions); err != nil {
+		t.Fatal("Error decoding JSON:", err)
+	}
+	currentID := 0
+	for _, session := range sessions.Sessions {
+		if session.Current {
+			currentID = session.ID
+		}
+	}
+	if currentID == 0 {
+		t.Fatal("Server did not mark the current session")
+	}
+
+	// Name the current session.
+	marshalled, err := json.Marshal(sessionNamePatch{Name: "Test device", ID: currentID})
+	if err != nil {
+		t.Fatal("Error marshalling body to be sent")
+	}
+	// Wrap NewReader in NopCloser to get ReadCloser.
+	body := io.NopCloser(bytes.NewReader(marshalled))
+	request = &http.Request{Method: "PATCH", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/session/name"}, Proto: "2.0", Header: header, Body: body}
+	request.AddCookie(testUser.Cookies[0])
+	res, err = client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatal("Server did not reply with 200 on PATCH session name")
+	}
+
+	// Check the session name.
+	request = &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/session/all"}, Proto: "2.0", Header: header}
+	request.AddCookie(testUser.Cookies[0])
+	res, err = client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	sessions = allSessions{}
+	if err := json.NewDecoder(res.Body).Decode(&sessions); err != nil {
+		t.Fatal("Error decoding JSON:", err)
+	}
+	for _, session := range sessions.Sessions {
+		if session.ID == currentID && (session.Name == nil || *session.Name != "Test device") {
+			t.Fatal("Server did not return the new session name")
+		}
+	}
+}
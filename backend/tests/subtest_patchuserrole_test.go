@@ -39,6 +39,7 @@ func subtestPatchUserRole(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on GET users")
 	}
@@ -70,6 +71,7 @@ func subtestPatchUserRole(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on PATCH user/role")
@@ -43,6 +43,7 @@ func subtestPatchUserStorageSpace(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on GET users")
 	}
@@ -74,6 +75,7 @@ func subtestPatchUserStorageSpace(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on PATCH user storage space")
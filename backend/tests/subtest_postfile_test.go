@@ -59,6 +59,7 @@ func subtestPostFile(t *testing.T) {
 	if err != nil {
 		t.Fatal("upload request failed:", err)
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode >= 400 {
 		t.Fatal("upload failed: status", res.Status)
@@ -117,6 +118,7 @@ func subtestPostFile(t *testing.T) {
 		if err != nil {
 			t.Fatal("upload request failed:", err)
 		}
+		updateCookies(res)
 		defer res.Body.Close()
 		if res.StatusCode >= 400 {
 			t.Fatal("upload failed: status", res.Status)
@@ -139,6 +141,7 @@ func subtestPostFile(t *testing.T) {
 	if err != nil {
 		t.Fatal("upload request failed:", err)
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode >= 400 {
 		t.Fatal("upload failed: status", res.Status)
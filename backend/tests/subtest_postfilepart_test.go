@@ -60,6 +60,7 @@ func subtestPostFilePart(t *testing.T) {
 	if err != nil {
 		t.Fatal("upload request failed:", err)
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode >= 400 {
 		t.Fatal("upload failed: status", res.Status)
@@ -118,6 +119,7 @@ func subtestPostFilePart(t *testing.T) {
 		if err != nil {
 			t.Fatal("upload request failed:", err)
 		}
+		updateCookies(res)
 		defer res.Body.Close()
 		if res.StatusCode >= 400 {
 			t.Fatal("upload failed: status", res.Status)
@@ -51,6 +51,7 @@ func subtestPostMember(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on GET users")
 	}
@@ -82,6 +83,7 @@ func subtestPostMember(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on POST member")
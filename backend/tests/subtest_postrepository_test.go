@@ -51,6 +51,7 @@ func subtestPostRepository(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		t.Fatal("Server did not reply with 200 on POST repository")
@@ -96,6 +97,7 @@ func subtestPostRepositoryFail(t *testing.T) {
 	if err != nil || res == nil {
 		t.Fatal("Server request error")
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode != 403 {
 		t.Fatal("Server did not reply with 403 on POST repository")
@@ -68,6 +68,7 @@ func subtestResumeUpload(t *testing.T) {
 	if err != nil {
 		t.Fatal("upload request failed:", err)
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode >= 400 {
 		t.Fatal("upload failed: status", res.Status)
@@ -97,6 +98,7 @@ func subtestResumeUpload(t *testing.T) {
 	if err != nil {
 		t.Fatal("upload request failed:", err)
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode >= 400 {
 		t.Fatal("upload failed: status", res.Status)
@@ -155,6 +157,7 @@ func subtestResumeUpload(t *testing.T) {
 		if err != nil {
 			t.Fatal("upload request failed:", err)
 		}
+		updateCookies(res)
 		defer res.Body.Close()
 		if res.StatusCode >= 400 {
 			t.Fatal("upload failed: status", res.Status)
@@ -177,6 +180,7 @@ func subtestResumeUpload(t *testing.T) {
 	if err != nil {
 		t.Fatal("upload request failed:", err)
 	}
+	updateCookies(res)
 	defer res.Body.Close()
 	if res.StatusCode >= 400 {
 		t.Fatal("upload failed: status", res.Status)
@@ -0,0 +1,80 @@
+package test
+
+import (
+	c "backend/util/config"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Refresh the access token, then use the old refresh token again after the reuse interval,
+// which should revoke the whole session.
+func subtestRefreshTokenReuse(t *testing.T) {
+	// Get a new SystemCertPool.
+	rootCAs, err := loadCerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trust the augmented cert pool in our client.
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		RootCAs:            rootCAs,
+	}
+	tr := &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}
+	client := &http.Client{Transport: tr}
+
+	// JWT expiry time set in seconds.
+	expiryTime := c.Config.JWTExpiry
+	if len(testUser.Cookies) == 0 {
+		t.Fatal("Found no user's cookies to be sent")
+	}
+	oldCookie := *testUser.Cookies[0]
+
+	// Make a request after the access token expires to rotate the refresh token.
+	time.Sleep(time.Second*time.Duration(expiryTime) + time.Second)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	request := &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/user/account"}, Proto: "2.0", Header: header}
+	request.AddCookie(&oldCookie)
+	res, err := client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	updateCookies(res)
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatal("Server did not reply with 200 on GET account")
+	}
+	if testUser.Cookies[0].Value == oldCookie.Value {
+		t.Fatal("Server did not rotate the refresh token")
+	}
+
+	// Reuse the old refresh token after the reuse interval.
+	time.Sleep(time.Second*time.Duration(c.Config.RefreshReuseInterval) + time.Second)
+	request = &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/user/account"}, Proto: "2.0", Header: header}
+	request.AddCookie(&oldCookie)
+	res, err = client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 401 {
+		t.Fatal("Server did not reply with 401 on GET account with a reused refresh token")
+	}
+
+	// The new token should not work either, since its session was revoked.
+	time.Sleep(time.Second*time.Duration(expiryTime) + time.Second)
+	request = &http.Request{Method: "GET", URL: &url.URL{Scheme: "https", Host: serverHost, Path: "/api/user/account"}, Proto: "2.0", Header: header}
+	request.AddCookie(testUser.Cookies[0])
+	res, err = client.Do(request)
+	if err != nil || res == nil {
+		t.Fatal("Server request error")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 401 {
+		t.Fatal("Server did not reply with 401 on GET account in a revoked session")
+	}
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Create a span for every pgx query and a parent span for every transaction attempt,
+// so that a transaction retried after a serialization failure shows up as multiple attempts.
+// A transaction span is started on BEGIN and ended on COMMIT or ROLLBACK sent on the same connection.
+type QueryTracer struct {
+	mu           sync.Mutex
+	transactions map[*pgx.Conn]trace.Span
+}
+
+func NewQueryTracer() *QueryTracer {
+	return &QueryTracer{transactions: map[*pgx.Conn]trace.Span{}}
+}
+
+type querySQLKey struct{}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	sql := strings.ToLower(strings.TrimSpace(data.SQL))
+
+	t.mu.Lock()
+	txSpan, inTx := t.transactions[conn]
+	if strings.HasPrefix(sql, "begin") {
+		// End a transaction that was left open, for example when the connection was released without a rollback.
+		if inTx {
+			txSpan.End()
+		}
+		_, txSpan = Tracer.Start(ctx, "transaction", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(semconv.DBSystemPostgreSQL))
+		t.transactions[conn] = txSpan
+		inTx = true
+	}
+	t.mu.Unlock()
+	if inTx {
+		ctx = trace.ContextWithSpan(ctx, txSpan)
+	}
+
+	ctx, _ = Tracer.Start(ctx, operationName(sql), trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBQueryText(data.SQL)))
+	return context.WithValue(ctx, querySQLKey{}, sql)
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+	span.End()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	txSpan, ok := t.transactions[conn]
+	if !ok {
+		return
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(data.Err, &pgErr) && pgErr.Code == pgerrcode.SerializationFailure {
+		txSpan.AddEvent("serialization failure")
+		txSpan.SetStatus(codes.Error, "serialization failure")
+	}
+	sql, _ := ctx.Value(querySQLKey{}).(string)
+	if strings.HasPrefix(sql, "commit") || strings.HasPrefix(sql, "rollback") {
+		if data.Err != nil {
+			txSpan.SetStatus(codes.Error, data.Err.Error())
+		}
+		txSpan.End()
+		delete(t.transactions, conn)
+	}
+}
+
+// Name a query span by its command, with the procedure name for CALL, for example "CALL prepare_file_".
+func operationName(sql string) string {
+	command, rest, _ := strings.Cut(sql, " ")
+	if command == "call" {
+		procedure, _, _ := strings.Cut(rest, "(")
+		return "CALL " + strings.TrimSpace(procedure)
+	}
+	return strings.ToUpper(command)
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	c "backend/util/config"
+	"context"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Tracer used by the backend, it creates no-op spans until InitTracing sets an exporter.
+var Tracer = otel.Tracer("backend")
+
+var provider *sdktrace.TracerProvider
+
+// Set up tracing with the exporter chosen by TRACING_EXPORTER:
+// otlp - send spans to OTEL_EXPORTER_OTLP_ENDPOINT (http), stdout - print spans for local runs,
+// none (or not set) - disable tracing.
+func InitTracing() {
+	exporterOption := c.Config.TracingExporter
+	if exporterOption == "none" {
+		return
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	switch exporterOption {
+	case "otlp":
+		// Reads the endpoint and headers from the standard OTEL_EXPORTER_OTLP_* environment variables.
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	}
+	if err != nil {
+		log.Fatal("Error creating a tracing exporter: ", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("file_hosting_backend"))),
+	)
+	otel.SetTracerProvider(provider)
+	// Continue traces started by a client or a proxy from the traceparent header.
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+// Flush the remaining spans and stop the exporter.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
@@ -1,10 +1,46 @@
 package types
 
+// Body of every error response.
+// Code is stable and machine-readable, Message is for people and Details describe the failure, for example
+// the fields that failed validation. RequestID is the id of the request in the logs.
 type ErrorResponse struct {
-	Message string `json:"message"`
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	Details   map[string]any `json:"details,omitempty"`
+	RequestID string         `json:"requestID,omitempty"`
 }
 
 const UserHasNoSpace = "User does not have enough space"
 const InsufficientPermission = "User has insufficient permission"
 const FileAlreadyExists = "File already exists"
 const ContainingFolderDoesNotExist = "Containing folder does not exist"
+const InvalidCredentials = "Invalid username or password"
+const TooManyLoginAttempts = "Too many failed login attempts, try again later"
+const TooManyRequests = "Too many requests, try again later"
+const MissingParts = "Not all parts of the file are uploaded"
+const WrongPassword = "Current password is incorrect"
+const ValidationFailed = "Request failed validation"
+
+// Machine-readable codes of the errors above, saved in the request log.
+const UserHasNoSpaceCode = "user_has_no_space"
+const InsufficientPermissionCode = "insufficient_permission"
+const FileAlreadyExistsCode = "file_already_exists"
+const ContainingFolderDoesNotExistCode = "containing_folder_does_not_exist"
+const InvalidCredentialsCode = "invalid_credentials"
+const TooManyLoginAttemptsCode = "too_many_login_attempts"
+const TooManyRequestsCode = "too_many_requests"
+const MissingPartsCode = "missing_parts"
+const WrongPasswordCode = "wrong_password"
+
+// Codes of errors not specific to an endpoint.
+const BadRequestCode = "bad_request"
+const InvalidJSONCode = "invalid_json"
+const BodyTooLargeCode = "body_too_large"
+const ValidationFailedCode = "validation_failed"
+const UnauthorizedCode = "unauthorized"
+const ForbiddenCode = "forbidden"
+const NotFoundCode = "not_found"
+const ConflictCode = "conflict"
+const UnavailableCode = "unavailable"
+const TimeoutCode = "timeout"
+const InternalErrorCode = "internal_error"
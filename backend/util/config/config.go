@@ -1,13 +1,331 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
 	"os"
+	"reflect"
 	"strconv"
-)
+	"strings"
 
-// Use these to avoid syscalls, for example in controllers.
-var (
-	JWTKey         = os.Getenv("JWT_KEY")
-	JWTExpiry      = os.Getenv("JWT_EXPIRY")
-	MinFileSize, _ = strconv.Atoi(os.Getenv("MIN_FILE_SIZE"))
+	"github.com/joho/godotenv"
 )
+
+// All settings of the backend, each one is read from the environment variable in its env tag.
+// Settings can also be set in a file (KEY=VALUE lines) pointed to by CONFIG_FILE, the environment overrides the file.
+// Durations are in seconds unless stated otherwise. Settings tagged secret are hidden by --print-config.
+type Settings struct {
+	// Server.
+	ServerHost string `env:"SERVER_HOST"`
+	// Timeouts for reading a whole request, writing a response and keeping an idle keep-alive connection.
+	ServerReadTimeout  int `env:"SERVER_READ_TIMEOUT" default:"10"`
+	ServerWriteTimeout int `env:"SERVER_WRITE_TIMEOUT" default:"10"`
+	ServerIdleTimeout  int `env:"SERVER_IDLE_TIMEOUT" default:"180"`
+	// Serve HTTPS (with HTTP/2) with the certificate and key from these PEM files, both have to be set to enable it.
+	// The files are checked for changes every TLSReloadInterval.
+	TLSCertFile       string `env:"TLS_CERT_FILE"`
+	TLSKeyFile        string `env:"TLS_KEY_FILE"`
+	TLSReloadInterval int    `env:"TLS_RELOAD_INTERVAL" default:"60"`
+	// Address to serve a plain HTTP server on, redirecting every request to HTTPS. Disabled if it is not set.
+	HTTPRedirectHost string `env:"HTTP_REDIRECT_HOST"`
+	// Time to wait for requests in progress to finish after SIGTERM or SIGINT.
+	ShutdownTimeout int `env:"SHUTDOWN_TIMEOUT" default:"25"`
+	// Deadlines for handling a request: reads, writes and bulk operations (deleting many files, presigning many parts, exporting logs).
+	RequestTimeoutRead  int `env:"REQUEST_TIMEOUT_READ" default:"5"`
+	RequestTimeoutWrite int `env:"REQUEST_TIMEOUT_WRITE" default:"10"`
+	RequestTimeoutBulk  int `env:"REQUEST_TIMEOUT_BULK" default:"60"`
+	// Comma separated CIDRs or ips of proxies allowed to set the client's ip with X-Forwarded-For or X-Real-IP.
+	// If it is not set, the address of the connection is always used.
+	TrustedProxies string `env:"TRUSTED_PROXIES"`
+
+	// Observability.
+	// Address to serve prometheus /metrics on, metrics are disabled if it is not set.
+	MetricsHost string `env:"METRICS_HOST"`
+	// Level of the JSON logs: debug, info, warn or error.
+	LogLevel string `env:"LOG_LEVEL" default:"info"`
+	// Where to send traces: otlp, stdout or none.
+	TracingExporter string `env:"TRACING_EXPORTER" default:"none"`
+
+	// Databases.
+	DBURL string `env:"DB_URL" secret:"true"`
+	// Logging requests to the log database is disabled if it is not set.
+	LogDBURL string `env:"LOG_DB_URL" secret:"true"`
+
+	// Request log writer: logs are queued in a buffer of LogBufferSize and saved in batches of up to LogBatchSize
+	// every LogFlushInterval milliseconds. Logs that do not fit in the buffer are dropped, batches that fail
+	// to be saved are appended to files in LogSpillDir and saved later, or dropped if it is not set.
+	LogBufferSize    int    `env:"LOG_BUFFER_SIZE" default:"10000"`
+	LogBatchSize     int    `env:"LOG_BATCH_SIZE" default:"500"`
+	LogFlushInterval int    `env:"LOG_FLUSH_INTERVAL" default:"1000"`
+	LogSpillDir      string `env:"LOG_SPILL_DIR"`
+	// Days to keep raw request logs, at least 2 so that every day is rolled up into log_daily_ before it is removed.
+	LogRetentionDays int `env:"LOG_RETENTION_DAYS" default:"30"`
+	// How to store client ips in request logs: full, truncate - zero the last octet of IPv4 and all but
+	// the first 48 bits of IPv6, or hash - HMAC-SHA256 with LogIPHashKey.
+	LogIPMode    string `env:"LOG_IP_MODE" default:"full"`
+	LogIPHashKey string `env:"LOG_IP_HASH_KEY" secret:"true"`
+
+	// Authentication.
+	JWTKey string `env:"JWT_KEY" secret:"true"`
+	// JSON file with multiple JWT keys, used instead of JWT_KEY when set.
+	JWTKeysFile string `env:"JWT_KEYS_FILE"`
+	JWTExpiry   int    `env:"JWT_EXPIRY" default:"300"`
+	// Time in which a rotated refresh token can still be used without revoking its session, for parallel requests.
+	RefreshReuseInterval int `env:"REFRESH_REUSE_INTERVAL" default:"10"`
+	// Session (refresh token) lifetime, prolonged on every refresh.
+	SessionLifetime int `env:"SESSION_LIFETIME" default:"1209600"`
+	// Maximum number of active sessions per user, the oldest sessions are deleted when a new one is created, 0 means no limit.
+	MaxSessions int `env:"MAX_SESSIONS" default:"10"`
+	// Failed login attempts allowed for a username from one ip (locking it only for that ip) or for an ip before it gets locked.
+	LoginMaxAttempts   int `env:"LOGIN_MAX_ATTEMPTS" default:"5"`
+	LoginMaxIPAttempts int `env:"LOGIN_MAX_IP_ATTEMPTS" default:"20"`
+	// Lockout time, doubled with every failed attempt made after the lockout ends.
+	LoginLockoutTime int `env:"LOGIN_LOCKOUT_TIME" default:"60"`
+	// Where to keep rate limit buckets, either memory or postgres (to share them between backend instances).
+	RateLimitStore string `env:"RATE_LIMIT_STORE" default:"memory"`
+	// Rate limits of expensive routes: requests allowed in a period for each user, or for each ip if not logged in.
+	// Starting and resuming uploads presigns a url for every part of a file.
+	RateLimitUpload       int `env:"RATE_LIMIT_UPLOAD" default:"30"`
+	RateLimitUploadPeriod int `env:"RATE_LIMIT_UPLOAD_PERIOD" default:"60"`
+	// Searching users matches every username with LIKE and can be done without logging in.
+	RateLimitUserSearch       int `env:"RATE_LIMIT_USER_SEARCH" default:"60"`
+	RateLimitUserSearchPeriod int `env:"RATE_LIMIT_USER_SEARCH_PERIOD" default:"60"`
+	// Renaming a folder updates the path of every file in it.
+	RateLimitFolderName       int `env:"RATE_LIMIT_FOLDER_NAME" default:"20"`
+	RateLimitFolderNamePeriod int `env:"RATE_LIMIT_FOLDER_NAME_PERIOD" default:"60"`
+
+	// Storage.
+	// Either cloud (aws) or local (seaweedfs).
+	StorageOption string `env:"STORAGE_OPTION"`
+	// The smallest amount of bytes an uploaded file can have.
+	MinFileSize int `env:"MIN_FILE_SIZE" default:"1"`
+	// How long presigned urls stay valid, upload urls are used for the whole (resumable) upload.
+	PresignUploadTTL   int `env:"PRESIGN_UPLOAD_TTL" default:"345600"`
+	PresignDownloadTTL int `env:"PRESIGN_DOWNLOAD_TTL" default:"60"`
+	// Cloud storage, the credentials can also come from the default aws sources, for example an IAM role.
+	AWSRegion          string `env:"AWS_REGION"`
+	Bucket             string `env:"BUCKET"`
+	AWSAccessKeyID     string `env:"AWS_ACCESS_KEY_ID"`
+	AWSSecretAccessKey string `env:"AWS_SECRET_ACCESS_KEY" secret:"true"`
+	// Local storage.
+	// Whether to presign s3 requests for the docker network for tests, then browser s3 requests will not work.
+	LocalBackendTest        bool   `env:"LOCAL_BACKEND_TEST"`
+	LocalBackendS3Endpoint  string `env:"LOCAL_BACKEND_S3_ENDPOINT"`
+	LocalBrowserS3Endpoint  string `env:"LOCAL_BROWSER_S3_ENDPOINT"`
+	LocalBucket             string `env:"LOCAL_BUCKET"`
+	LocalAWSAccessKeyID     string `env:"LOCAL_AWS_ACCESS_KEY_ID"`
+	LocalAWSSecretAccessKey string `env:"LOCAL_AWS_SECRET_ACCESS_KEY" secret:"true"`
+}
+
+// Use this to avoid syscalls, for example in controllers.
+// It is loaded when the package is initialized, so that other packages can use it in their initialization,
+// and validated by main with Validate before anything else is started.
+var Config, loadErrors = load()
+
+// Read the settings from defaults, then CONFIG_FILE, then the environment.
+// Values that cannot be parsed are returned as errors and left at their defaults.
+func load() (Settings, []error) {
+	var errs []error
+	values := map[string]string{}
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		fileValues, err := godotenv.Read(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CONFIG_FILE: cannot read %s: %w", file, err))
+		}
+		for key, value := range fileValues {
+			values[key] = value
+		}
+	}
+
+	settings := Settings{}
+	v := reflect.ValueOf(&settings).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := field.Tag.Get("env")
+		// Set the default first, so that a value that cannot be parsed is reported once, not also by Validate.
+		if defaultValue := field.Tag.Get("default"); defaultValue != "" {
+			setField(v.Field(i), defaultValue)
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			value = values[name]
+		}
+		if value == "" {
+			continue
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %q %w", name, value, err))
+		}
+	}
+	return settings, errs
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return errors.New("is not an integer")
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return errors.New("is not a boolean (1, 0, true or false)")
+		}
+		field.SetBool(b)
+	}
+	return nil
+}
+
+// Check every setting and return all problems at once, so that they can be fixed in one go.
+func Validate() error {
+	errs := append([]error{}, loadErrors...)
+	s := Config
+	required := func(name, value string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s: is required", name))
+		}
+	}
+	atLeast := func(name string, value, minimum int) {
+		if value < minimum {
+			errs = append(errs, fmt.Errorf("%s: %d is less than %d", name, value, minimum))
+		}
+	}
+	oneOf := func(name, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: %q is not one of %s", name, value, strings.Join(allowed, ", ")))
+	}
+
+	required("SERVER_HOST", s.ServerHost)
+	atLeast("SERVER_READ_TIMEOUT", s.ServerReadTimeout, 1)
+	atLeast("SERVER_WRITE_TIMEOUT", s.ServerWriteTimeout, 1)
+	atLeast("SERVER_IDLE_TIMEOUT", s.ServerIdleTimeout, 1)
+	atLeast("SHUTDOWN_TIMEOUT", s.ShutdownTimeout, 1)
+	atLeast("REQUEST_TIMEOUT_READ", s.RequestTimeoutRead, 1)
+	atLeast("REQUEST_TIMEOUT_WRITE", s.RequestTimeoutWrite, 1)
+	atLeast("REQUEST_TIMEOUT_BULK", s.RequestTimeoutBulk, 1)
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE, TLS_KEY_FILE: have to be set together"))
+	}
+	atLeast("TLS_RELOAD_INTERVAL", s.TLSReloadInterval, 1)
+	if s.HTTPRedirectHost != "" && s.TLSCertFile == "" {
+		errs = append(errs, errors.New("HTTP_REDIRECT_HOST: requires TLS_CERT_FILE and TLS_KEY_FILE"))
+	}
+	if _, err := s.TrustedProxyPrefixes(); err != nil {
+		errs = append(errs, err)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: %q is not one of debug, info, warn, error", s.LogLevel))
+	}
+	oneOf("TRACING_EXPORTER", s.TracingExporter, "otlp", "stdout", "none")
+
+	required("DB_URL", s.DBURL)
+	atLeast("LOG_BUFFER_SIZE", s.LogBufferSize, 1)
+	atLeast("LOG_BATCH_SIZE", s.LogBatchSize, 1)
+	atLeast("LOG_FLUSH_INTERVAL", s.LogFlushInterval, 1)
+	atLeast("LOG_RETENTION_DAYS", s.LogRetentionDays, 2)
+	oneOf("LOG_IP_MODE", s.LogIPMode, "full", "truncate", "hash")
+	if s.LogIPMode == "hash" {
+		required("LOG_IP_HASH_KEY", s.LogIPHashKey)
+	}
+
+	if s.JWTKeysFile == "" {
+		required("JWT_KEY", s.JWTKey)
+	}
+	atLeast("JWT_EXPIRY", s.JWTExpiry, 1)
+	atLeast("REFRESH_REUSE_INTERVAL", s.RefreshReuseInterval, 0)
+	atLeast("SESSION_LIFETIME", s.SessionLifetime, 1)
+	atLeast("MAX_SESSIONS", s.MaxSessions, 0)
+	atLeast("LOGIN_MAX_ATTEMPTS", s.LoginMaxAttempts, 1)
+	atLeast("LOGIN_MAX_IP_ATTEMPTS", s.LoginMaxIPAttempts, 1)
+	atLeast("LOGIN_LOCKOUT_TIME", s.LoginLockoutTime, 1)
+	oneOf("RATE_LIMIT_STORE", s.RateLimitStore, "memory", "postgres")
+	atLeast("RATE_LIMIT_UPLOAD", s.RateLimitUpload, 1)
+	atLeast("RATE_LIMIT_USER_SEARCH", s.RateLimitUserSearch, 1)
+	atLeast("RATE_LIMIT_FOLDER_NAME", s.RateLimitFolderName, 1)
+	atLeast("RATE_LIMIT_UPLOAD_PERIOD", s.RateLimitUploadPeriod, 1)
+	atLeast("RATE_LIMIT_USER_SEARCH_PERIOD", s.RateLimitUserSearchPeriod, 1)
+	atLeast("RATE_LIMIT_FOLDER_NAME_PERIOD", s.RateLimitFolderNamePeriod, 1)
+	// The memory store removes buckets that were not used for an hour, they have to be full by then.
+	if s.RateLimitUploadPeriod > 3600 || s.RateLimitUserSearchPeriod > 3600 || s.RateLimitFolderNamePeriod > 3600 {
+		errs = append(errs, errors.New("RATE_LIMIT_UPLOAD_PERIOD, RATE_LIMIT_USER_SEARCH_PERIOD, RATE_LIMIT_FOLDER_NAME_PERIOD: have to be at most 3600 (an hour)"))
+	}
+
+	oneOf("STORAGE_OPTION", s.StorageOption, "cloud", "local")
+	atLeast("MIN_FILE_SIZE", s.MinFileSize, 1)
+	// S3 does not accept presigned urls valid for longer than 7 days.
+	atLeast("PRESIGN_UPLOAD_TTL", s.PresignUploadTTL, 1)
+	atLeast("PRESIGN_DOWNLOAD_TTL", s.PresignDownloadTTL, 1)
+	if s.PresignUploadTTL > 604800 || s.PresignDownloadTTL > 604800 {
+		errs = append(errs, errors.New("PRESIGN_UPLOAD_TTL, PRESIGN_DOWNLOAD_TTL: have to be at most 604800 (7 days)"))
+	}
+	if s.StorageOption == "cloud" {
+		required("AWS_REGION", s.AWSRegion)
+		required("BUCKET", s.Bucket)
+	}
+	if s.StorageOption == "local" {
+		required("LOCAL_BACKEND_S3_ENDPOINT", s.LocalBackendS3Endpoint)
+		required("LOCAL_BROWSER_S3_ENDPOINT", s.LocalBrowserS3Endpoint)
+		required("LOCAL_BUCKET", s.LocalBucket)
+		required("LOCAL_AWS_ACCESS_KEY_ID", s.LocalAWSAccessKeyID)
+		required("LOCAL_AWS_SECRET_ACCESS_KEY", s.LocalAWSSecretAccessKey)
+	}
+	return errors.Join(errs...)
+}
+
+// Parse TrustedProxies, a comma separated list of CIDRs or single ips.
+func (s Settings) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	for _, value := range strings.Split(s.TrustedProxies, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES: %q is not an ip or a CIDR", value)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %q is not an ip or a CIDR", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Write the settings as JSON keyed by their environment variables, with secrets that are set replaced by "***".
+func Print(w io.Writer) error {
+	printed := map[string]any{}
+	v := reflect.ValueOf(Config)
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		value := v.Field(i).Interface()
+		if field.Tag.Get("secret") == "true" && v.Field(i).String() != "" {
+			value = "***"
+		}
+		printed[field.Tag.Get("env")] = value
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(printed)
+}
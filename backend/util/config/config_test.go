@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Load the settings from the environment set by the test, validating them with Validate.
+func loadTest(t *testing.T) error {
+	t.Helper()
+	oldConfig, oldErrors := Config, loadErrors
+	t.Cleanup(func() { Config, loadErrors = oldConfig, oldErrors })
+	Config, loadErrors = load()
+	return Validate()
+}
+
+// Set the required settings, so that a test only has to set the ones it checks.
+func setRequired(t *testing.T) {
+	t.Helper()
+	for key, value := range map[string]string{"SERVER_HOST": ":8080", "DB_URL": "postgres://db", "JWT_KEY": "jwt-secret",
+		"STORAGE_OPTION": "cloud", "AWS_REGION": "eu-central-1", "BUCKET": "bucket", "CONFIG_FILE": ""} {
+		t.Setenv(key, value)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	setRequired(t)
+	file := filepath.Join(t.TempDir(), "backend.env")
+	err := os.WriteFile(file, []byte("LOG_BATCH_SIZE=200\nLOG_BUFFER_SIZE=300\nLOG_LEVEL=debug\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("LOG_BUFFER_SIZE", "400")
+	t.Setenv("LOG_LEVEL", "")
+
+	err = loadTest(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"default", Config.LogFlushInterval, 1000},
+		{"file over the default", Config.LogBatchSize, 200},
+		{"environment over the file", Config.LogBufferSize, 400},
+		// An empty variable overrides the file, leaving the default.
+		{"empty environment", Config.LogLevel, "info"},
+		{"environment", Config.ServerHost, ":8080"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		errors []string // Settings named in the error, nil if the config is valid.
+	}{
+		{name: "valid"},
+		{name: "not an integer", env: map[string]string{"JWT_EXPIRY": "soon"}, errors: []string{"JWT_EXPIRY"}},
+		{name: "not a boolean", env: map[string]string{"LOCAL_BACKEND_TEST": "maybe"}, errors: []string{"LOCAL_BACKEND_TEST"}},
+		{name: "too small", env: map[string]string{"LOG_RETENTION_DAYS": "1"}, errors: []string{"LOG_RETENTION_DAYS"}},
+		{name: "not allowed", env: map[string]string{"LOG_IP_MODE": "partial"}, errors: []string{"LOG_IP_MODE"}},
+		{name: "missing", env: map[string]string{"DB_URL": ""}, errors: []string{"DB_URL"}},
+		{name: "required by another", env: map[string]string{"LOG_IP_MODE": "hash"}, errors: []string{"LOG_IP_HASH_KEY"}},
+		{name: "invalid proxy", env: map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8, proxy"}, errors: []string{"TRUSTED_PROXIES"}},
+		{name: "rate limit period over an hour", env: map[string]string{"RATE_LIMIT_UPLOAD_PERIOD": "7200"}, errors: []string{"RATE_LIMIT_UPLOAD_PERIOD"}},
+		{name: "every problem at once", env: map[string]string{"JWT_EXPIRY": "0", "LOG_LEVEL": "loud", "BUCKET": ""},
+			errors: []string{"JWT_EXPIRY", "LOG_LEVEL", "BUCKET"}},
+		{name: "missing config file", env: map[string]string{"CONFIG_FILE": "/does/not/exist.env"}, errors: []string{"CONFIG_FILE"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setRequired(t)
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			err := loadTest(t)
+			if test.errors == nil && err != nil {
+				t.Fatal("valid config failed:", err)
+			}
+			if test.errors != nil && err == nil {
+				t.Fatal("invalid config passed")
+			}
+			for _, name := range test.errors {
+				if !strings.Contains(err.Error(), name) {
+					t.Errorf("error %q does not name %s", err, name)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintHidesSecrets(t *testing.T) {
+	setRequired(t)
+	t.Setenv("DB_URL", "postgres://user:db-password@db")
+	t.Setenv("LOG_DB_URL", "")
+	err := loadTest(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = Print(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "db-password") || strings.Contains(out.String(), "jwt-secret") {
+		t.Fatalf("printed a secret: %s", out.String())
+	}
+	printed := map[string]any{}
+	err = json.Unmarshal(out.Bytes(), &printed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Secrets that are set are hidden, empty ones are printed to show they are not set.
+	for key, want := range map[string]any{"DB_URL": "***", "JWT_KEY": "***", "LOG_DB_URL": "", "SERVER_HOST": ":8080", "JWT_EXPIRY": 300.0} {
+		if printed[key] != want {
+			t.Errorf("printed %s as %v, want %v", key, printed[key], want)
+		}
+	}
+}
@@ -2,36 +2,24 @@ package cookieutil
 
 import (
 	c "backend/util/config"
+	"backend/util/jwtutil"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// JWT expiry time set in seconds.
-var expiryTime int
-
-func init() {
-	var err error
-	expiryTime, err = strconv.Atoi(c.JWTExpiry)
-	if err != nil {
-		panic(err)
-	}
-}
-
 func CreateJWTCookie(userID int, refreshToken string) (*http.Cookie, error) {
 	// If the user's refresh token is still valid, create a new JWT.
-	newClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": userID,                                                         // Subject (user identifier)
-		"iss": "file_hosting",                                                 // Issuer
-		"exp": time.Now().Add(time.Second * time.Duration(expiryTime)).Unix(), // Expiry time
-		"iat": time.Now().Unix(),                                              // Issued at
+	tokenString, err := jwtutil.Sign(jwt.MapClaims{
+		"sub": userID,                                                                 // Subject (user identifier)
+		"iss": "file_hosting",                                                         // Issuer
+		"exp": time.Now().Add(time.Second * time.Duration(c.Config.JWTExpiry)).Unix(), // Expiry time
+		"iat": time.Now().Unix(),                                                      // Issued at
 		// Custom field below, checked on a request and used to prolong the refresh token.
 		"refreshtoken": refreshToken,
 	})
-	// SignedString() expects the argument to be of []byte type.
-	tokenString, err := newClaims.SignedString([]byte(c.JWTKey))
 	if err != nil {
 		return nil, err
 	}
@@ -41,10 +29,24 @@ func CreateJWTCookie(userID int, refreshToken string) (*http.Cookie, error) {
 		Name:     "file_hosting",
 		Path:     "/api",
 		Value:    tokenString,
-		MaxAge:   60 * 60 * 24 * 14, // 14 days in seconds
+		MaxAge:   c.Config.SessionLifetime,
 		Secure:   true,
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
 	}
 	return &cookie, nil
 }
+
+// Get the JWT from the cookie, or from an "Authorization: Bearer" header for clients that do not keep cookies.
+// The cookie is preferred when both are sent.
+func GetJWT(r *http.Request) (string, error) {
+	cookie, err := r.Cookie("file_hosting")
+	if err == nil {
+		return cookie.Value, nil
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", err
+	}
+	return token, nil
+}
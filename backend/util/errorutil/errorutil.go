@@ -0,0 +1,133 @@
+package errorutil
+
+import (
+	"backend/database/errorcodes"
+	"backend/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Implemented by the request meta created by the RequestID middleware, to save the error in the request log.
+type errorSetter interface {
+	SetError(code, message string)
+	SetLoggedError(code, message string)
+}
+
+// Write an error response with the types.ErrorResponse body and save the error for the request log.
+// Errors returned to the client replace a logged error, except for server errors
+// where the logged error is kept since it tells more about the cause.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, message string, details map[string]any) {
+	if meta, ok := r.Context().Value(types.ContextKey("meta")).(errorSetter); ok {
+		if status >= http.StatusInternalServerError {
+			meta.SetLoggedError(code, message)
+		} else {
+			meta.SetError(code, message)
+		}
+	}
+	requestID, _ := r.Context().Value(types.ContextKey("requestID")).(string)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(types.ErrorResponse{Code: code, Message: message, Details: details, RequestID: requestID})
+}
+
+// Codes of the statuses written with Status.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          types.BadRequestCode,
+	http.StatusUnauthorized:        types.UnauthorizedCode,
+	http.StatusForbidden:           types.ForbiddenCode,
+	http.StatusNotFound:            types.NotFoundCode,
+	http.StatusConflict:            types.ConflictCode,
+	http.StatusServiceUnavailable:  types.UnavailableCode,
+	http.StatusGatewayTimeout:      types.TimeoutCode,
+	http.StatusInternalServerError: types.InternalErrorCode,
+}
+
+// Write an error response with the generic code and message of the status.
+func Status(w http.ResponseWriter, r *http.Request, status int) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = types.InternalErrorCode
+	}
+	Write(w, r, status, code, http.StatusText(status), nil)
+}
+
+// Write a 400 response for a request that was decoded but failed validation,
+// field is the name of the invalid field or query parameter.
+func Invalid(w http.ResponseWriter, r *http.Request, field, reason string) {
+	Write(w, r, http.StatusBadRequest, types.ValidationFailedCode, types.ValidationFailed, map[string]any{field: reason})
+}
+
+// Decode a JSON request body of up to limit bytes into v.
+// If it cannot be decoded an error response is written and false is returned:
+// 413 if the body is larger than limit, 400 if the body is not valid JSON or does not match v.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, limit int64, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(v)
+	if err == nil {
+		return true
+	}
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		Write(w, r, http.StatusRequestEntityTooLarge, types.BodyTooLargeCode, "Request body is too large",
+			map[string]any{"limit": maxBytesErr.Limit})
+	case errors.As(err, &syntaxErr):
+		Write(w, r, http.StatusBadRequest, types.InvalidJSONCode, "Request body is not valid JSON",
+			map[string]any{"offset": syntaxErr.Offset, "error": syntaxErr.Error()})
+	case errors.As(err, &typeErr):
+		Write(w, r, http.StatusBadRequest, types.InvalidJSONCode, "Request body has a field of the wrong type",
+			map[string]any{typeErr.Field: "expected " + typeErr.Type.String()})
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		Write(w, r, http.StatusBadRequest, types.InvalidJSONCode, "Request body is empty or incomplete", nil)
+	default:
+		Write(w, r, http.StatusBadRequest, types.InvalidJSONCode, "Request body could not be decoded", nil)
+	}
+	return false
+}
+
+// Response to an error returned by a database or storage call.
+type response struct {
+	status  int
+	code    string
+	message string
+}
+
+// Typed errors in the order they are checked, the first one err wraps decides the response.
+var responses = []struct {
+	err error
+	response
+}{
+	{errorcodes.ErrUserHasNoSpace, response{http.StatusForbidden, types.UserHasNoSpaceCode, types.UserHasNoSpace}},
+	{errorcodes.ErrInsufficientPermission, response{http.StatusForbidden, types.InsufficientPermissionCode, types.InsufficientPermission}},
+	{errorcodes.ErrPrivilegeNotGranted, response{http.StatusForbidden, types.ForbiddenCode, "User does not have access to this resource"}},
+	{errorcodes.ErrFileAlreadyExists, response{http.StatusConflict, types.FileAlreadyExistsCode, types.FileAlreadyExists}},
+	{errorcodes.ErrContainingFolderDoesNotExist, response{http.StatusNotFound, types.ContainingFolderDoesNotExistCode, types.ContainingFolderDoesNotExist}},
+	{errorcodes.ErrResourceDoesNotExist, response{http.StatusNotFound, types.NotFoundCode, "Resource does not exist"}},
+	{pgx.ErrNoRows, response{http.StatusNotFound, types.NotFoundCode, "Resource does not exist"}},
+	{errorcodes.ErrUniqueViolation, response{http.StatusConflict, types.ConflictCode, "Resource already exists"}},
+	{errorcodes.ErrSerializationFailure, response{http.StatusServiceUnavailable, types.UnavailableCode, "Server is busy, try again"}},
+	{errorcodes.ErrDeadlockDetected, response{http.StatusServiceUnavailable, types.UnavailableCode, "Server is busy, try again"}},
+	{context.DeadlineExceeded, response{http.StatusGatewayTimeout, types.TimeoutCode, "Request took too long"}},
+}
+
+// Write the error response for err, using the typed errors of database/errorcodes to choose the status.
+// Errors without a typed error are internal server errors.
+// err should already be logged with logutil.LogError, so that the log has the source of the error.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	for _, typed := range responses {
+		if errors.Is(err, typed.err) {
+			if typed.status == http.StatusServiceUnavailable {
+				w.Header().Set("Retry-After", "1")
+			}
+			Write(w, r, typed.status, typed.code, typed.message, nil)
+			return
+		}
+	}
+	Status(w, r, http.StatusInternalServerError)
+}
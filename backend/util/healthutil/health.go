@@ -0,0 +1,76 @@
+package healthutil
+
+import (
+	db "backend/database"
+	logdb "backend/logdatabase"
+	"backend/storage"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var shuttingDown atomic.Bool
+
+// Report the backend as not ready, so that no new requests are sent to it while it drains the current ones.
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+func ShuttingDown() bool {
+	return shuttingDown.Load()
+}
+
+// The result of checking a dependency.
+// Status is "ok", "error" or "disabled" for the optional log database.
+type Dependency struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency"` // In milliseconds.
+	Error   string  `json:"error,omitempty"`
+}
+
+// Check the main database, the log database and the storage bucket in parallel, each with its own timeout.
+// ok is false if any enabled dependency failed.
+func Check(ctx context.Context) (dependencies []Dependency, ok bool) {
+	checks := []struct {
+		name  string
+		check func(context.Context) error
+	}{
+		{"database", db.Ping},
+		{"logDatabase", logdb.Ping},
+		{"storage", storage.HeadBucket},
+	}
+	dependencies = make([]Dependency, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		dependencies[i].Name = check.name
+		// Logging to the log database is optional.
+		if check.name == "logDatabase" && logdb.Pool == nil {
+			dependencies[i].Status = "disabled"
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, time.Second*2)
+			defer cancel()
+			start := time.Now()
+			err := check.check(ctx)
+			dependencies[i].Latency = float64(time.Since(start).Microseconds()) / 1000
+			dependencies[i].Status = "ok"
+			if err != nil {
+				dependencies[i].Status = "error"
+				dependencies[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	ok = true
+	for _, dependency := range dependencies {
+		if dependency.Status == "error" {
+			ok = false
+		}
+	}
+	return dependencies, ok
+}
@@ -0,0 +1,86 @@
+package iputil
+
+import (
+	"backend/types"
+	c "backend/util/config"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Proxies allowed to set the client's ip with X-Forwarded-For or X-Real-IP, parsed from TRUSTED_PROXIES
+// by InitTrustedProxies.
+var trustedProxies []netip.Prefix
+
+// Parse TRUSTED_PROXIES, it is validated at startup by config.Validate.
+func InitTrustedProxies() {
+	trustedProxies, _ = c.Config.TrustedProxyPrefixes()
+}
+
+func isTrusted(addr netip.Addr) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAddr(value string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(value))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// Get the client's ip resolved by the ClientIP middleware, or resolve it if the middleware was not run.
+func GetIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(types.ContextKey("ip")).(string); ok {
+		return ip
+	}
+	return ResolveIP(r)
+}
+
+// Resolve the client's ip. Proxy headers are only read if the connection comes from a trusted proxy,
+// then X-Forwarded-For is read from the right, skipping trusted proxies, so that a client cannot spoof it.
+// X-Real-IP is used if X-Forwarded-For is not set.
+func ResolveIP(r *http.Request) string {
+	// Remove the port from the address, so that each connection from the same ip gives the same result.
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote, ok := parseAddr(host)
+	if !ok {
+		return host
+	}
+	if !isTrusted(remote) {
+		return remote.String()
+	}
+
+	// Every proxy appends the address it got the request from.
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	client := remote
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(forwarded[i])
+		if !ok {
+			// Everything to the left of an invalid value cannot be trusted.
+			break
+		}
+		client = addr
+		if !isTrusted(addr) {
+			return client.String()
+		}
+	}
+	if len(forwarded) == 0 {
+		if addr, ok := parseAddr(r.Header.Get("X-Real-IP")); ok {
+			return addr.String()
+		}
+	}
+	return client.String()
+}
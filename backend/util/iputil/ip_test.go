@@ -0,0 +1,83 @@
+package iputil
+
+import (
+	c "backend/util/config"
+	"net/http"
+	"testing"
+)
+
+func TestResolveIP(t *testing.T) {
+	oldProxies := c.Config.TrustedProxies
+	t.Cleanup(func() {
+		c.Config.TrustedProxies = oldProxies
+		InitTrustedProxies()
+	})
+	c.Config.TrustedProxies = "10.0.0.0/8, 192.168.1.1"
+	InitTrustedProxies()
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string // X-Forwarded-For headers.
+		realIP     string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.5:1234", want: "203.0.113.5"},
+		{name: "untrusted remote spoofing X-Forwarded-For", remoteAddr: "203.0.113.5:1234", forwarded: []string{"198.51.100.7"}, want: "203.0.113.5"},
+		{name: "untrusted remote spoofing X-Real-IP", remoteAddr: "203.0.113.5:1234", realIP: "198.51.100.7", want: "203.0.113.5"},
+		{name: "trusted proxy without headers", remoteAddr: "10.0.0.1:443", want: "10.0.0.1"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:443", forwarded: []string{"198.51.100.7"}, want: "198.51.100.7"},
+		{name: "trusted chain", remoteAddr: "10.0.0.1:443", forwarded: []string{"198.51.100.7, 10.0.0.2, 192.168.1.1"}, want: "198.51.100.7"},
+		{name: "client spoofing the start of the chain", remoteAddr: "10.0.0.1:443", forwarded: []string{"6.6.6.6, 198.51.100.7, 10.0.0.2"}, want: "198.51.100.7"},
+		{name: "only trusted proxies in the chain", remoteAddr: "10.0.0.1:443", forwarded: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "untrusted proxy in the chain", remoteAddr: "10.0.0.1:443", forwarded: []string{"198.51.100.7, 192.168.1.2"}, want: "192.168.1.2"},
+		{name: "invalid entry next to the proxy", remoteAddr: "10.0.0.1:443", forwarded: []string{"198.51.100.7, garbage"}, want: "10.0.0.1"},
+		{name: "invalid entry in the chain", remoteAddr: "10.0.0.1:443", forwarded: []string{"198.51.100.7, garbage, 10.0.0.2"}, want: "10.0.0.2"},
+		{name: "invalid entry spoofed by the client", remoteAddr: "10.0.0.1:443", forwarded: []string{"garbage, 198.51.100.7"}, want: "198.51.100.7"},
+		{name: "entry with a port", remoteAddr: "10.0.0.1:443", forwarded: []string{"198.51.100.7:5555"}, want: "10.0.0.1"},
+		{name: "empty entry", remoteAddr: "10.0.0.1:443", forwarded: []string{""}, want: "10.0.0.1"},
+		{name: "multiple headers", remoteAddr: "10.0.0.1:443", forwarded: []string{"6.6.6.6", "198.51.100.7, 10.0.0.2"}, want: "198.51.100.7"},
+		{name: "multiple headers spoofed in the first", remoteAddr: "10.0.0.1:443", forwarded: []string{"6.6.6.6, 10.0.0.5", "10.0.0.2"}, want: "6.6.6.6"},
+		{name: "X-Real-IP from a trusted proxy", remoteAddr: "10.0.0.1:443", realIP: "198.51.100.7", want: "198.51.100.7"},
+		{name: "invalid X-Real-IP", remoteAddr: "10.0.0.1:443", realIP: "garbage", want: "10.0.0.1"},
+		{name: "X-Forwarded-For before X-Real-IP", remoteAddr: "10.0.0.1:443", forwarded: []string{"198.51.100.7"}, realIP: "6.6.6.6", want: "198.51.100.7"},
+		{name: "IPv4-mapped trusted remote", remoteAddr: "[::ffff:10.0.0.1]:443", forwarded: []string{"198.51.100.7"}, want: "198.51.100.7"},
+		{name: "IPv4-mapped untrusted remote", remoteAddr: "[::ffff:203.0.113.5]:443", forwarded: []string{"198.51.100.7"}, want: "203.0.113.5"},
+		{name: "IPv4-mapped forwarded client", remoteAddr: "10.0.0.1:443", forwarded: []string{"::ffff:198.51.100.7"}, want: "198.51.100.7"},
+		{name: "IPv4-mapped forwarded proxy", remoteAddr: "10.0.0.1:443", forwarded: []string{"198.51.100.7, ::ffff:10.0.0.2"}, want: "198.51.100.7"},
+		{name: "IPv6 client", remoteAddr: "[2001:db8::1]:443", forwarded: []string{"198.51.100.7"}, want: "2001:db8::1"},
+		{name: "remote without a port", remoteAddr: "203.0.113.5", want: "203.0.113.5"},
+		{name: "remote that is not an ip", remoteAddr: "@", forwarded: []string{"198.51.100.7"}, want: "@"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: test.remoteAddr, Header: http.Header{}}
+			for _, value := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if test.realIP != "" {
+				r.Header.Set("X-Real-IP", test.realIP)
+			}
+			if got := ResolveIP(r); got != test.want {
+				t.Fatalf("ResolveIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveIPWithoutTrustedProxies(t *testing.T) {
+	oldProxies := c.Config.TrustedProxies
+	t.Cleanup(func() {
+		c.Config.TrustedProxies = oldProxies
+		InitTrustedProxies()
+	})
+	c.Config.TrustedProxies = ""
+	InitTrustedProxies()
+
+	r := &http.Request{RemoteAddr: "10.0.0.1:443", Header: http.Header{}}
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	r.Header.Set("X-Real-IP", "198.51.100.7")
+	if got := ResolveIP(r); got != "10.0.0.1" {
+		t.Fatalf("ResolveIP() = %q, want the remote address when no proxy is trusted", got)
+	}
+}
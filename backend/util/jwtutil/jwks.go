@@ -0,0 +1,54 @@
+package jwtutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/base64"
+	"sort"
+	"time"
+)
+
+// JSON Web Key Set, see https://datatracker.ietf.org/doc/html/rfc7517
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y,omitempty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// Get the public keys that tokens can currently be verified with.
+// HS256 keys are secret and never included.
+func GetJWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range keys {
+		if k.Expires != nil && time.Now().After(*k.Expires) {
+			continue
+		}
+		switch public := k.VerifyKey.(type) {
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{KeyType: "OKP", Curve: "Ed25519", X: base64.RawURLEncoding.EncodeToString(public),
+				KeyID: k.ID, Algorithm: k.Method.Alg(), Use: "sig"})
+		case *ecdsa.PublicKey:
+			ecdhKey, err := public.ECDH()
+			if err != nil {
+				continue
+			}
+			// Uncompressed point: 0x04 || X || Y, each coordinate 32 bytes long for P-256.
+			point := ecdhKey.Bytes()
+			set.Keys = append(set.Keys, JWK{KeyType: "EC", Curve: "P-256", X: base64.RawURLEncoding.EncodeToString(point[1:33]),
+				Y: base64.RawURLEncoding.EncodeToString(point[33:]), KeyID: k.ID, Algorithm: k.Method.Alg(), Use: "sig"})
+		}
+	}
+	// Keep the order stable between requests.
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+	return set
+}
@@ -0,0 +1,172 @@
+package jwtutil
+
+import (
+	c "backend/util/config"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// A key used to sign or verify JWTs, identified by the kid header.
+type key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   any        // []byte for HS256, ed25519.PrivateKey for EdDSA, *ecdsa.PrivateKey for ES256.
+	VerifyKey any        // []byte for HS256, ed25519.PublicKey for EdDSA, *ecdsa.PublicKey for ES256.
+	Expires   *time.Time // After this time tokens signed with the key are rejected, nil if the key does not expire.
+}
+
+// Keys file schema, see JWT_KEYS_FILE in compose files.
+type keysFile struct {
+	SigningKey string    `json:"signingKey"` // kid of the key used to sign new tokens.
+	Keys       []fileKey `json:"keys"`
+}
+
+type fileKey struct {
+	ID             string     `json:"kid"`
+	Algorithm      string     `json:"alg"`            // HS256, EdDSA or ES256.
+	Secret         string     `json:"secret"`         // Used with HS256.
+	PrivateKeyFile string     `json:"privateKeyFile"` // PKCS #8 PEM file used with EdDSA and ES256.
+	Expires        *time.Time `json:"expires"`        // Set when rotating the key out, gives old tokens a grace period to be refreshed.
+}
+
+// kid of the key made from JWT_KEY when no keys file is set.
+// Tokens without a kid header (signed before key rotation was added) are verified with this key.
+const legacyKeyID = "default"
+
+var (
+	keys       = map[string]key{}
+	signingKey key
+)
+
+var validMethods = []string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodES256.Alg()}
+
+// Load the signing and verification keys from JWT_KEYS_FILE or JWT_KEY.
+func InitKeys() {
+	err := loadKeys()
+	if err != nil {
+		log.Fatalln("Error loading JWT keys:", err)
+	}
+}
+
+func loadKeys() error {
+	if c.Config.JWTKeysFile == "" {
+		if c.Config.JWTKey == "" {
+			return errors.New("neither JWT_KEYS_FILE nor JWT_KEY is set")
+		}
+		signingKey = key{ID: legacyKeyID, Method: jwt.SigningMethodHS256, SignKey: []byte(c.Config.JWTKey), VerifyKey: []byte(c.Config.JWTKey)}
+		keys[legacyKeyID] = signingKey
+		return nil
+	}
+
+	data, err := os.ReadFile(c.Config.JWTKeysFile)
+	if err != nil {
+		return err
+	}
+	file := keysFile{}
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return fmt.Errorf("invalid JWT keys file: %w", err)
+	}
+	for _, k := range file.Keys {
+		loaded, err := loadKey(k)
+		if err != nil {
+			return fmt.Errorf("invalid JWT key %q: %w", k.ID, err)
+		}
+		if _, ok := keys[k.ID]; ok {
+			return fmt.Errorf("duplicate JWT key %q", k.ID)
+		}
+		keys[k.ID] = loaded
+	}
+	var ok bool
+	signingKey, ok = keys[file.SigningKey]
+	if !ok {
+		return fmt.Errorf("JWT signing key %q is not in the keys file", file.SigningKey)
+	}
+	if signingKey.Expires != nil {
+		return errors.New("JWT signing key cannot have an expiry date")
+	}
+	return nil
+}
+
+func loadKey(k fileKey) (key, error) {
+	if k.ID == "" {
+		return key{}, errors.New("kid is empty")
+	}
+	if k.Algorithm == jwt.SigningMethodHS256.Alg() {
+		if k.Secret == "" {
+			return key{}, errors.New("secret is empty")
+		}
+		return key{ID: k.ID, Method: jwt.SigningMethodHS256, SignKey: []byte(k.Secret), VerifyKey: []byte(k.Secret), Expires: k.Expires}, nil
+	}
+
+	data, err := os.ReadFile(k.PrivateKeyFile)
+	if err != nil {
+		return key{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return key{}, errors.New("no PEM block found in the private key file")
+	}
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return key{}, err
+	}
+	switch k.Algorithm {
+	case jwt.SigningMethodEdDSA.Alg():
+		private, ok := private.(ed25519.PrivateKey)
+		if !ok {
+			return key{}, errors.New("EdDSA key is not an Ed25519 key")
+		}
+		return key{ID: k.ID, Method: jwt.SigningMethodEdDSA, SignKey: private, VerifyKey: private.Public(), Expires: k.Expires}, nil
+	case jwt.SigningMethodES256.Alg():
+		private, ok := private.(*ecdsa.PrivateKey)
+		if !ok || private.Curve != elliptic.P256() {
+			return key{}, errors.New("ES256 key is not a P-256 key")
+		}
+		return key{ID: k.ID, Method: jwt.SigningMethodES256, SignKey: private, VerifyKey: &private.PublicKey, Expires: k.Expires}, nil
+	}
+	return key{}, fmt.Errorf("unsupported algorithm %q", k.Algorithm)
+}
+
+// Sign the claims with the current signing key and set its kid in the header.
+func Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.ID
+	return token.SignedString(signingKey.SignKey)
+}
+
+// Parse and verify the token with the key from its kid header.
+// Like jwt.Parse, an expired token with a valid signature is returned along with jwt.ErrTokenExpired.
+func Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, getVerifyKey, jwt.WithValidMethods(validMethods))
+}
+
+func getVerifyKey(token *jwt.Token) (any, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		kid = legacyKeyID
+	}
+	k, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown JWT key %q", kid)
+	}
+	// Make sure the token cannot choose a different algorithm than the key's.
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("JWT key %q does not use %s", kid, token.Method.Alg())
+	}
+	if k.Expires != nil && time.Now().After(*k.Expires) {
+		return nil, fmt.Errorf("JWT key %q has expired", kid)
+	}
+	return k.VerifyKey, nil
+}
@@ -0,0 +1,199 @@
+package jwtutil
+
+import (
+	c "backend/util/config"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Private keys of the keys file loaded by loadTestKeys.
+type testKeys struct {
+	ed    ed25519.PrivateKey
+	ec    *ecdsa.PrivateKey
+	oldEd ed25519.PrivateKey
+}
+
+func writePrivateKey(t *testing.T, dir, name string, private any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Load a keys file signing with an EdDSA key, with an ES256 and an HS256 key to verify,
+// an EdDSA key past its grace period and an HS256 key still in it.
+func loadTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	oldKeys, oldSigningKey, oldKeysFile := keys, signingKey, c.Config.JWTKeysFile
+	t.Cleanup(func() {
+		keys, signingKey, c.Config.JWTKeysFile = oldKeys, oldSigningKey, oldKeysFile
+	})
+
+	dir := t.TempDir()
+	var private testKeys
+	_, private.ed, _ = ed25519.GenerateKey(rand.Reader)
+	_, private.oldEd, _ = ed25519.GenerateKey(rand.Reader)
+	var err error
+	private.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Now().Add(-time.Hour)
+	grace := time.Now().Add(time.Hour)
+	file := keysFile{SigningKey: "ed", Keys: []fileKey{
+		{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: writePrivateKey(t, dir, "ed.pem", private.ed)},
+		{ID: "ec", Algorithm: "ES256", PrivateKeyFile: writePrivateKey(t, dir, "ec.pem", private.ec)},
+		{ID: "hs", Algorithm: "HS256", Secret: "secret", Expires: &grace},
+		{ID: "old", Algorithm: "EdDSA", PrivateKeyFile: writePrivateKey(t, dir, "old.pem", private.oldEd), Expires: &expired},
+	}}
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Config.JWTKeysFile = filepath.Join(dir, "keys.json")
+	err = os.WriteFile(c.Config.JWTKeysFile, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, signingKey = map[string]key{}, key{}
+	err = loadKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return private
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, signKey any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, jwt.MapClaims{"id": 1, "exp": time.Now().Add(time.Minute).Unix()})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	tokenString, err := token.SignedString(signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenString
+}
+
+func TestParse(t *testing.T) {
+	private := loadTestKeys(t)
+	signed, err := Sign(jwt.MapClaims{"id": 1, "exp": time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "signed with the signing key", token: signed, valid: true},
+		{name: "verification key", token: sign(t, jwt.SigningMethodES256, "ec", private.ec), valid: true},
+		{name: "key in its grace period", token: sign(t, jwt.SigningMethodHS256, "hs", []byte("secret")), valid: true},
+		{name: "unknown kid", token: sign(t, jwt.SigningMethodHS256, "missing", []byte("secret"))},
+		{name: "no kid without a legacy key", token: sign(t, jwt.SigningMethodHS256, "", []byte("secret"))},
+		{name: "key past its grace period", token: sign(t, jwt.SigningMethodEdDSA, "old", private.oldEd)},
+		// HS256 signed with the public key, the classic algorithm confusion attack.
+		{name: "HS256 with an EdDSA kid", token: sign(t, jwt.SigningMethodHS256, "ed", []byte(private.ed.Public().(ed25519.PublicKey)))},
+		{name: "ES256 with an EdDSA kid", token: sign(t, jwt.SigningMethodES256, "ed", private.ec)},
+		{name: "EdDSA with an ES256 kid", token: sign(t, jwt.SigningMethodEdDSA, "ec", private.ed)},
+		{name: "EdDSA with an HS256 kid", token: sign(t, jwt.SigningMethodEdDSA, "hs", private.ed)},
+		{name: "signed with another key", token: sign(t, jwt.SigningMethodEdDSA, "ed", private.oldEd)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := Parse(test.token)
+			if test.valid && (err != nil || !token.Valid) {
+				t.Fatal("valid token was rejected:", err)
+			}
+			if !test.valid && err == nil {
+				t.Fatal("invalid token was accepted")
+			}
+		})
+	}
+}
+
+func TestSignSetsKid(t *testing.T) {
+	loadTestKeys(t)
+	signed, err := Sign(jwt.MapClaims{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(signed, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Header["kid"] != "ed" || token.Method.Alg() != "EdDSA" {
+		t.Fatalf("signed with kid %v and %s, want ed and EdDSA", token.Header["kid"], token.Method.Alg())
+	}
+}
+
+func TestGetJWKS(t *testing.T) {
+	private := loadTestKeys(t)
+	set := GetJWKS()
+
+	// The HS256 key is secret and the old key is past its grace period.
+	if len(set.Keys) != 2 || set.Keys[0].KeyID != "ec" || set.Keys[1].KeyID != "ed" {
+		t.Fatalf("got keys %+v, want only ec and ed", set.Keys)
+	}
+	ec, ed := set.Keys[0], set.Keys[1]
+	if ed.KeyType != "OKP" || ed.Curve != "Ed25519" || ed.Algorithm != "EdDSA" || ed.Use != "sig" || ed.Y != "" {
+		t.Fatalf("got EdDSA key %+v", ed)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(ed.X)
+	if err != nil || !private.ed.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+		t.Fatal("EdDSA key is not the public key")
+	}
+	if ec.KeyType != "EC" || ec.Curve != "P-256" || ec.Algorithm != "ES256" || ec.Use != "sig" {
+		t.Fatalf("got ES256 key %+v", ec)
+	}
+	x, errX := base64.RawURLEncoding.DecodeString(ec.X)
+	y, errY := base64.RawURLEncoding.DecodeString(ec.Y)
+	if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+		t.Fatal("ES256 coordinates are not 32 bytes long")
+	}
+	// The uncompressed point is 4 followed by the coordinates.
+	public, err := private.ec.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(public.Bytes(), append(append([]byte{4}, x...), y...)) {
+		t.Fatal("ES256 key is not the public key")
+	}
+
+	// Nothing private is published.
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"d"`) || strings.Contains(string(data), "secret") {
+		t.Fatalf("JWKS has private parts: %s", data)
+	}
+	privateD := base64.RawURLEncoding.EncodeToString(private.ec.D.Bytes())
+	seed := base64.RawURLEncoding.EncodeToString(private.ed.Seed())
+	if strings.Contains(string(data), privateD) || strings.Contains(string(data), seed) {
+		t.Fatalf("JWKS has private keys: %s", data)
+	}
+}
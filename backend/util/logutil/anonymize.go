@@ -0,0 +1,33 @@
+package logutil
+
+import (
+	c "backend/util/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
+)
+
+// Anonymize the client's ip before it is saved in the log database, as set with LOG_IP_MODE.
+// Also used on ips searched for in the log database, so that they match the saved ones.
+func AnonymizeIP(ip string) string {
+	switch c.Config.LogIPMode {
+	case "truncate":
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return ip
+		}
+		bits := 48
+		if addr.Is4() {
+			bits = 24
+		}
+		prefix, _ := addr.Prefix(bits)
+		return prefix.Addr().String()
+	case "hash":
+		// Keyed, so that the hashes cannot be reversed by hashing every IPv4 address.
+		mac := hmac.New(sha256.New, []byte(c.Config.LogIPHashKey))
+		mac.Write([]byte(ip))
+		return hex.EncodeToString(mac.Sum(nil)[:16])
+	}
+	return ip
+}
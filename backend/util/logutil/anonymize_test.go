@@ -0,0 +1,44 @@
+package logutil
+
+import (
+	c "backend/util/config"
+	"testing"
+)
+
+func setIPMode(t *testing.T, mode, key string) {
+	t.Helper()
+	oldMode, oldKey := c.Config.LogIPMode, c.Config.LogIPHashKey
+	t.Cleanup(func() { c.Config.LogIPMode, c.Config.LogIPHashKey = oldMode, oldKey })
+	c.Config.LogIPMode, c.Config.LogIPHashKey = mode, key
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		mode, ip, want string
+	}{
+		{"full", "203.0.113.5", "203.0.113.5"},
+		{"truncate", "203.0.113.5", "203.0.113.0"},
+		{"truncate", "2001:db8:1:2:3:4:5:6", "2001:db8:1::"},
+		{"truncate", "not an ip", "not an ip"},
+		// HMAC-SHA256 of the ip with the key "secret", cut to 16 bytes.
+		{"hash", "203.0.113.5", "c3565eede5987d19bbfb6f55d9e95d83"},
+	}
+	for _, test := range tests {
+		setIPMode(t, test.mode, "secret")
+		if got := AnonymizeIP(test.ip); got != test.want {
+			t.Errorf("%s: AnonymizeIP(%q) = %q, want %q", test.mode, test.ip, got, test.want)
+		}
+	}
+}
+
+func TestLogAnonymizesIP(t *testing.T) {
+	oldEntries := entries
+	t.Cleanup(func() { entries = oldEntries })
+	entries = make(chan Entry, 1)
+	setIPMode(t, "truncate", "")
+
+	Log(Entry{IP: "203.0.113.5"})
+	if entry := <-entries; entry.IP != "203.0.113.0" {
+		t.Fatalf("logged ip %q, want 203.0.113.0", entry.IP)
+	}
+}
@@ -1,31 +1,35 @@
 package logutil
 
 import (
-	logdb "backend/logdatabase"
-	"context"
-	"fmt"
 	"time"
-
-	"github.com/jackc/pgx/v5"
 )
 
-// Create a log in the log database.
-func Log(ip string, userID int, username string, executionTime float64, endpoint string, method string, status int) {
-	// Get a connection from the log database.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-	conn, err := logdb.GetConnection(ctx)
-	defer conn.Release()
-	if err != nil {
-		fmt.Println("Failed to log data: " + err.Error())
-		return
-	}
+// A request to be saved in the log database.
+type Entry struct {
+	Date          time.Time
+	IP            string
+	UserID        int
+	Username      string
+	ExecutionTime float64 // In milliseconds.
+	Endpoint      string  // The request path.
+	Route         string  // The chi route pattern, for example "/api/repository/{id}".
+	Method        string
+	Status        int
+	Bytes         int // Response body size.
+	RequestID     string
+	UserAgent     string
+	ErrorCode     string
+	ErrorMessage  string
+}
 
-	_, err = conn.Exec(ctx, "INSERT INTO log_ VALUES (DEFAULT, CURRENT_TIMESTAMP(0), @ip, @userID, @username, @time, @endpoint, @method, @status)",
-		pgx.NamedArgs{"ip": ip, "userID": userID, "username": username, "time": executionTime, "endpoint": endpoint, "method": method, "status": status})
-	if err != nil {
-		fmt.Println("Failed to log data: " + err.Error())
-		return
+// Queue a log to be saved in the log database by the background writer, it never blocks the request.
+// If the queue is full the log is dropped, the writer reports how many were dropped.
+func Log(entry Entry) {
+	entry.IP = AnonymizeIP(entry.IP)
+	select {
+	case entries <- entry:
+	default:
+		dropped.Add(1)
 	}
 }
 
@@ -38,4 +42,10 @@ func Log(ip string, userID int, username string, executionTime float64, endpoint
 // time_	 REAL NOT NULL,
 // endpoint_ TEXT NOT NULL CHECK (TRIM(endpoint_) <> ''),
 // method_	 TEXT NOT NULL CHECK (TRIM(method_) <> ''),
-// status_	 INT NOT NULL
+// status_	 INT NOT NULL,
+// route_	 TEXT,
+// bytes_	 BIGINT,
+// request_id_ TEXT,
+// user_agent_ TEXT,
+// error_code_ TEXT,
+// error_message_ TEXT
@@ -0,0 +1,109 @@
+package logutil
+
+import (
+	"backend/database/errorcodes"
+	"backend/types"
+	c "backend/util/config"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Set up the default slog logger to write JSON records to stdout.
+// The level is set with LOG_LEVEL (debug, info, warn or error), info by default.
+func InitLogger() {
+	// Validated at startup by config.Validate.
+	var level slog.Level
+	level.UnmarshalText([]byte(c.Config.LogLevel))
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level, AddSource: true})
+	slog.SetDefault(slog.New(contextHandler{handler}))
+}
+
+// Add the request id, user id, client ip, route and trace id from the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID, ok := ctx.Value(types.ContextKey("requestID")).(string); ok {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if userID, ok := ctx.Value(types.ContextKey("id")).(int); ok {
+		record.AddAttrs(slog.Int("user_id", userID))
+	}
+	if ip, ok := ctx.Value(types.ContextKey("ip")).(string); ok {
+		record.AddAttrs(slog.String("client_ip", ip))
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+		record.AddAttrs(slog.String("route", rctx.RoutePattern()))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Implemented by the request meta created by the RequestID middleware, to save the error in the request log.
+type errorSetter interface {
+	SetLoggedError(code, message string)
+}
+
+// Log an error with its class, the source of the record is the caller.
+// The class and the message are also saved for the request log.
+func LogError(ctx context.Context, err error, attrs ...slog.Attr) {
+	if meta, ok := ctx.Value(types.ContextKey("meta")).(errorSetter); ok {
+		meta.SetLoggedError(ErrorClass(err), err.Error())
+	}
+	logger := slog.Default()
+	if !logger.Enabled(ctx, slog.LevelError) {
+		return
+	}
+	// Skip runtime.Callers and LogError.
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+	record := slog.NewRecord(time.Now(), slog.LevelError, err.Error(), pcs[0])
+	record.AddAttrs(slog.String("error_class", ErrorClass(err)))
+	record.AddAttrs(attrs...)
+	logger.Handler().Handle(ctx, record)
+}
+
+// Classify an error to group the logs, for example "timeout", "db_40001" or "storage_NoSuchUpload".
+func ErrorClass(err error) string {
+	var pgErr *pgconn.PgError
+	var apiErr smithy.APIError
+	switch {
+	case errors.Is(err, errorcodes.ErrSerializationFailure):
+		return "serialization_failure"
+	case errors.Is(err, errorcodes.ErrDeadlockDetected):
+		return "deadlock"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &pgErr):
+		return "db_" + pgErr.Code
+	case errors.Is(err, pgx.ErrNoRows):
+		return "db_no_rows"
+	case errors.As(err, &apiErr):
+		return "storage_" + apiErr.ErrorCode()
+	default:
+		return "internal"
+	}
+}
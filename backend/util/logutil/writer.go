@@ -0,0 +1,352 @@
+package logutil
+
+import (
+	logdb "backend/logdatabase"
+	c "backend/util/config"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// Stop spilling logs to disk above this size, then the logs are dropped.
+	maxSpillBytes = 256 << 20
+	// Delay before pinging the log database after a failure, doubled with every failed ping.
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+)
+
+var (
+	// Set by StartWriter, after the config is validated. Logs are dropped while entries is nil.
+	batchSize     int
+	flushInterval time.Duration
+	entries       chan Entry
+
+	stop    = make(chan struct{})
+	stopped = make(chan struct{})
+	started atomic.Bool
+
+	dropped atomic.Int64
+	// Size of the spill files. The files are only written and replayed by the writer goroutine.
+	spilledBytes int64
+	// Saves a batch in the log database, replaced in tests.
+	copyBatch = copyEntries
+	// Pings the log database, replaced in tests.
+	pingDB = pingLogDB
+
+	// Set while the log database is down, then logs are spilled without waiting for it to time out
+	// and it is not used again before a ping at retryAt succeeds. Only used by the writer goroutine.
+	down       bool
+	retryDelay time.Duration
+	retryAt    time.Time
+)
+
+var logColumns = []string{"date_", "ip_", "user_id_", "username_", "time_", "endpoint_", "method_", "status_",
+	"route_", "bytes_", "request_id_", "user_agent_", "error_code_", "error_message_"}
+
+// Start the background writer saving queued logs in the log database.
+func StartWriter() {
+	if logdb.Pool == nil {
+		return
+	}
+	if !started.CompareAndSwap(false, true) {
+		return
+	}
+	batchSize = c.Config.LogBatchSize
+	flushInterval = time.Millisecond * time.Duration(c.Config.LogFlushInterval)
+	entries = make(chan Entry, c.Config.LogBufferSize)
+	spilledBytes = spillSize()
+	go write()
+}
+
+// Stop the writer after saving all queued logs, or spilling them to disk if the log database does not respond in time.
+func StopWriter(ctx context.Context) error {
+	if !started.Load() {
+		return nil
+	}
+	close(stop)
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func write() {
+	defer close(stopped)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]Entry, 0, batchSize)
+	for {
+		select {
+		case entry := <-entries:
+			batch = append(batch, entry)
+			if len(batch) >= batchSize {
+				flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				flush(batch)
+				batch = batch[:0]
+			}
+			replaySpilled()
+			if n := dropped.Swap(0); n > 0 {
+				slog.Warn("Dropped request logs", "count", n)
+			}
+		case <-stop:
+			// Save what is left in the queue.
+			for {
+				select {
+				case entry := <-entries:
+					batch = append(batch, entry)
+					if len(batch) >= batchSize {
+						flush(batch)
+						batch = batch[:0]
+					}
+					continue
+				default:
+				}
+				break
+			}
+			if len(batch) > 0 {
+				flush(batch)
+			}
+			return
+		}
+	}
+}
+
+// Save a batch with COPY, spill it to disk on failure or while the log database is down.
+func flush(batch []Entry) {
+	if !logDBUp() {
+		spillOrDrop(batch)
+		return
+	}
+	err := copyBatch(batch)
+	if err != nil {
+		LogError(context.Background(), err, slog.Int("logs", len(batch)))
+		markDown()
+		spillOrDrop(batch)
+	}
+}
+
+// Report if the log database can be used. While it is down, it is pinged once the retry delay passed.
+func logDBUp() bool {
+	if !down {
+		return true
+	}
+	if time.Now().Before(retryAt) {
+		return false
+	}
+	if err := pingDB(); err != nil {
+		markDown()
+		return false
+	}
+	down = false
+	retryDelay = 0
+	slog.Info("Log database is back, saving the spilled logs")
+	return true
+}
+
+// Stop using the log database until the retry delay passes, doubling the delay if it was already down.
+func markDown() {
+	down = true
+	retryDelay = min(max(retryDelay*2, minRetryDelay), maxRetryDelay)
+	retryAt = time.Now().Add(retryDelay)
+}
+
+func pingLogDB() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	return logdb.Ping(ctx)
+}
+
+func copyEntries(batch []Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	conn, err := logdb.GetConnection(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	rows := make([][]any, len(batch))
+	for i, e := range batch {
+		// Empty strings are saved as NULL, user agent and error message are truncated.
+		rows[i] = []any{e.Date, e.IP, e.UserID, e.Username, float32(e.ExecutionTime), e.Endpoint, e.Method, e.Status,
+			nullString(e.Route), e.Bytes, nullString(e.RequestID), nullString(truncate(e.UserAgent, 255)),
+			nullString(e.ErrorCode), nullString(truncate(e.ErrorMessage, 1000))}
+	}
+	_, err = conn.CopyFrom(ctx, pgx.Identifier{"log_"}, logColumns, pgx.CopyFromRows(rows))
+	return err
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+	return s
+}
+
+// Append logs to the current spill file as JSON lines, or drop them if spilling is disabled or the spill directory is full.
+// Only called by the writer goroutine.
+func spillOrDrop(batch []Entry) {
+	if c.Config.LogSpillDir == "" || spilledBytes > maxSpillBytes {
+		dropped.Add(int64(len(batch)))
+		return
+	}
+	// One file per minute, so that a file does not grow forever while the log database is down.
+	name := filepath.Join(c.Config.LogSpillDir, "requests-"+strconv.FormatInt(time.Now().Unix()/60, 10)+".ndjson")
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		dropped.Add(int64(len(batch)))
+		return
+	}
+	defer file.Close()
+	for _, entry := range batch {
+		line, err := json.Marshal(entry)
+		if err == nil {
+			_, err = file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			dropped.Add(1)
+			continue
+		}
+		spilledBytes += int64(len(line) + 1)
+	}
+}
+
+func spillFiles() []string {
+	files, _ := filepath.Glob(filepath.Join(c.Config.LogSpillDir, "requests-*.ndjson"))
+	sort.Strings(files)
+	return files
+}
+
+// Size of the spill files left by an earlier run, read once when the writer starts.
+func spillSize() int64 {
+	if c.Config.LogSpillDir == "" {
+		return 0
+	}
+	var size int64
+	for _, name := range spillFiles() {
+		if info, err := os.Stat(name); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// Save the spilled logs, oldest files first. Only called by the writer goroutine.
+func replaySpilled() {
+	if c.Config.LogSpillDir == "" || spilledBytes == 0 || !logDBUp() {
+		return
+	}
+	for _, name := range spillFiles() {
+		err := replayFile(name)
+		if err != nil {
+			// The log database is probably still down, try again after the retry delay.
+			return
+		}
+	}
+}
+
+// Save the logs of a spill file in batches and remove it. If a batch fails, the logs saved before it
+// are cut from the file, so that they are not saved twice when the file is replayed again.
+func replayFile(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	batch := make([]Entry, 0, batchSize)
+	// Offset of the first line that is not saved yet, and of the end of the lines read so far.
+	var saved, read int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		read += int64(len(line))
+		var entry Entry
+		// Skip a line that was cut, for example by a crash.
+		if len(line) > 0 && json.Unmarshal(line, &entry) == nil {
+			batch = append(batch, entry)
+		}
+		if len(batch) >= batchSize || (readErr != nil && len(batch) > 0) {
+			if err := copyBatch(batch); err != nil {
+				markDown()
+				return cutSpillFile(name, saved, err)
+			}
+			batch = batch[:0]
+			saved = read
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return cutSpillFile(name, saved, readErr)
+		}
+	}
+	err = os.Remove(name)
+	if err != nil {
+		return err
+	}
+	spilledBytes = max(0, spilledBytes-info.Size())
+	return nil
+}
+
+// Remove the first saved bytes of a spill file by copying the rest to a new file, then return err.
+func cutSpillFile(name string, saved int64, err error) error {
+	if saved == 0 {
+		return err
+	}
+	file, openErr := os.Open(name)
+	if openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	defer file.Close()
+	temp, createErr := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp-*")
+	if createErr != nil {
+		return errors.Join(err, createErr)
+	}
+	_, copyErr := file.Seek(saved, io.SeekStart)
+	if copyErr == nil {
+		_, copyErr = io.Copy(temp, file)
+	}
+	closeErr := temp.Close()
+	if copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr == nil {
+		copyErr = os.Rename(temp.Name(), name)
+	}
+	if copyErr != nil {
+		os.Remove(temp.Name())
+		return errors.Join(err, copyErr)
+	}
+	spilledBytes = max(0, spilledBytes-saved)
+	return err
+}
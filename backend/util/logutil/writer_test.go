@@ -0,0 +1,285 @@
+package logutil
+
+import (
+	c "backend/util/config"
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Replace the log database and the spill directory for a test, the database answers pings.
+func setupWriter(t *testing.T, size int, copy func(batch []Entry) error) string {
+	t.Helper()
+	dir := t.TempDir()
+	oldCopy, oldPing, oldSpillDir, oldBatchSize := copyBatch, pingDB, c.Config.LogSpillDir, batchSize
+	t.Cleanup(func() {
+		copyBatch, pingDB, c.Config.LogSpillDir, batchSize = oldCopy, oldPing, oldSpillDir, oldBatchSize
+		spilledBytes = 0
+		dropped.Store(0)
+		down, retryDelay, retryAt = false, 0, time.Time{}
+	})
+	copyBatch = copy
+	pingDB = func() error { return nil }
+	c.Config.LogSpillDir = dir
+	batchSize = size
+	spilledBytes = 0
+	dropped.Store(0)
+	down, retryDelay, retryAt = false, 0, time.Time{}
+	return dir
+}
+
+// Let the retry delay pass, so that the log database is pinged on the next flush or replay.
+func passRetryDelay() {
+	retryAt = time.Now()
+}
+
+func testEntries(n int) []Entry {
+	batch := make([]Entry, n)
+	for i := range batch {
+		batch[i] = Entry{Date: time.Unix(int64(i), 0).UTC(), IP: "127.0.0.1", Endpoint: "/api/" + strconv.Itoa(i), Method: "GET", Status: 200}
+	}
+	return batch
+}
+
+func countLines(t *testing.T) int {
+	t.Helper()
+	lines := 0
+	for _, name := range spillFiles() {
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			lines++
+		}
+		file.Close()
+	}
+	return lines
+}
+
+func TestWriteBatches(t *testing.T) {
+	var batches []int
+	setupWriter(t, 3, func(batch []Entry) error {
+		batches = append(batches, len(batch))
+		return nil
+	})
+	oldEntries, oldStop, oldStopped, oldInterval := entries, stop, stopped, flushInterval
+	t.Cleanup(func() { entries, stop, stopped, flushInterval = oldEntries, oldStop, oldStopped, oldInterval })
+	entries = make(chan Entry, 10)
+	stop = make(chan struct{})
+	stopped = make(chan struct{})
+	flushInterval = time.Hour
+
+	for _, entry := range testEntries(7) {
+		Log(entry)
+	}
+	go write()
+	close(stop)
+	<-stopped
+
+	// Full batches are saved right away, the rest when the writer stops.
+	if len(batches) != 3 || batches[0] != 3 || batches[1] != 3 || batches[2] != 1 {
+		t.Fatalf("saved batches of %v logs, want [3 3 1]", batches)
+	}
+}
+
+func TestLogDropsWhenQueueIsFull(t *testing.T) {
+	setupWriter(t, 3, func([]Entry) error { return nil })
+	oldEntries := entries
+	t.Cleanup(func() { entries = oldEntries })
+	entries = make(chan Entry, 2)
+
+	for _, entry := range testEntries(5) {
+		Log(entry)
+	}
+	if len(entries) != 2 || dropped.Load() != 3 {
+		t.Fatalf("queued %d and dropped %d logs, want 2 and 3", len(entries), dropped.Load())
+	}
+}
+
+func TestSpillAndReplay(t *testing.T) {
+	var saved []Entry
+	fail := true
+	setupWriter(t, 2, func(batch []Entry) error {
+		if fail {
+			return errors.New("log database is down")
+		}
+		saved = append(saved, batch...)
+		return nil
+	})
+
+	flush(testEntries(5))
+	if lines := countLines(t); lines != 5 {
+		t.Fatalf("spilled %d logs, want 5", lines)
+	}
+	if spilledBytes != spillSize() {
+		t.Fatalf("counted %d spilled bytes, the files have %d", spilledBytes, spillSize())
+	}
+
+	// Nothing is removed while the database is down.
+	pingDB = func() error { return errors.New("log database is down") }
+	passRetryDelay()
+	replaySpilled()
+	if lines := countLines(t); lines != 5 {
+		t.Fatalf("%d logs left after a failed replay, want 5", lines)
+	}
+
+	fail = false
+	pingDB = func() error { return nil }
+	passRetryDelay()
+	replaySpilled()
+	if len(saved) != 5 {
+		t.Fatalf("replayed %d logs, want 5", len(saved))
+	}
+	for i, entry := range testEntries(5) {
+		if saved[i] != entry {
+			t.Fatalf("replayed log %d is %+v, want %+v", i, saved[i], entry)
+		}
+	}
+	if files := spillFiles(); len(files) != 0 || spilledBytes != 0 {
+		t.Fatalf("%d spill files and %d bytes left after replaying", len(files), spilledBytes)
+	}
+}
+
+func TestReplayCutsSavedLogs(t *testing.T) {
+	var saved []Entry
+	calls := 0
+	dir := setupWriter(t, 2, func(batch []Entry) error {
+		calls++
+		// Fail the first spill and the second batch of the first replay.
+		if calls == 1 || calls == 3 {
+			return errors.New("log database is down")
+		}
+		saved = append(saved, batch...)
+		return nil
+	})
+
+	flush(testEntries(5))
+	passRetryDelay()
+	replaySpilled()
+	if len(saved) != 2 {
+		t.Fatalf("replayed %d logs before the failure, want 2", len(saved))
+	}
+	if lines := countLines(t); lines != 3 {
+		t.Fatalf("%d logs left after a failed batch, want 3", lines)
+	}
+	if spilledBytes != spillSize() {
+		t.Fatalf("counted %d spilled bytes, the files have %d", spilledBytes, spillSize())
+	}
+
+	// The logs saved before the failure are not saved again.
+	passRetryDelay()
+	replaySpilled()
+	if len(saved) != 5 {
+		t.Fatalf("replayed %d logs in total, want 5", len(saved))
+	}
+	for i, entry := range testEntries(5) {
+		if saved[i] != entry {
+			t.Fatalf("replayed log %d is %+v, want %+v", i, saved[i], entry)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("%d files left in the spill directory", len(entries))
+	}
+}
+
+func TestReplaySkipsCutLines(t *testing.T) {
+	var saved []Entry
+	dir := setupWriter(t, 10, func(batch []Entry) error {
+		saved = append(saved, batch...)
+		return nil
+	})
+	name := filepath.Join(dir, "requests-1.ndjson")
+	err := os.WriteFile(name, []byte(`{"Endpoint":"/api/a","Method":"GET"}`+"\n"+`{"Endpoint":"/api/b","Me`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spilledBytes = spillSize()
+
+	replaySpilled()
+	if len(saved) != 1 || saved[0].Endpoint != "/api/a" {
+		t.Fatalf("replayed %+v, want only /api/a", saved)
+	}
+	if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("the replayed file was not removed")
+	}
+}
+
+func TestSpillLimit(t *testing.T) {
+	setupWriter(t, 2, func([]Entry) error { return errors.New("log database is down") })
+	spilledBytes = maxSpillBytes + 1
+
+	flush(testEntries(3))
+	if lines := countLines(t); lines != 0 || dropped.Load() != 3 {
+		t.Fatalf("spilled %d and dropped %d logs over the limit, want 0 and 3", lines, dropped.Load())
+	}
+}
+
+func TestOutageBackoff(t *testing.T) {
+	copies, pings := 0, 0
+	fail := true
+	setupWriter(t, 10, func([]Entry) error {
+		copies++
+		if fail {
+			return errors.New("log database is down")
+		}
+		return nil
+	})
+	pingDB = func() error {
+		pings++
+		if fail {
+			return errors.New("log database is down")
+		}
+		return nil
+	}
+
+	flush(testEntries(2))
+	if copies != 1 || !down {
+		t.Fatalf("tried %d copies, want 1 and the database down", copies)
+	}
+
+	// Nothing waits for the database until the retry delay passes.
+	flush(testEntries(2))
+	replaySpilled()
+	if copies != 1 || pings != 0 {
+		t.Fatalf("tried %d copies and %d pings during the retry delay, want 1 and 0", copies, pings)
+	}
+	if lines := countLines(t); lines != 4 {
+		t.Fatalf("spilled %d logs, want 4", lines)
+	}
+
+	// A failed ping doubles the delay.
+	passRetryDelay()
+	replaySpilled()
+	if pings != 1 || copies != 1 || retryDelay != minRetryDelay*2 {
+		t.Fatalf("tried %d pings and %d copies with a %v delay, want 1, 1 and %v", pings, copies, retryDelay, minRetryDelay*2)
+	}
+
+	// The spilled logs are saved once a ping succeeds.
+	fail = false
+	passRetryDelay()
+	replaySpilled()
+	if pings != 2 || copies != 2 || down || retryDelay != 0 {
+		t.Fatalf("tried %d pings and %d copies, down %v, want 2, 2 and up", pings, copies, down)
+	}
+	if lines := countLines(t); lines != 0 {
+		t.Fatalf("%d logs left after the database came back", lines)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	setupWriter(t, 10, func([]Entry) error { return nil })
+	want := []time.Duration{time.Second, time.Second * 2, time.Second * 4, time.Second * 8, time.Second * 16, time.Second * 32, time.Minute, time.Minute}
+	for i, delay := range want {
+		start := time.Now()
+		markDown()
+		if retryDelay != delay || retryAt.Before(start.Add(delay)) {
+			t.Fatalf("failure %d retries in %v at %v, want %v", i+1, retryDelay, retryAt.Sub(start), delay)
+		}
+	}
+}
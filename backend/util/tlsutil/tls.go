@@ -0,0 +1,121 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Serves the certificate loaded from a cert and key file, and loads it again when either file changes,
+// so that a renewed certificate (for example by certbot) is used without a restart.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// Load the certificate, failing if the files are missing or invalid.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	reloader := &CertReloader{certFile: certFile, keyFile: keyFile}
+	_, err := reloader.reload()
+	if err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Use as tls.Config.GetCertificate.
+func (reloader *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+	return reloader.cert, nil
+}
+
+// Check the files every interval until ctx is done, keeping the previous certificate if the new one is invalid,
+// for example when only one of the files was written yet.
+func (reloader *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := reloader.reload()
+			if err != nil {
+				slog.Error("Error reloading the TLS certificate, keeping the previous one", "error", err)
+				continue
+			}
+			if reloaded {
+				slog.Info("Reloaded the TLS certificate", "cert_file", reloader.certFile)
+			}
+		}
+	}
+}
+
+// Load the certificate if either file was modified after the last load.
+func (reloader *CertReloader) reload() (bool, error) {
+	modTime, err := latestModTime(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return false, err
+	}
+	reloader.mu.RLock()
+	unchanged := reloader.cert != nil && modTime.Equal(reloader.modTime)
+	reloader.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return false, err
+	}
+	reloader.mu.Lock()
+	reloader.cert = &cert
+	reloader.modTime = modTime
+	reloader.mu.Unlock()
+	return true, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Redirect every request to the same host and path over HTTPS, httpsPort is added to the host if it is not 443.
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			// An IPv6 address.
+			host = "[" + host + "]"
+		}
+		url := "https://" + host + r.URL.RequestURI()
+		// 308 keeps the method and body of non GET requests.
+		http.Redirect(w, r, url, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,188 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Write a self-signed certificate for name and its key, modified at modTime.
+func writeCert(t *testing.T, certFile, keyFile, name string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: name}, DNSNames: []string{name},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), modTime)
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), modTime)
+}
+
+func writeFile(t *testing.T, file string, data []byte, modTime time.Time) {
+	t.Helper()
+	err := os.WriteFile(file, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Set the modification time, the file system may not see writes in the same second as a change.
+	err = os.Chtimes(file, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The name of the certificate served by the reloader.
+func servedName(t *testing.T, reloader *CertReloader) string {
+	t.Helper()
+	cert, err := reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	start := time.Now().Add(-time.Hour)
+	writeCert(t, certFile, keyFile, "old.example.com", start)
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := servedName(t, reloader); name != "old.example.com" {
+		t.Fatalf("serving %s, want old.example.com", name)
+	}
+
+	reloaded, err := reloader.reload()
+	if reloaded || err != nil {
+		t.Fatalf("reloaded unchanged files: %v", err)
+	}
+
+	// A renewal writes a new pair.
+	writeCert(t, certFile, keyFile, "new.example.com", start.Add(time.Minute))
+	reloaded, err = reloader.reload()
+	if !reloaded || err != nil {
+		t.Fatalf("did not reload the new pair: %v", err)
+	}
+	if name := servedName(t, reloader); name != "new.example.com" {
+		t.Fatalf("serving %s, want new.example.com", name)
+	}
+
+	// Only the cert of the next renewal is written yet, it does not match the key.
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeCert(t, certFile, keyFile, "next.example.com", start.Add(time.Minute*2))
+	writeFile(t, keyFile, key, start.Add(time.Minute*2))
+	reloaded, err = reloader.reload()
+	if reloaded || err == nil {
+		t.Fatal("loaded a cert with the wrong key")
+	}
+	if name := servedName(t, reloader); name != "new.example.com" {
+		t.Fatalf("serving %s after a bad pair, want new.example.com", name)
+	}
+
+	writeFile(t, certFile, []byte("not a certificate"), start.Add(time.Minute*3))
+	reloaded, err = reloader.reload()
+	if reloaded || err == nil {
+		t.Fatal("loaded an invalid cert")
+	}
+	if name := servedName(t, reloader); name != "new.example.com" {
+		t.Fatalf("serving %s after an invalid cert, want new.example.com", name)
+	}
+}
+
+func TestCertReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	start := time.Now().Add(-time.Hour)
+	writeCert(t, certFile, keyFile, "old.example.com", start)
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reloader.Watch(ctx, time.Millisecond*10)
+		close(done)
+	}()
+
+	writeCert(t, certFile, keyFile, "new.example.com", start.Add(time.Minute))
+	deadline := time.Now().Add(time.Second * 5)
+	for servedName(t, reloader) != "new.example.com" {
+		if time.Now().After(deadline) {
+			t.Fatal("Watch did not reload the new pair")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Watch did not stop with its context")
+	}
+}
+
+func TestNewCertReloaderMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err == nil {
+		t.Fatal("loaded missing files")
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name, httpsPort, host, target, want string
+	}{
+		{"path and query", "443", "example.com", "/api/file?id=1&name=a%20b", "https://example.com/api/file?id=1&name=a%20b"},
+		{"http port dropped", "443", "example.com:80", "/", "https://example.com/"},
+		{"other port dropped", "", "example.com:8080", "/login", "https://example.com/login"},
+		{"https port added", "8443", "example.com", "/", "https://example.com:8443/"},
+		{"port replaced", "8443", "example.com:8080", "/a?b=c", "https://example.com:8443/a?b=c"},
+		{"ipv6", "443", "[2001:db8::1]:80", "/", "https://[2001:db8::1]/"},
+		{"ipv6 with port", "8443", "[2001:db8::1]", "/", "https://[2001:db8::1]:8443/"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, method := range []string{"GET", "POST"} {
+				r := httptest.NewRequest(method, "http://"+test.host+test.target, nil)
+				w := httptest.NewRecorder()
+				RedirectHandler(test.httpsPort).ServeHTTP(w, r)
+				if w.Code != http.StatusPermanentRedirect {
+					t.Fatalf("%s got %d, want 308", method, w.Code)
+				}
+				if location := w.Header().Get("Location"); location != test.want {
+					t.Fatalf("%s redirected to %s, want %s", method, location, test.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package txutil
+
+import (
+	"backend/database/errorcodes"
+	"backend/metrics"
+	c "backend/util/config"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Attempts of a transaction failing to serialize or deadlocking, and the backoff before retrying it.
+// The backoff doubles after every attempt and half of it is random, so that conflicting transactions
+// do not retry at the same time again.
+const (
+	maxAttempts = 3
+	baseBackoff = time.Millisecond * 10
+	maxBackoff  = time.Millisecond * 200
+)
+
+var (
+	writeOptions = pgx.TxOptions{IsoLevel: pgx.Serializable}
+	// Read only deferrable transactions wait for a snapshot that cannot fail serializing, so they are not retried.
+	readOptions = pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly, DeferrableMode: pgx.Deferrable}
+	// Replaced in tests.
+	runTx = run
+)
+
+// Run fn in a serializable transaction and commit it, retrying the whole transaction on serialization failure or deadlock.
+// fn can run more than once and should only change variables it sets from scratch on every attempt.
+// handler labels the retry metrics, for example "file.PatchFolderName".
+// Postgres errors are returned wrapped with their typed error from errorcodes.
+func Run(ctx context.Context, conn *pgxpool.Conn, handler string, fn func(tx pgx.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			metrics.SerializationRetries.WithLabelValues(handler).Inc()
+			err = sleep(ctx, backoff(attempt))
+			if err != nil {
+				return err
+			}
+		}
+		err = runTx(ctx, conn, writeOptions, fn)
+		if !errors.Is(err, errorcodes.ErrSerializationFailure) && !errors.Is(err, errorcodes.ErrDeadlockDetected) {
+			return err
+		}
+	}
+	metrics.SerializationFailures.WithLabelValues(handler).Inc()
+	return fmt.Errorf("%w after %d attempts", err, maxAttempts)
+}
+
+// Detach returns a context that is not canceled with the request, with its own REQUEST_TIMEOUT_WRITE deadline.
+// It is for the database writes that follow deleting objects from storage, so a client disconnecting
+// in between cannot leave rows pointing at objects that no longer exist.
+func Detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), time.Second*time.Duration(c.Config.RequestTimeoutWrite))
+}
+
+// Run fn in a read only transaction, for reads that need a consistent snapshot, like permission checks before writing.
+func RunReadOnly(ctx context.Context, conn *pgxpool.Conn, fn func(tx pgx.Tx) error) error {
+	return run(ctx, conn, readOptions, fn)
+}
+
+func run(ctx context.Context, conn *pgxpool.Conn, options pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, options)
+	if err != nil {
+		return errorcodes.Map(err)
+	}
+	// If commit is not run first this will rollback the transaction.
+	defer tx.Rollback(ctx)
+
+	err = fn(tx)
+	if err != nil {
+		return errorcodes.Map(err)
+	}
+	return errorcodes.Map(tx.Commit(ctx))
+}
+
+// Get a random backoff up to baseBackoff doubled for every retry before this attempt.
+func backoff(attempt int) time.Duration {
+	limit := min(baseBackoff<<(attempt-2), maxBackoff)
+	return limit/2 + rand.N(limit/2)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
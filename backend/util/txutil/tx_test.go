@@ -0,0 +1,134 @@
+package txutil
+
+import (
+	"backend/database/errorcodes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Run the transaction function without a database, mapping its errors like a real transaction.
+func fakeTx(t *testing.T) {
+	t.Helper()
+	oldRunTx := runTx
+	t.Cleanup(func() { runTx = oldRunTx })
+	runTx = func(ctx context.Context, conn *pgxpool.Conn, options pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+		return errorcodes.Map(fn(nil))
+	}
+}
+
+// A transaction function failing with the postgres error codes in order, then succeeding.
+func failWith(attempts *int, codes ...string) func(tx pgx.Tx) error {
+	return func(tx pgx.Tx) error {
+		*attempts++
+		if *attempts <= len(codes) {
+			return &pgconn.PgError{Code: codes[*attempts-1]}
+		}
+		return nil
+	}
+}
+
+func TestRunRetries(t *testing.T) {
+	fakeTx(t)
+	tests := []struct {
+		name     string
+		codes    []string
+		attempts int
+		err      error  // Typed error returned.
+		pgCode   string // Postgres error returned without a typed error.
+	}{
+		{name: "success", attempts: 1},
+		{name: "serialization failure", codes: []string{pgerrcode.SerializationFailure}, attempts: 2},
+		{name: "deadlock", codes: []string{pgerrcode.DeadlockDetected}, attempts: 2},
+		{name: "both", codes: []string{pgerrcode.DeadlockDetected, pgerrcode.SerializationFailure}, attempts: 3},
+		{name: "unique violation", codes: []string{pgerrcode.UniqueViolation}, attempts: 1, err: errorcodes.ErrUniqueViolation},
+		{name: "other error", codes: []string{pgerrcode.CheckViolation}, attempts: 1, pgCode: pgerrcode.CheckViolation},
+		{name: "unique violation after a retry", codes: []string{pgerrcode.SerializationFailure, pgerrcode.UniqueViolation}, attempts: 2,
+			err: errorcodes.ErrUniqueViolation},
+		{name: "too many attempts", codes: []string{pgerrcode.SerializationFailure, pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected},
+			attempts: maxAttempts, err: errorcodes.ErrDeadlockDetected},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			err := Run(context.Background(), nil, "test", failWith(&attempts, test.codes...))
+			if attempts != test.attempts {
+				t.Fatalf("ran %d attempts, want %d", attempts, test.attempts)
+			}
+			var pgErr *pgconn.PgError
+			switch {
+			case test.pgCode != "":
+				if !errors.As(err, &pgErr) || pgErr.Code != test.pgCode {
+					t.Fatal("got error", err, "want postgres error", test.pgCode)
+				}
+			case test.err == nil && err != nil:
+				t.Fatal("got error", err)
+			case !errors.Is(err, test.err):
+				t.Fatal("got error", err, "want", test.err)
+			}
+		})
+	}
+}
+
+func TestRunErrorAfterAllAttempts(t *testing.T) {
+	fakeTx(t)
+	attempts := 0
+	codes := []string{pgerrcode.SerializationFailure, pgerrcode.SerializationFailure, pgerrcode.SerializationFailure, pgerrcode.SerializationFailure}
+	err := Run(context.Background(), nil, "test", failWith(&attempts, codes...))
+	if attempts != maxAttempts || !errors.Is(err, errorcodes.ErrSerializationFailure) || !strings.HasSuffix(err.Error(), "after 3 attempts") {
+		t.Fatalf("ran %d attempts with error %v, want %d attempts", attempts, err, maxAttempts)
+	}
+}
+
+func TestRunStopsWhenCanceled(t *testing.T) {
+	fakeTx(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := Run(ctx, nil, "test", func(tx pgx.Tx) error {
+		attempts++
+		// The request ends while the transaction is failing.
+		cancel()
+		return &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+	})
+	if attempts != 1 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("ran %d attempts with error %v, want 1 attempt canceled", attempts, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 2; attempt <= 10; attempt++ {
+		// The limit doubles from the base backoff for every retry, up to the max backoff.
+		limit := min(baseBackoff<<(attempt-2), maxBackoff)
+		seen := map[time.Duration]bool{}
+		for range 100 {
+			d := backoff(attempt)
+			if d < limit/2 || d >= limit {
+				t.Fatalf("attempt %d backed off %v, want at least %v and less than %v", attempt, d, limit/2, limit)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Fatalf("attempt %d always backed off %v, want a random backoff", attempt, backoff(attempt))
+		}
+	}
+}
+
+func TestSleep(t *testing.T) {
+	start := time.Now()
+	if err := sleep(context.Background(), time.Millisecond*20); err != nil || time.Since(start) < time.Millisecond*20 {
+		t.Fatal("sleep returned early:", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	if err := sleep(ctx, time.Minute); !errors.Is(err, context.Canceled) || time.Since(start) > time.Second {
+		t.Fatal("sleep did not stop with its context:", err)
+	}
+}
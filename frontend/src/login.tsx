@@ -26,18 +26,18 @@ function Login() {
         if (res.status == 200) {
             navigate("/home")
         }
-        if (res.status == 404) {
-            setStatus("Username not found.")
+        if (res.status == 401) {
+            setStatus("The username or password is incorrect.")
+        }
+        if (res.status == 429) {
+            setStatus("Too many failed attempts, try again later.")
         }
         if (res.status == 500) {
             setStatus("Unknown server error occurred.")
         }
-        if (res.status == 422 || res.status == 413) {
+        if (res.status == 400 || res.status == 413) {
             setStatus("Given credentials are too long or empty.")
         }
-        if (res.status == 400) {
-            setStatus("The password is incorrect.")
-        }
         setLoading(false)
     }
 
@@ -1,5 +1,8 @@
 export type ErrorResponse = {
-    message: string
+    code: string,
+    message: string,
+    details?: Record<string, unknown>,
+    requestID?: string,
 }
 export let messages: {userHasNoSpace: string, insufficientPermission: string, 
 fileAlreadyExists: string, containingFolderDoesNotExist: string} = {